@@ -1,10 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -44,7 +46,14 @@ func main() {
 	// Für Demo nutzen wir die kleinste Variante
 	fmt.Println("📥 Lade externe Blacklist von GitHub...")
 	//hostsURL := "https://raw.githubusercontent.com/StevenBlack/hosts/master/hosts"
-	added, err := blacklist.LoadFromFile("hosts")
+	blacklist.AddList(dns.ListInfo{Name: "stevenblack", Source: "hosts", Category: "ads", Enabled: true})
+	added, err := blacklist.LoadList("stevenblack")
+	var loadErr *dns.LoadError
+	if errors.As(err, &loadErr) {
+		// Einzelne ungültige Zeilen, die gültigen Einträge sind geladen
+		log.Printf("⚠️  Warnung: %v", loadErr)
+		err = nil
+	}
 	if err != nil {
 		log.Printf("⚠️  Warnung: Konnte externe Blacklist nicht laden: %v", err)
 		log.Println("   Fahre mit manuellen Regeln fort...")
@@ -57,25 +66,66 @@ func main() {
 		fmt.Printf("✅ %d Domains von externer Blacklist geladen\n\n", added)
 	}
 
+	// Listen einmal täglich aktualisieren, unveränderte Quellen werden übersprungen
+	refresher := dns.NewListRefresher(blacklist, 24*time.Hour)
+	defer refresher.Stop()
+	refresher.SetOnRefresh(func(results []dns.RefreshResult) {
+		for _, r := range results {
+			if r.Err != nil {
+				log.Printf("⚠️  Warnung: Konnte Liste %s nicht aktualisieren: %v", r.Name, r.Err)
+			}
+		}
+	})
+
 	// Füge zusätzliche manuelle Regeln hinzu
 	blacklist.AddDomain("ads.example.com")
 	blacklist.AddDomain("tracker.example.com")
 
+	// Allowlist hat Vorrang vor der Blacklist (optional, Datei "allowlist")
+	allowlist := dns.NewAllowlist()
+	if _, err := os.Stat("allowlist"); err == nil {
+		allowed, err := allowlist.LoadFromFile("allowlist")
+		if err != nil {
+			log.Printf("⚠️  Warnung: Konnte Allowlist nicht laden: %v", err)
+		} else {
+			fmt.Printf("✅ %d Domains von Allowlist geladen\n\n", allowed)
+		}
+	}
+
+	// IP-Regeln für Upstream-Antworten (optional, Datei "ipblocklist") und Rebinding-Schutz
+	ipFilter := dns.NewIPFilter()
+	ipFilter.SetRebindingProtection(true)
+	if _, err := os.Stat("ipblocklist"); err == nil {
+		networks, err := ipFilter.LoadFromFile("ipblocklist")
+		if err != nil {
+			log.Printf("⚠️  Warnung: Konnte IP-Blockliste nicht vollständig laden: %v", err)
+		}
+		fmt.Printf("✅ %d Netze von IP-Blockliste geladen\n\n", networks)
+	}
+
 	// Initialisiere Cache (2 Stunden TTL, 5 Minuten Cleanup)
 	cache := dns.NewCache(2*time.Hour, 5*time.Minute)
 	defer cache.Stop()
 
 	// Erstelle Proxy mit Cache und Round-Robin
 	proxy := dns.NewProxyWithCache(registry, blacklist, cache)
+	defer proxy.Close()
+	proxy.SetAllowlist(allowlist)
+	proxy.SetIPFilter(ipFilter)
 
 	// Konfiguration ausgeben
 	fmt.Printf("📋 Konfiguration:\n")
 	fmt.Printf("   DNS-Server (Round-Robin): %d\n", registry.Count())
 	servers := registry.GetAllServers()
 	for _, s := range servers {
-		fmt.Printf("     • %s (%s)\n", s.GetName(), s.GetAddress())
+		fmt.Printf("     • %s (%s)\n", s.GetName(), strings.Join(s.GetAddresses(), ", "))
 	}
 	fmt.Printf("   Blacklist-Regeln: %d\n", blacklist.Count())
+	for _, l := range blacklist.GetLists() {
+		fmt.Printf("     • %s [%s] %d Regeln, aktiv: %v\n", l.Name, l.Category, l.Count, l.Enabled)
+	}
+	fmt.Printf("   Allowlist-Regeln: %d\n", allowlist.Count())
+	fmt.Printf("   IP-Regeln: %d (Rebinding-Schutz aktiv)\n", ipFilter.Count())
 	fmt.Printf("   Cache TTL: 2 Stunden\n")
 	fmt.Printf("   Cache Cleanup: alle 5 Minuten\n\n")
 
@@ -86,6 +136,10 @@ func main() {
 	if err != nil {
 		log.Fatalf("Fehler beim Erstellen des DNS-Servers: %v", err)
 	}
+	fmt.Printf("🔐 Erlaubte Clients: %s\n", strings.Join(dnsServer.GetAccessControl().GetAllowed(), ", "))
+	limits := dnsServer.GetRateLimiter().GetConfig()
+	fmt.Printf("🚦 Rate Limit: %.0f Anfragen/s pro Client, RRL %.0f Antworten/s, Slip %d\n",
+		limits.QueriesPerSecond, limits.ResponsesPerSecond, limits.Slip)
 
 	fmt.Printf("🚀 Starte DNS-Server auf %s...\n", dnsAddr)
 	err = dnsServer.Start()
@@ -114,6 +168,9 @@ func main() {
 	fmt.Printf("   Cache-Einträge: %d\n", cache.Count())
 	fmt.Printf("   Aktive DNS-Server: %d\n", registry.Count())
 	fmt.Printf("   Blockierte Regeln: %d\n", blacklist.Count())
+	limitStats := dnsServer.GetRateLimiter().GetStats()
+	fmt.Printf("   Anfragen: %d, über dem Limit: %d\n", limitStats.QueriesAllowed, limitStats.QueriesLimited)
+	fmt.Printf("   RRL: %d verworfen, %d abgeschnitten\n", limitStats.ResponsesDropped, limitStats.ResponsesSlipped)
 
 	// Server stoppen
 	err = dnsServer.Stop()
@@ -3,7 +3,11 @@ module gittea.kittel.dev/go-dnsproxy
 go 1.25.4
 
 require (
-	github.com/miekg/dns v1.1.68 // indirect
+	github.com/klauspost/compress v1.20.1
+	github.com/miekg/dns v1.1.68
+)
+
+require (
 	golang.org/x/mod v0.24.0 // indirect
 	golang.org/x/net v0.40.0 // indirect
 	golang.org/x/sync v0.14.0 // indirect
@@ -0,0 +1,164 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errUnsupportedRule markiert Regeln, die nur für Browser gelten (Pfade, kosmetische Filter,
+// Browser-Modifikatoren). Solche Zeilen werden beim Laden übersprungen, ohne als Fehler zu zählen.
+var errUnsupportedRule = errors.New("unsupported adblock rule")
+
+// adblockRule ist eine geparste Regel im Adblock-Plus-/AdGuard-Format
+// Unterstützt wird nur der für DNS relevante Teil der Syntax
+type adblockRule struct {
+	domain string // "example.com" (exakt), "*.example.com" (inkl. Subdomains), Glob oder "/regex/"
+	kind   ruleKind
+	filter dnsTypeFilter
+}
+
+// parseAdblockLine parst eine Zeile im Adblock-Format
+// Unterstützt:
+//
+//	||example.com^              Domain und alle Subdomains blockieren
+//	|example.com^               nur exakt diese Domain blockieren
+//	example.com                 nur exakt diese Domain blockieren
+//	@@||example.com^            Ausnahme (hebt Block-Regeln auf)
+//	||example.com^$important    blockiert auch gegen Ausnahmen
+//	||example.com^$dnstype=AAAA nur für bestimmte Query-Typen
+//	||ad*.example.com^          Glob, "*" steht für beliebige Zeichen
+//	/^ad[0-9]+\./               regulärer Ausdruck
+//
+// Kommentare ("!", "#"), der Header "[Adblock Plus 2.0]" und Leerzeilen liefern ok=false.
+// Browser-Regeln (Pfade, kosmetische Filter, unbekannte Modifikatoren) liefern einen Fehler,
+// der errUnsupportedRule umschließt. Ungültige Domains und Muster liefern andere Fehler.
+func parseAdblockLine(line string) (rule adblockRule, ok bool, err error) {
+	line = strings.TrimSpace(line)
+
+	// Ignoriere leere Zeilen, Kommentare und Header
+	if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[") {
+		return adblockRule{}, false, nil
+	}
+	if strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "##") {
+		return adblockRule{}, false, nil
+	}
+
+	// Kosmetische Filter betreffen nur Browser
+	if strings.Contains(line, "##") || strings.Contains(line, "#@#") || strings.Contains(line, "#?#") {
+		return adblockRule{}, false, fmt.Errorf("%w: cosmetic filter %s", errUnsupportedRule, line)
+	}
+
+	rule.kind = ruleBlock
+	if strings.HasPrefix(line, "@@") {
+		rule.kind = ruleException
+		line = line[2:]
+	}
+
+	// Modifikatoren abtrennen - bei Regexen erst hinter dem schließenden "/",
+	// da "$" im Ausdruck selbst vorkommen darf
+	var pattern, modifiers string
+	if strings.HasPrefix(line, "/") {
+		end := strings.LastIndex(line, "/")
+		rest := line[end+1:]
+		if end == 0 || (rest != "" && !strings.HasPrefix(rest, "$")) {
+			return adblockRule{}, false, fmt.Errorf("%w: path rule %s", errUnsupportedRule, line)
+		}
+		pattern, modifiers = line[:end+1], strings.TrimPrefix(rest, "$")
+	} else {
+		pattern, modifiers, _ = strings.Cut(line, "$")
+	}
+	if modifiers != "" {
+		if err := applyAdblockModifiers(&rule, modifiers); err != nil {
+			return adblockRule{}, false, err
+		}
+	}
+
+	if isRegexRule(pattern) {
+		if _, err := compilePattern(pattern); err != nil {
+			return adblockRule{}, false, err
+		}
+		rule.domain = pattern
+		return rule, true, nil
+	}
+
+	domain, wildcard, err := parseAdblockPattern(pattern)
+	if err != nil {
+		return adblockRule{}, false, err
+	}
+	if wildcard {
+		domain = "*." + domain
+	}
+	rule.domain = domain
+
+	return rule, true, nil
+}
+
+// parseAdblockPattern extrahiert die Domain aus dem Muster einer Regel
+// wildcard ist true bei "||domain^" (Domain inkl. Subdomains)
+func parseAdblockPattern(pattern string) (domain string, wildcard bool, err error) {
+	switch {
+	case strings.HasPrefix(pattern, "||"):
+		wildcard = true
+		pattern = pattern[2:]
+	case strings.HasPrefix(pattern, "|"):
+		pattern = pattern[1:]
+	}
+
+	// "^" markiert das Ende der Domain, "|" das Ende der Adresse
+	pattern = strings.TrimSuffix(pattern, "|")
+	pattern = strings.TrimSuffix(pattern, "^")
+
+	if pattern == "" {
+		return "", false, fmt.Errorf("empty adblock rule")
+	}
+	if strings.ContainsAny(pattern, "/:?=&^|") {
+		return "", false, fmt.Errorf("%w: pattern %s", errUnsupportedRule, pattern)
+	}
+
+	pattern = strings.ToLower(pattern)
+	if strings.Contains(pattern, "*") {
+		// Glob: wird beim Einfügen kompiliert, hier nur vorab validiert
+		glob := pattern
+		if wildcard {
+			glob = "*." + glob
+		}
+		if _, err := compilePattern(glob); err != nil {
+			return "", false, err
+		}
+		return pattern, wildcard, nil
+	}
+	if !isValidHostname(pattern) {
+		return "", false, fmt.Errorf("invalid domain in adblock rule: %s", pattern)
+	}
+
+	return pattern, wildcard, nil
+}
+
+// applyAdblockModifiers wertet die Modifikatoren hinter "$" aus
+// Unterstützt werden $important und $dnstype, alle anderen gelten nur für Browser
+func applyAdblockModifiers(rule *adblockRule, modifiers string) error {
+	for _, modifier := range strings.Split(modifiers, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(modifier), "=")
+
+		switch name {
+		case "important":
+			// "@@...$important" ist eine Ausnahme, die auch $important-Regeln aufhebt
+			if rule.kind == ruleException {
+				rule.kind = ruleImportantException
+			} else {
+				rule.kind = ruleImportant
+			}
+		case "dnstype":
+			filter, err := parseDNSTypeFilter(value)
+			if err != nil {
+				return err
+			}
+			rule.filter = filter
+		default:
+			return fmt.Errorf("%w: modifier %s", errUnsupportedRule, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package dns
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	mdns "github.com/miekg/dns"
+)
+
+func TestParseAdblockLine(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		wantOK     bool
+		wantErr    bool
+		wantDomain string
+		wantKind   ruleKind
+	}{
+		{name: "Domain with subdomains", line: "||ads.example.com^", wantOK: true, wantDomain: "*.ads.example.com", wantKind: ruleBlock},
+		{name: "Exact domain", line: "|ads.example.com^", wantOK: true, wantDomain: "ads.example.com", wantKind: ruleBlock},
+		{name: "Plain domain", line: "ads.example.com", wantOK: true, wantDomain: "ads.example.com", wantKind: ruleBlock},
+		{name: "Uppercase", line: "||ADS.Example.COM^", wantOK: true, wantDomain: "*.ads.example.com", wantKind: ruleBlock},
+		{name: "Exception", line: "@@||ok.example.com^", wantOK: true, wantDomain: "*.ok.example.com", wantKind: ruleException},
+		{name: "Important", line: "||tracker.com^$important", wantOK: true, wantDomain: "*.tracker.com", wantKind: ruleImportant},
+		{name: "Important exception", line: "@@||tracker.com^$important", wantOK: true, wantDomain: "*.tracker.com", wantKind: ruleImportantException},
+		{name: "Dnstype", line: "||example.org^$dnstype=AAAA", wantOK: true, wantDomain: "*.example.org", wantKind: ruleBlock},
+		{name: "Glob", line: "*tracking*.example.*", wantOK: true, wantDomain: "*tracking*.example.*", wantKind: ruleBlock},
+		{name: "Glob with subdomains", line: "||ad*.example.com^", wantOK: true, wantDomain: "*.ad*.example.com", wantKind: ruleBlock},
+		{name: "Regex", line: `/^ad[0-9]+\./`, wantOK: true, wantDomain: `/^ad[0-9]+\./`, wantKind: ruleBlock},
+		{name: "Regex with dollar", line: `/^ads\.com$/$important`, wantOK: true, wantDomain: `/^ads\.com$/`, wantKind: ruleImportant},
+		{name: "Regex exception", line: `@@/^ok[0-9]\./`, wantOK: true, wantDomain: `/^ok[0-9]\./`, wantKind: ruleException},
+		{name: "Comment", line: "! Title: Test", wantOK: false},
+		{name: "Hash comment", line: "# comment", wantOK: false},
+		{name: "Header", line: "[Adblock Plus 2.0]", wantOK: false},
+		{name: "Empty", line: "   ", wantOK: false},
+		{name: "Cosmetic filter", line: "example.com##.banner", wantErr: true},
+		{name: "Path rule", line: "||example.com/ads/*", wantErr: true},
+		{name: "Browser modifier", line: "||example.com^$third-party", wantErr: true},
+		{name: "Unknown dnstype", line: "||example.com^$dnstype=FOO", wantErr: true},
+		{name: "Mixed dnstype", line: "||example.com^$dnstype=A|~AAAA", wantErr: true},
+		{name: "Invalid domain", line: "||-bad-.com^", wantErr: true},
+		{name: "Empty pattern", line: "||^", wantErr: true},
+		{name: "Invalid regex", line: "/ad[0-9/", wantErr: true},
+		{name: "Path starting with slash", line: "/banner/ads.js", wantErr: true},
+		{name: "Glob matching everything", line: "*.*", wantErr: true},
+		{name: "Glob with invalid character", line: "*ads!*.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok, err := parseAdblockLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAdblockLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("parseAdblockLine(%q) ok = %v, want %v", tt.line, ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if rule.domain != tt.wantDomain {
+				t.Errorf("domain = %q, want %q", rule.domain, tt.wantDomain)
+			}
+			if rule.kind != tt.wantKind {
+				t.Errorf("kind = %v, want %v", rule.kind, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestDNSTypeFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		qtype uint16
+		want  bool
+	}{
+		{name: "Listed type", value: "AAAA", qtype: mdns.TypeAAAA, want: true},
+		{name: "Other type", value: "AAAA", qtype: mdns.TypeA, want: false},
+		{name: "Multiple types", value: "A|AAAA", qtype: mdns.TypeA, want: true},
+		{name: "Negated listed", value: "~A", qtype: mdns.TypeA, want: false},
+		{name: "Negated other", value: "~A", qtype: mdns.TypeAAAA, want: true},
+		{name: "Unknown qtype", value: "A", qtype: 0, want: false},
+		{name: "Lowercase", value: "aaaa", qtype: mdns.TypeAAAA, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := parseDNSTypeFilter(tt.value)
+			if err != nil {
+				t.Fatalf("parseDNSTypeFilter(%q) error = %v", tt.value, err)
+			}
+			if got := filter.matches(tt.qtype); got != tt.want {
+				t.Errorf("matches(%d) = %v, want %v", tt.qtype, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlacklist_LoadFromAdblockContent(t *testing.T) {
+	content := `[Adblock Plus 2.0]
+! Title: Test list
+||ads.example.com^
+|exact.example.com^
+@@||ok.ads.example.com^
+||tracker.com^$important
+@@||tracker.com^
+||v6only.example.org^$dnstype=AAAA
+example.com##.banner
+||example.com/path
+`
+
+	bl := NewBlacklist()
+	added, err := bl.LoadFromAdblockContent(content)
+	if err != nil {
+		t.Fatalf("LoadFromAdblockContent() error = %v", err)
+	}
+	if added != 6 {
+		t.Errorf("LoadFromAdblockContent() added = %d, want 6", added)
+	}
+
+	tests := []struct {
+		domain string
+		qtype  uint16
+		want   bool
+	}{
+		{domain: "ads.example.com", want: true},
+		{domain: "x.ads.example.com", want: true},
+		{domain: "ok.ads.example.com", want: false},
+		{domain: "sub.ok.ads.example.com", want: false},
+		{domain: "exact.example.com", want: true},
+		{domain: "sub.exact.example.com", want: false},
+		{domain: "tracker.com", want: true},
+		{domain: "cdn.tracker.com", want: true},
+		{domain: "v6only.example.org", qtype: mdns.TypeAAAA, want: true},
+		{domain: "v6only.example.org", qtype: mdns.TypeA, want: false},
+		{domain: "v6only.example.org", want: false},
+		{domain: "example.com", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := bl.IsBlockedType(tt.domain, tt.qtype); got != tt.want {
+			t.Errorf("IsBlockedType(%q, %d) = %v, want %v", tt.domain, tt.qtype, got, tt.want)
+		}
+	}
+}
+
+func TestBlacklist_ImportantException(t *testing.T) {
+	bl := NewBlacklist()
+	for _, rule := range []string{"||tracker.com^$important", "@@||ok.tracker.com^$important"} {
+		if err := bl.AddAdblockRule(rule); err != nil {
+			t.Fatalf("AddAdblockRule(%q) error = %v", rule, err)
+		}
+	}
+
+	if !bl.IsBlocked("tracker.com") {
+		t.Error("tracker.com should be blocked")
+	}
+	if bl.IsBlocked("ok.tracker.com") {
+		t.Error("ok.tracker.com should be allowed by important exception")
+	}
+}
+
+func TestBlacklist_Match_LayerOrder(t *testing.T) {
+	rules := []string{
+		"||tracker.example.com^$dnstype=A",
+		"||example.com^$dnstype=A|AAAA",
+		"||cdn.tracker.example.com^$dnstype=~MX",
+		"||example.com^",
+	}
+
+	// Trifft eine Domain mehrere Ebenen, gewinnt immer dieselbe Regel (erste angelegte Ebene)
+	for range 20 {
+		bl := NewBlacklist()
+		for _, rule := range rules {
+			if err := bl.AddAdblockRule(rule); err != nil {
+				t.Fatalf("AddAdblockRule(%q) error = %v", rule, err)
+			}
+		}
+
+		match := bl.Match("cdn.tracker.example.com", mdns.TypeA)
+		if !match.Blocked || match.Rule != "*.example.com" {
+			t.Fatalf("Match() = %+v, want rule *.example.com of the default layer", match)
+		}
+		bl.RemoveDomain("*.example.com")
+		if match := bl.Match("cdn.tracker.example.com", mdns.TypeA); match.Rule != "*.tracker.example.com" {
+			t.Fatalf("Match() without default rule = %+v, want rule of the first added layer", match)
+		}
+	}
+}
+
+func TestBlacklist_AddAdblockRule_Errors(t *testing.T) {
+	bl := NewBlacklist()
+
+	if err := bl.AddAdblockRule("! comment"); err == nil {
+		t.Error("AddAdblockRule() with comment should return error")
+	}
+	if err := bl.AddAdblockRule("example.com##.ad"); err == nil {
+		t.Error("AddAdblockRule() with cosmetic filter should return error")
+	}
+	if bl.Count() != 0 {
+		t.Errorf("Count() = %d, want 0", bl.Count())
+	}
+}
+
+func TestParseAdblockLine_UnsupportedIsMarked(t *testing.T) {
+	for _, line := range []string{"example.com##.banner", "||example.com/ads", "||example.com^$third-party"} {
+		if _, _, err := parseAdblockLine(line); !errors.Is(err, errUnsupportedRule) {
+			t.Errorf("parseAdblockLine(%q) error = %v, want errUnsupportedRule", line, err)
+		}
+	}
+	for _, line := range []string{"/ad[0-9/", "||-bad-.com^"} {
+		if _, _, err := parseAdblockLine(line); err == nil || errors.Is(err, errUnsupportedRule) {
+			t.Errorf("parseAdblockLine(%q) error = %v, want validation error", line, err)
+		}
+	}
+}
+
+func TestBlacklist_LoadFromAdblockContent_Patterns(t *testing.T) {
+	content := `! patterns
+/^ad[0-9]+\./
+*tracking*.example.*
+/ad[0-9/
+@@/^ad0\./
+||-bad-.com^
+`
+
+	bl := NewBlacklist()
+	added, err := bl.LoadFromAdblockContent(content)
+	if added != 3 {
+		t.Errorf("LoadFromAdblockContent() added = %d, want 3", added)
+	}
+	if err == nil {
+		t.Fatal("LoadFromAdblockContent() should report invalid lines")
+	}
+
+	var lineErr *LineError
+	if !errors.As(err, &lineErr) || lineErr.Line != 4 {
+		t.Errorf("first LineError = %v, want line 4", lineErr)
+	}
+	if !strings.Contains(err.Error(), "line 6") {
+		t.Errorf("error should mention line 6, got: %v", err)
+	}
+
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{domain: "ad12.example.com", want: true},
+		{domain: "ad0.example.com", want: false},
+		{domain: "my-tracking-host.example.org", want: true},
+		{domain: "tracking.other.org", want: false},
+		{domain: "ads.example.com", want: false},
+	}
+	for _, tt := range tests {
+		if got := bl.IsBlocked(tt.domain); got != tt.want {
+			t.Errorf("IsBlocked(%q) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package dns
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Allowlist verwaltet Domains, die nie blockiert werden
+// Einträge haben Vorrang vor der Blacklist und bleiben beim Neuladen der Blacklist erhalten
+type Allowlist struct {
+	rules *domainSet
+	mu    sync.RWMutex
+}
+
+// NewAllowlist erstellt eine neue leere Allowlist
+func NewAllowlist() *Allowlist {
+	return &Allowlist{
+		rules: newDomainSet(),
+	}
+}
+
+// AddDomain fügt eine Domain zur Allowlist hinzu
+// Unterstützt Wildcards (z.B. "*.example.com" erlaubt auch alle Subdomains)
+func (a *Allowlist) AddDomain(domain string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.rules.add(domain)
+}
+
+// RemoveDomain entfernt eine Domain aus der Allowlist
+func (a *Allowlist) RemoveDomain(domain string) error {
+	if domain == "" {
+		return fmt.Errorf("domain cannot be empty")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.rules.remove(domain)
+	return nil
+}
+
+// IsAllowed prüft, ob eine Domain explizit erlaubt ist
+func (a *Allowlist) IsAllowed(domain string) bool {
+	if domain == "" {
+		return false
+	}
+
+	domain = normalizeDomain(domain)
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.rules.contains(domain)
+}
+
+// GetAllDomains gibt alle erlaubten Domains zurück (ohne Wildcards)
+func (a *Allowlist) GetAllDomains() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.rules.allDomains()
+}
+
+// GetAllWildcards gibt alle Wildcard-Einträge zurück (mit *. Präfix)
+func (a *Allowlist) GetAllWildcards() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.rules.allWildcards()
+}
+
+// Count gibt die Gesamtanzahl der Einträge zurück (Domains + Wildcards)
+func (a *Allowlist) Count() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.rules.count()
+}
+
+// Clear entfernt alle Einträge aus der Allowlist
+func (a *Allowlist) Clear() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.rules = newDomainSet()
+}
+
+// parseAllowlistLine parst eine Zeile einer Allowlist
+// Erlaubt sind einfache Domains ("example.com", "*.example.com") und hosts-Zeilen
+// ("0.0.0.0 a.com b.com"), damit dieselben Dateien wie für die Blacklist nutzbar sind.
+// hosts-Zeilen parst parseHostsLine der Blacklist, also mit allen Hostnamen einer Zeile.
+// Gibt keine Domains zurück bei Kommentaren und Leerzeilen
+func parseAllowlistLine(line string) ([]string, error) {
+	// Inline-Kommentare abschneiden
+	if idx := strings.IndexByte(line, '#'); idx >= 0 {
+		line = line[:idx]
+	}
+
+	fields := strings.Fields(line)
+	switch len(fields) {
+	case 0:
+		return nil, nil
+	case 1:
+		return fields, nil
+	default:
+		entry, err := parseHostsLine(line)
+		return entry.domains, err
+	}
+}
+
+// LoadFromContent lädt Domains aus einem Listen-Inhalt
+// Gibt die Anzahl der hinzugefügten Domains zurück
+func (a *Allowlist) LoadFromContent(content string) (int, error) {
+	return a.LoadFromReader(strings.NewReader(content))
+}
+
+// LoadFromReader liest eine Allowlist zeilenweise (auch gzip- oder zstd-komprimiert)
+// Die Einträge werden ohne Lock aufgebaut und erst am Ende übernommen. Ist die Liste zu groß
+// oder bricht das Lesen ab, wird nichts übernommen. Gültige Einträge werden sonst immer
+// übernommen, ungültige Zeilen fasst ein *LoadError zusammen.
+// Gibt die Anzahl der hinzugefügten Domains zurück
+func (a *Allowlist) LoadFromReader(r io.Reader) (int, error) {
+	rc, err := openListReader(r, DefaultMaxListSize)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	rules := newDomainSet()
+	added := 0
+	var loadErr LoadError
+	err = scanLines(rc, func(lineNo int, line string) {
+		domains, lineErr := parseAllowlistLine(line)
+		for _, domain := range domains {
+			if err := rules.add(domain); err != nil {
+				if lineErr == nil {
+					lineErr = err
+				}
+				continue
+			}
+			added++
+		}
+		if lineErr != nil {
+			loadErr.add(lineNo, line, lineErr)
+		}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read list: %w", err)
+	}
+
+	a.mu.Lock()
+	a.rules.merge(rules)
+	a.mu.Unlock()
+
+	return added, loadErr.errorOrNil()
+}
+
+// LoadFromURL lädt eine Allowlist von einer URL
+// Unterstützt HTTP und HTTPS URLs
+func (a *Allowlist) LoadFromURL(url string) (int, error) {
+	body, err := openURL(url)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	return a.LoadFromReader(body)
+}
+
+// LoadFromFile lädt eine Allowlist vom Dateisystem
+func (a *Allowlist) LoadFromFile(filepath string) (int, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	return a.LoadFromReader(file)
+}
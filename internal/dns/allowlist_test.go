@@ -0,0 +1,229 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNewAllowlist(t *testing.T) {
+	al := NewAllowlist()
+	if al == nil {
+		t.Fatal("NewAllowlist() returned nil")
+	}
+	if al.Count() != 0 {
+		t.Errorf("New allowlist should be empty, got count = %d", al.Count())
+	}
+}
+
+func TestAllowlist_IsAllowed(t *testing.T) {
+	al := NewAllowlist()
+	al.AddDomain("login.example.com")
+	al.AddDomain("*.cdn.example.net")
+
+	tests := []struct {
+		name    string
+		domain  string
+		allowed bool
+	}{
+		{name: "Exact match", domain: "login.example.com", allowed: true},
+		{name: "Exact - sibling not allowed", domain: "ads.example.com", allowed: false},
+		{name: "Exact - subdomain not allowed", domain: "a.login.example.com", allowed: false},
+		{name: "Wildcard - base domain", domain: "cdn.example.net", allowed: true},
+		{name: "Wildcard - subdomain", domain: "img.cdn.example.net", allowed: true},
+		{name: "Wildcard - partial label", domain: "notcdn.example.net", allowed: false},
+		{name: "Case insensitive", domain: "LOGIN.Example.COM", allowed: true},
+		{name: "Empty domain", domain: "", allowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := al.IsAllowed(tt.domain); got != tt.allowed {
+				t.Errorf("IsAllowed(%q) = %v, want %v", tt.domain, got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestAllowlist_AddAndRemove(t *testing.T) {
+	al := NewAllowlist()
+
+	if err := al.AddDomain(""); err == nil {
+		t.Error("AddDomain() with empty domain should return error")
+	}
+	if err := al.AddDomain("*."); err == nil {
+		t.Error("AddDomain() with invalid wildcard should return error")
+	}
+
+	al.AddDomain("a.com")
+	al.AddDomain("*.b.com")
+	if al.Count() != 2 {
+		t.Errorf("Count() = %d, want 2", al.Count())
+	}
+	if len(al.GetAllDomains()) != 1 || len(al.GetAllWildcards()) != 1 {
+		t.Errorf("GetAllDomains() = %v, GetAllWildcards() = %v", al.GetAllDomains(), al.GetAllWildcards())
+	}
+
+	al.RemoveDomain("*.b.com")
+	if al.IsAllowed("x.b.com") {
+		t.Error("Removed wildcard should no longer allow subdomains")
+	}
+	if err := al.RemoveDomain(""); err == nil {
+		t.Error("RemoveDomain() with empty domain should return error")
+	}
+
+	al.Clear()
+	if al.Count() != 0 {
+		t.Errorf("Count() after Clear() = %d, want 0", al.Count())
+	}
+}
+
+func TestParseAllowlistLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"example.com", []string{"example.com"}},
+		{"*.example.com", []string{"*.example.com"}},
+		{"  example.com  ", []string{"example.com"}},
+		{"0.0.0.0 example.com", []string{"example.com"}},
+		{"0.0.0.0 a.example.com b.example.com", []string{"a.example.com", "b.example.com"}},
+		{"example.com # needed for login", []string{"example.com"}},
+		{"# comment", nil},
+		{"", nil},
+		{"   ", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := parseAllowlistLine(tt.line)
+			if err != nil {
+				t.Fatalf("parseAllowlistLine(%q) unexpected error: %v", tt.line, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseAllowlistLine(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowlist_LoadFromContent(t *testing.T) {
+	al := NewAllowlist()
+
+	content := `# Allowlist
+s.youtube.com
+*.microsoft.com
+0.0.0.0 www.googleadservices.com # Shopping-Links
+`
+
+	added, err := al.LoadFromContent(content)
+	if err != nil {
+		t.Fatalf("LoadFromContent() unexpected error: %v", err)
+	}
+	if added != 3 {
+		t.Errorf("LoadFromContent() added %d, want 3", added)
+	}
+	for _, domain := range []string{"s.youtube.com", "login.microsoft.com", "www.googleadservices.com"} {
+		if !al.IsAllowed(domain) {
+			t.Errorf("%s should be allowed", domain)
+		}
+	}
+}
+
+func TestAllowlist_LoadFromContent_HostsLines(t *testing.T) {
+	al := NewAllowlist()
+
+	content := `0.0.0.0 a.example.com b.example.com
+127.0.0.1 localhost c.example.com bad_name!
+`
+
+	added, err := al.LoadFromContent(content)
+	if added != 3 {
+		t.Errorf("LoadFromContent() added %d, want 3", added)
+	}
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) || loadErr.Rejected != 1 {
+		t.Errorf("LoadFromContent() error = %v, want LoadError with 1 rejected line", err)
+	}
+	for _, domain := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+		if !al.IsAllowed(domain) {
+			t.Errorf("%s should be allowed", domain)
+		}
+	}
+	if al.IsAllowed("localhost") {
+		t.Error("standard hosts names should be skipped")
+	}
+}
+
+func TestAllowlist_LoadFromReader_ReadError(t *testing.T) {
+	al := NewAllowlist()
+	al.AddDomain("kept.example.com")
+
+	// Bricht das Lesen ab, darf keine halb geladene Liste übrig bleiben
+	r := io.MultiReader(
+		strings.NewReader("partial.example.com\n"),
+		iotest.ErrReader(errors.New("connection reset")),
+	)
+	added, err := al.LoadFromReader(r)
+	if err == nil {
+		t.Fatal("LoadFromReader() with read error should return error")
+	}
+	if added != 0 || al.IsAllowed("partial.example.com") {
+		t.Errorf("LoadFromReader() added %d, partial list should not be applied", added)
+	}
+	if !al.IsAllowed("kept.example.com") || al.Count() != 1 {
+		t.Errorf("existing entries should be kept, Count() = %d", al.Count())
+	}
+}
+
+func TestAllowlist_LoadFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowlist")
+	if err := os.WriteFile(path, []byte("allowed.example.com\n*.trusted.net\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+
+	al := NewAllowlist()
+	added, err := al.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadFromFile() unexpected error: %v", err)
+	}
+	if added != 2 || !al.IsAllowed("x.trusted.net") {
+		t.Errorf("LoadFromFile() added %d, x.trusted.net allowed = %v", added, al.IsAllowed("x.trusted.net"))
+	}
+
+	if _, err := al.LoadFromFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("LoadFromFile() with missing file should return error")
+	}
+}
+
+func TestAllowlist_LoadFromURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/allowlist.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, "allowed.example.com")
+	}))
+	defer server.Close()
+
+	al := NewAllowlist()
+	added, err := al.LoadFromURL(server.URL + "/allowlist.txt")
+	if err != nil {
+		t.Fatalf("LoadFromURL() unexpected error: %v", err)
+	}
+	if added != 1 || !al.IsAllowed("allowed.example.com") {
+		t.Errorf("LoadFromURL() added %d, want 1", added)
+	}
+
+	if _, err := al.LoadFromURL(server.URL + "/missing"); err == nil {
+		t.Error("LoadFromURL() with 404 should return error")
+	}
+}
@@ -4,53 +4,84 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/netip"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 )
 
 // Blacklist verwaltet blockierte Domains
+// Neben einfachen Block-Regeln kennt sie Ausnahmen und $important-/$dnstype-Regeln
+// aus Adblock-Listen. Regeln aus AddDomain und den LoadFrom-Methoden bilden die
+// manuellen Regeln, zusätzlich können benannte Listen verwaltet werden (siehe AddList).
 type Blacklist struct {
-	domains   map[string]bool
-	wildcards map[string]bool
-	mu        sync.RWMutex
+	rules       *ruleSet
+	lists       []*blockList     // benannte Listen in Reihenfolge des Hinzufügens
+	maxListSize int64            // maximale Größe einer Liste nach dem Entpacken
+	compact     bool             // Listen nach dem Laden kompaktieren, siehe SetCompact
+	now         func() time.Time // Uhr für Zeitpläne, in Tests austauschbar
+	mu          sync.RWMutex
 }
 
 // NewBlacklist erstellt eine neue leere Blacklist
 func NewBlacklist() *Blacklist {
 	return &Blacklist{
-		domains:   make(map[string]bool),
-		wildcards: make(map[string]bool),
+		rules:       newRuleSet(),
+		maxListSize: DefaultMaxListSize,
+		now:         time.Now,
 	}
 }
 
+// SetClock setzt die Uhr, gegen die Zeitpläne ausgewertet werden (Standard time.Now)
+func (b *Blacklist) SetClock(now func() time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now == nil {
+		now = time.Now
+	}
+	b.now = now
+}
+
 // AddDomain fügt eine Domain zur Blacklist hinzu
-// Unterstützt Wildcards (z.B. "*.ads.com")
+// Unterstützt Wildcards (z.B. "*.ads.com"), Globs mit "*" an beliebiger Stelle
+// (z.B. "*tracking*.example.*") und reguläre Ausdrücke in Schrägstrichen (z.B. "/^ad[0-9]+\./")
 func (b *Blacklist) AddDomain(domain string) error {
-	if domain == "" {
-		return fmt.Errorf("domain cannot be empty")
-	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.rules.add(domain, ruleBlock, dnsTypeFilter{})
+}
 
-	// Normalisiere Domain zu lowercase
-	domain = strings.ToLower(strings.TrimSpace(domain))
+// AddScheduledDomain fügt eine Domain hinzu, die nur während des Zeitplans blockiert wird
+// Unterstützt dieselben Schreibweisen wie AddDomain
+func (b *Blacklist) AddScheduledDomain(domain string, schedule Schedule) error {
+	if err := schedule.validate(); err != nil {
+		return err
+	}
 
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	// Prüfe ob es ein Wildcard ist (beginnt mit *.)
-	if strings.HasPrefix(domain, "*.") {
-		// Entferne *. und speichere als Wildcard
-		suffix := domain[2:]
-		if suffix == "" {
-			return fmt.Errorf("invalid wildcard domain: %s", domain)
-		}
-		b.wildcards[suffix] = true
-	} else {
-		b.domains[domain] = true
+	return b.rules.addScheduled(domain, ruleBlock, dnsTypeFilter{}, &schedule)
+}
+
+// AddAdblockRule fügt eine Regel im Adblock-Format hinzu (z.B. "||ads.com^", "@@||ok.ads.com^")
+func (b *Blacklist) AddAdblockRule(line string) error {
+	rule, ok, err := parseAdblockLine(line)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("not an adblock rule: %s", line)
 	}
 
-	return nil
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.rules.add(rule.domain, rule.kind, rule.filter)
 }
 
 // RemoveDomain entfernt eine Domain aus der Blacklist
@@ -59,186 +90,428 @@ func (b *Blacklist) RemoveDomain(domain string) error {
 		return fmt.Errorf("domain cannot be empty")
 	}
 
-	domain = strings.ToLower(strings.TrimSpace(domain))
-
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	// Prüfe ob es ein Wildcard ist
-	if strings.HasPrefix(domain, "*.") {
-		suffix := domain[2:]
-		delete(b.wildcards, suffix)
-	} else {
-		delete(b.domains, domain)
-	}
-
+	b.rules.remove(domain)
 	return nil
 }
 
 // IsBlocked prüft, ob eine Domain blockiert ist
 // Berücksichtigt exakte Matches und Wildcard-Regeln
+// Regeln mit $dnstype werden hier nicht ausgewertet, dafür gibt es IsBlockedType
+// Zeitpläne werden gegen die aktuelle Zeit der Uhr (SetClock) ausgewertet
+// Welche Liste und Regel getroffen hat, liefert Match
 func (b *Blacklist) IsBlocked(domain string) bool {
+	return b.IsBlockedType(domain, 0)
+}
+
+// IsBlockedType prüft, ob eine Domain für einen Query-Typ (z.B. dns.TypeAAAA) blockiert ist
+// Reihenfolge: wichtige Ausnahmen, $important, Ausnahmen (@@), normale Regeln
+func (b *Blacklist) IsBlockedType(domain string, qtype uint16) bool {
+	return b.Match(domain, qtype).Blocked
+}
+
+// AddLocalRecord fügt einen lokalen A/AAAA-Record hinzu, den der Proxy ohne Upstream beantwortet
+// Wie "192.168.1.10 nas.home" in einer hosts-Datei
+func (b *Blacklist) AddLocalRecord(domain string, ip string) error {
+	domain = normalizeDomain(domain)
+	if !isValidHostname(domain) {
+		return fmt.Errorf("invalid domain: %s", domain)
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rules.addLocal(domain, addr.Unmap().WithZone(""))
+	return nil
+}
+
+// LocalAddresses gibt die lokalen Records einer Domain zurück (IPv4 und IPv6)
+// Durchsucht die manuellen Regeln und alle aktivierten Listen, die erste Quelle mit Treffer gewinnt
+func (b *Blacklist) LocalAddresses(domain string) []string {
+	return b.LocalAddressesLists(domain, nil)
+}
+
+// LocalAddressesLists arbeitet wie LocalAddresses, durchsucht aber nur die aktivierten Listen,
+// deren Name oder Kategorie in lists steht (nil = alle), wie MatchLists für Client-Gruppen
+func (b *Blacklist) LocalAddressesLists(domain string, lists []string) []string {
 	if domain == "" {
-		return false
+		return nil
 	}
 
-	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = normalizeDomain(domain)
 
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	// Prüfe exakte Domain
-	if b.domains[domain] {
-		return true
+	if ips := b.rules.local[domain]; len(ips) > 0 {
+		return slices.Clone(ips)
 	}
-
-	// Prüfe Wildcards
-	// z.B. "ads.example.com" matched "*.example.com"
-	for suffix := range b.wildcards {
-		if strings.HasSuffix(domain, "."+suffix) || domain == suffix {
-			return true
+	for _, l := range b.lists {
+		if !l.info.Enabled || !l.selectedBy(lists) {
+			continue
+		}
+		if ips := l.rules.local[domain]; len(ips) > 0 {
+			return slices.Clone(ips)
 		}
 	}
-
-	return false
+	return nil
 }
 
-// GetAllDomains gibt alle blockierten Domains zurück (ohne Wildcards)
+// GetAllDomains gibt alle blockierten Domains der manuellen Regeln zurück (ohne Wildcards)
+// Enthält nur einfache Block-Regeln ohne Modifikatoren
 func (b *Blacklist) GetAllDomains() []string {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	domains := make([]string, 0, len(b.domains))
-	for domain := range b.domains {
-		domains = append(domains, domain)
-	}
-
-	return domains
+	return b.rules.defaultLayer().allDomains()
 }
 
-// GetAllWildcards gibt alle Wildcard-Regeln zurück (mit *. Präfix)
+// GetAllWildcards gibt alle Wildcard-Regeln der manuellen Regeln zurück (mit *. Präfix)
+// Enthält nur einfache Block-Regeln ohne Modifikatoren
 func (b *Blacklist) GetAllWildcards() []string {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	wildcards := make([]string, 0, len(b.wildcards))
-	for suffix := range b.wildcards {
-		wildcards = append(wildcards, "*."+suffix)
-	}
+	return b.rules.defaultLayer().allWildcards()
+}
 
-	return wildcards
+// GetAllPatterns gibt alle blockierenden Regex- ("/.../") und Glob-Regeln der manuellen Regeln zurück
+func (b *Blacklist) GetAllPatterns() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.rules.allPatterns()
 }
 
 // Count gibt die Gesamtanzahl der Einträge zurück (Domains + Wildcards)
+// Enthält die manuellen Regeln und alle benannten Listen, auch deaktivierte
 func (b *Blacklist) Count() int {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	return len(b.domains) + len(b.wildcards)
+	total := b.rules.count()
+	for _, l := range b.lists {
+		total += l.rules.count()
+	}
+	return total
 }
 
 // Clear entfernt alle Einträge aus der Blacklist
+// Benannte Listen bleiben mit Quelle und Status erhalten und können neu geladen werden
 func (b *Blacklist) Clear() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.domains = make(map[string]bool)
-	b.wildcards = make(map[string]bool)
+	b.rules = newRuleSet()
+	for _, l := range b.lists {
+		l.rules = newRuleSet()
+	}
 }
 
-// parseHostsLine parst eine Zeile im hosts-Format
-// Format: "0.0.0.0 domain.com" oder "127.0.0.1 domain.com"
-// Gibt die Domain zurück oder einen leeren String bei ungültigen Zeilen
-func parseHostsLine(line string) string {
-	// Entferne führende/nachfolgende Whitespace
-	line = strings.TrimSpace(line)
+// hostsEntry ist eine geparste Zeile einer hosts-Datei
+type hostsEntry struct {
+	domains []string
+	addr    netip.Addr
+	local   bool // true: lokale A/AAAA-Records, false: Block-Regeln
+}
 
-	// Ignoriere leere Zeilen
-	if line == "" {
-		return ""
-	}
+// standardHostsNames sind Namen, die jede hosts-Datei für das lokale System einträgt
+// Sie werden weder blockiert noch als lokale Records übernommen
+var standardHostsNames = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+	"0.0.0.0":               true,
+}
 
-	// Ignoriere Kommentare
-	if strings.HasPrefix(line, "#") {
-		return ""
+// parseHostsLine parst eine Zeile im hosts-Format
+// Format: "0.0.0.0 a.com b.com # Kommentar", "127.0.0.1 domain.com" oder "192.168.1.10 nas.home"
+// Alle Hostnamen einer Zeile werden übernommen, Inline-Kommentare abgeschnitten.
+// 0.0.0.0, :: und Loopback-Adressen ergeben Block-Regeln, routbare Adressen lokale Records.
+// Kommentare, Leerzeilen und Standard-Namen (localhost, broadcasthost, ...) liefern eine leere
+// Liste ohne Fehler. Ungültige IPs, nicht routbare Adressen (Broadcast, Multicast, Link-Local)
+// und Hostnamen, die nicht RFC 1123 entsprechen, liefern einen Fehler - die gültigen Hostnamen
+// einer Zeile sind dann trotzdem in entry enthalten.
+func parseHostsLine(line string) (entry hostsEntry, err error) {
+	// Inline-Kommentare abschneiden
+	if idx := strings.IndexByte(line, '#'); idx >= 0 {
+		line = line[:idx]
 	}
 
 	// Splitte nach Whitespace
 	parts := strings.Fields(line)
-	if len(parts) < 2 {
-		return ""
+	if len(parts) == 0 {
+		return hostsEntry{}, nil
+	}
+	if len(parts) == 1 {
+		return hostsEntry{}, fmt.Errorf("%w after %s", errMissingHostname, parts[0])
+	}
+
+	// Erste Spalte ist die IP, alle weiteren Spalten sind Hostnamen
+	addr, err := netip.ParseAddr(parts[0])
+	if err != nil {
+		return hostsEntry{}, fmt.Errorf("%w: %s", errInvalidAddress, parts[0])
 	}
 
-	// Erste Spalte sollte eine IP sein (ignorieren wir)
-	// Zweite Spalte ist die Domain
-	domain := parts[1]
+	var invalid []string
+	for _, name := range parts[1:] {
+		name = strings.ToLower(name)
+		if standardHostsNames[name] {
+			continue
+		}
+		if !isListDomain(name) {
+			invalid = append(invalid, name)
+			continue
+		}
+		entry.domains = append(entry.domains, strings.TrimSuffix(name, "."))
+	}
 
-	// Validiere Domain (mindestens einen Punkt enthalten)
-	if !strings.Contains(domain, ".") {
-		return ""
+	// Nur Standard-Namen
+	if len(entry.domains) == 0 && len(invalid) == 0 {
+		return hostsEntry{}, nil
 	}
 
-	return domain
+	entry.addr, entry.local, err = classifyListAddress(addr)
+	if err != nil {
+		return hostsEntry{}, err
+	}
+
+	if len(invalid) > 0 {
+		return entry, fmt.Errorf("%w: %s", errInvalidHostname, strings.Join(invalid, ", "))
+	}
+	return entry, nil
+}
+
+// isListDomain prüft einen Hostnamen aus einer Liste (RFC 1123, mindestens ein Punkt)
+// Einzelne Labels wie "router" gehören nicht in eine Blockliste
+func isListDomain(name string) bool {
+	return strings.Contains(strings.TrimSuffix(name, "."), ".") && isValidHostname(name)
+}
+
+// classifyListAddress ordnet die IP eines Listeneintrags ein
+// 0.0.0.0, :: und Loopback-Adressen ergeben Block-Regeln (local = false),
+// routbare Adressen lokale Records (local = true), alles andere ist ein Fehler
+func classifyListAddress(addr netip.Addr) (netip.Addr, bool, error) {
+	addr = addr.Unmap()
+	switch {
+	case addr.IsUnspecified() || addr.IsLoopback():
+		return addr, false, nil
+	case addr.IsGlobalUnicast():
+		return addr.WithZone(""), true, nil
+	default:
+		return netip.Addr{}, false, fmt.Errorf("%w: %s", errNonRoutable, addr)
+	}
 }
 
 // LoadFromHostsContent lädt Domains aus einem hosts-Datei-Inhalt
 // Format: Zeilen mit "0.0.0.0 domain.com" oder "127.0.0.1 domain.com"
+// Zeilen mit routbarer IP (z.B. "192.168.1.10 nas.home") werden zu lokalen Records,
+// die der Proxy selbst beantwortet (siehe LocalAddresses)
+// Gültige Einträge werden immer übernommen. Wurden Zeilen abgelehnt, fasst ein *LoadError
+// Anzahl und Gründe zusammen (ungültige IP, ungültiger Hostname, ...)
 func (b *Blacklist) LoadFromHostsContent(content string) (int, error) {
-	lines := strings.Split(content, "\n")
-	added := 0
-
-	for _, line := range lines {
-		domain := parseHostsLine(line)
-		if domain != "" {
-			err := b.AddDomain(domain)
-			if err != nil {
-				// Fehler beim Hinzufügen, aber weitermachen
-				continue
-			}
-			added++
+	return b.LoadFromReader(strings.NewReader(content), FormatHosts)
+}
+
+// LoadFromAdblockContent lädt Regeln aus einer Liste im Adblock-Plus-/AdGuard-Format
+// Browser-spezifische Regeln (Pfade, kosmetische Filter) werden übersprungen
+// Ungültige Regeln (z.B. fehlerhafte Regexe) werden nicht geladen, alle gültigen Regeln schon.
+// Der zurückgegebene *LoadError fasst die ungültigen Zeilen zusammen.
+func (b *Blacklist) LoadFromAdblockContent(content string) (int, error) {
+	return b.LoadFromReader(strings.NewReader(content), FormatAdblock)
+}
+
+// LoadFromContent lädt eine Liste im angegebenen Format
+// Bei FormatAuto wird das Format anhand des Inhalts erkannt
+func (b *Blacklist) LoadFromContent(content string, format ListFormat) (int, error) {
+	return b.LoadFromReader(strings.NewReader(content), format)
+}
+
+// LoadFromReader liest eine Liste zeilenweise, ohne sie komplett im Speicher zu halten
+// gzip- und zstd-komprimierte Listen werden automatisch entpackt. Die Regeln werden ohne
+// Lock aufgebaut und erst am Ende übernommen, Abfragen werden während des Ladens nicht blockiert.
+// Ist die Liste größer als SetMaxListSize erlaubt oder bricht das Lesen ab, wird nichts übernommen.
+func (b *Blacklist) LoadFromReader(r io.Reader, format ListFormat) (int, error) {
+	maxSize, compact := b.loadOptions()
+	rules, added, err := buildRules(r, format, maxSize)
+	if rules == nil {
+		return 0, err
+	}
+	if compact {
+		rules.compact()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rules.merge(rules)
+	return added, err
+}
+
+// SetMaxListSize setzt die maximale Größe einer Liste in Bytes (nach dem Entpacken)
+// Werte <= 0 setzen den Standard (DefaultMaxListSize)
+func (b *Blacklist) SetMaxListSize(size int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if size <= 0 {
+		size = DefaultMaxListSize
+	}
+	b.maxListSize = size
+}
+
+// SetCompact schaltet die speichersparende Darstellung für Domains und Wildcards ein oder aus
+// Eingeschaltet werden alle bereits geladenen Regeln sofort und jede später geladene Liste
+// nach dem Laden kompaktiert: statt 100+ Bytes in einer Map belegt eine Domain dann
+// typischerweise 10-20 Bytes, eine Abfrage kostet eine Binärsuche statt eines Map-Zugriffs.
+// Gedacht für Listen mit Millionen Einträgen auf Geräten mit wenig Speicher (z.B. Router).
+// Ausgeschaltet bleiben bereits kompaktierte Regeln bis zum nächsten Laden erhalten.
+// Regex- und Glob-Regeln sowie lokale Records sind nicht betroffen.
+func (b *Blacklist) SetCompact(enabled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.compact = enabled
+	if !enabled {
+		return
+	}
+	b.rules.compact()
+	for _, l := range b.lists {
+		l.rules.compact()
+	}
+}
+
+// loadOptions gibt die maximale Größe einer Liste und die Einstellung für compact zurück
+func (b *Blacklist) loadOptions() (maxSize int64, compact bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.maxListSize, b.compact
+}
+
+// parseHostsRules parst eine hosts-Zeile in Regeln, siehe parseHostsLine
+func parseHostsRules(line string) ([]listRule, error) {
+	entry, err := parseHostsLine(line)
+
+	rules := make([]listRule, 0, len(entry.domains))
+	for _, domain := range entry.domains {
+		if entry.local {
+			rules = append(rules, listRule{domain: domain, addr: entry.addr})
+		} else {
+			rules = append(rules, listRule{domain: domain, kind: ruleBlock})
 		}
 	}
+	return rules, err
+}
 
-	return added, nil
+// parseAdblockRules parst eine Adblock-Zeile in Regeln, siehe parseAdblockLine
+func parseAdblockRules(line string) ([]listRule, error) {
+	rule, ok, err := parseAdblockLine(line)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return []listRule{{domain: rule.domain, kind: rule.kind, filter: rule.filter}}, nil
 }
 
-// LoadFromURL lädt eine hosts-Datei von einer URL herunter und fügt die Domains zur Blacklist hinzu
-// Unterstützt HTTP und HTTPS URLs
-// Gibt die Anzahl der hinzugefügten Domains zurück
+// LoadFromURL lädt eine Liste von einer URL herunter und fügt die Regeln zur Blacklist hinzu
+// Unterstützt HTTP und HTTPS URLs, das Format wird automatisch erkannt (siehe ListFormat)
+// Gibt die Anzahl der hinzugefügten Regeln zurück
 func (b *Blacklist) LoadFromURL(url string) (int, error) {
+	body, err := openURL(url)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	return b.LoadFromReader(body, FormatAuto)
+}
+
+// openURL öffnet den Inhalt einer Liste per HTTP(S), der Aufrufer muss body schließen
+func openURL(url string) (io.ReadCloser, error) {
+	result, err := fetchConditional(url, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return result.body, nil
+}
+
+// fetchResult ist das Ergebnis eines bedingten Downloads
+// body wird beim Lesen gestreamt und muss vom Aufrufer geschlossen werden
+type fetchResult struct {
+	body         io.ReadCloser
+	etag         string
+	lastModified string
+	notModified  bool // der Server hat 304 geantwortet, body ist leer
+}
+
+// fetchConditional lädt eine Liste per HTTP(S) herunter
+// Mit etag bzw. lastModified aus einem früheren Download wird eine bedingte Anfrage
+// (If-None-Match / If-Modified-Since) gestellt, unveränderte Listen werden nicht erneut übertragen
+func fetchConditional(url, etag, lastModified string) (*fetchResult, error) {
 	// HTTP Client mit Timeout
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 	}
 
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
 	// GET Request
-	resp, err := client.Get(url)
+	resp, err := client.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("failed to fetch URL: %w", err)
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
 	}
-	defer resp.Body.Close()
 
-	// Status Code prüfen
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		return &fetchResult{etag: etag, lastModified: lastModified, notModified: true}, nil
 	}
 
-	// Body lesen
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read response body: %w", err)
+	// Status Code prüfen
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	// Content verarbeiten
-	return b.LoadFromHostsContent(string(body))
+	// Body wird nicht komplett gelesen, sondern beim Parsen gestreamt
+	return &fetchResult{
+		body:         resp.Body,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
 }
 
+// LoadFromFile lädt eine Liste vom Dateisystem
+// Das Format wird automatisch erkannt (siehe ListFormat)
 func (b *Blacklist) LoadFromFile(filepath string) (int, error) {
-	// Datei öffnen
-	bytes, err := os.ReadFile(filepath)
+	// Datei öffnen, der Inhalt wird zeilenweise gelesen
+	file, err := os.Open(filepath)
 	if err != nil {
 		return 0, fmt.Errorf("failed to read file: %w", err)
 	}
-	// Content verarbeiten
-	return b.LoadFromHostsContent(string(bytes))
+	defer file.Close()
+
+	return b.LoadFromReader(file, FormatAuto)
 }
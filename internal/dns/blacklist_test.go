@@ -1,6 +1,9 @@
 package dns
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -354,9 +357,12 @@ func TestBlacklist_DuplicateAddition(t *testing.T) {
 
 func TestParseHostsLine(t *testing.T) {
 	tests := []struct {
-		name string
-		line string
-		want string
+		name      string
+		line      string
+		want      string // Hostnamen, durch Leerzeichen getrennt
+		wantErr   error
+		wantLocal bool
+		wantAddr  string
 	}{
 		{
 			name: "Valid hosts line",
@@ -394,9 +400,10 @@ func TestParseHostsLine(t *testing.T) {
 			want: "",
 		},
 		{
-			name: "Line with only IP",
-			line: "0.0.0.0",
-			want: "",
+			name:    "Line with only IP",
+			line:    "0.0.0.0",
+			want:    "",
+			wantErr: errMissingHostname,
 		},
 		{
 			name: "Invalid domain (no dot)",
@@ -413,18 +420,197 @@ func TestParseHostsLine(t *testing.T) {
 			line: "::1 tracker.example.com",
 			want: "tracker.example.com",
 		},
+		{
+			name: "IPv6 unspecified",
+			line: ":: tracker.example.com",
+			want: "tracker.example.com",
+		},
+		{
+			name:      "Routable IPv4 is a local record",
+			line:      "192.168.1.10 NAS.home",
+			want:      "nas.home",
+			wantLocal: true,
+			wantAddr:  "192.168.1.10",
+		},
+		{
+			name:      "Routable IPv6 is a local record",
+			line:      "2001:db8::10 nas.home",
+			want:      "nas.home",
+			wantLocal: true,
+			wantAddr:  "2001:db8::10",
+		},
+		{
+			name:      "IPv4-mapped address",
+			line:      "::ffff:10.0.0.1 printer.lan",
+			want:      "printer.lan",
+			wantLocal: true,
+			wantAddr:  "10.0.0.1",
+		},
+		{
+			name: "Standard name with dot",
+			line: "127.0.0.1 localhost.localdomain",
+			want: "",
+		},
+		{
+			name: "Broadcast",
+			line: "255.255.255.255 broadcasthost",
+			want: "",
+		},
+		{
+			name:    "Multicast address",
+			line:    "ff02::1 all.nodes.example",
+			want:    "",
+			wantErr: errNonRoutable,
+		},
+		{
+			name:    "Link-local address",
+			line:    "fe80::1%lo0 router.example",
+			want:    "",
+			wantErr: errNonRoutable,
+		},
+		{
+			name: "Multiple hostnames",
+			line: "0.0.0.0 ads.example.com tracker.example.com\tpixel.example.com",
+			want: "ads.example.com tracker.example.com pixel.example.com",
+		},
+		{
+			name: "Inline comment without space",
+			line: "0.0.0.0 ads.example.com#tracker.example.com",
+			want: "ads.example.com",
+		},
+		{
+			name: "Standard names are skipped among others",
+			line: "127.0.0.1 localhost ads.example.com",
+			want: "ads.example.com",
+		},
+		{
+			name:    "Invalid hostnames are reported, valid ones kept",
+			line:    "0.0.0.0 ads.example.com bad_name.example.com -bad.example.com",
+			want:    "ads.example.com",
+			wantErr: errInvalidHostname,
+		},
+		{
+			name:    "Single label among hostnames",
+			line:    "0.0.0.0 router",
+			want:    "",
+			wantErr: errInvalidHostname,
+		},
+		{
+			name:    "Label too long",
+			line:    "0.0.0.0 " + strings.Repeat("a", 64) + ".com",
+			want:    "",
+			wantErr: errInvalidHostname,
+		},
+		{
+			name:    "First column is no IP",
+			line:    "invalid line.example",
+			want:    "",
+			wantErr: errInvalidAddress,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := parseHostsLine(tt.line)
-			if got != tt.want {
-				t.Errorf("parseHostsLine(%q) = %q, want %q", tt.line, got, tt.want)
+			entry, err := parseHostsLine(tt.line)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("parseHostsLine(%q) error = %v, want %v", tt.line, err, tt.wantErr)
+			}
+			if got := strings.Join(entry.domains, " "); got != tt.want {
+				t.Errorf("parseHostsLine(%q) domains = %q, want %q", tt.line, got, tt.want)
+			}
+			if entry.local != tt.wantLocal {
+				t.Errorf("parseHostsLine(%q) local = %v, want %v", tt.line, entry.local, tt.wantLocal)
+			}
+			if tt.wantLocal && entry.addr.String() != tt.wantAddr {
+				t.Errorf("parseHostsLine(%q) addr = %s, want %s", tt.line, entry.addr, tt.wantAddr)
 			}
 		})
 	}
 }
 
+func TestBlacklist_LoadFromHostsContent_LocalRecords(t *testing.T) {
+	bl := NewBlacklist()
+
+	content := `127.0.0.1 localhost
+127.0.0.1 localhost.localdomain
+255.255.255.255 broadcasthost
+::1 ip6-localhost
+fe80::1%lo0 localhost
+ff02::1 ip6-allnodes
+0.0.0.0 0.0.0.0
+192.168.1.10 nas.home
+2001:db8::10 nas.home
+0.0.0.0 ads.example.com
+`
+
+	added, err := bl.LoadFromHostsContent(content)
+	if err != nil {
+		t.Fatalf("LoadFromHostsContent() error = %v", err)
+	}
+	if added != 3 {
+		t.Errorf("LoadFromHostsContent() added = %d, want 3", added)
+	}
+
+	for _, name := range []string{"localhost", "localhost.localdomain", "broadcasthost", "ip6-allnodes", "0.0.0.0", "nas.home"} {
+		if bl.IsBlocked(name) {
+			t.Errorf("%s should not be blocked", name)
+		}
+	}
+	if !bl.IsBlocked("ads.example.com") {
+		t.Error("ads.example.com should be blocked")
+	}
+
+	ips := bl.LocalAddresses("NAS.home")
+	if len(ips) != 2 || ips[0] != "192.168.1.10" || ips[1] != "2001:db8::10" {
+		t.Errorf("LocalAddresses() = %v", ips)
+	}
+
+	bl.RemoveDomain("nas.home")
+	if len(bl.LocalAddresses("nas.home")) != 0 {
+		t.Error("RemoveDomain() should remove local records")
+	}
+}
+
+func TestBlacklist_AddLocalRecord(t *testing.T) {
+	bl := NewBlacklist()
+
+	if err := bl.AddLocalRecord("router.lan", "10.0.0.1"); err != nil {
+		t.Fatalf("AddLocalRecord() error = %v", err)
+	}
+	bl.AddLocalRecord("router.lan", "10.0.0.1")
+	if err := bl.AddLocalRecord("router.lan", "not-an-ip"); err == nil {
+		t.Error("AddLocalRecord() with invalid IP should return error")
+	}
+	if err := bl.AddLocalRecord("-bad-", "10.0.0.1"); err == nil {
+		t.Error("AddLocalRecord() with invalid domain should return error")
+	}
+	if ips := bl.LocalAddresses("router.lan"); len(ips) != 1 {
+		t.Errorf("LocalAddresses() = %v, want one IP", ips)
+	}
+
+	// Lokale Records deaktivierter Listen werden nicht beantwortet
+	bl.AddList(ListInfo{Name: "lan", Enabled: false})
+	bl.LoadListContent("lan", "192.168.1.20 tv.lan")
+	if ips := bl.LocalAddresses("tv.lan"); len(ips) != 0 {
+		t.Errorf("LocalAddresses() from disabled list = %v", ips)
+	}
+	bl.SetListEnabled("lan", true)
+	if ips := bl.LocalAddresses("tv.lan"); len(ips) != 1 {
+		t.Errorf("LocalAddresses() from enabled list = %v", ips)
+	}
+
+	// Nur ausgewählte Listen liefern lokale Records, manuelle Records gelten immer
+	if ips := bl.LocalAddressesLists("tv.lan", []string{"ads"}); len(ips) != 0 {
+		t.Errorf("LocalAddressesLists() from deselected list = %v", ips)
+	}
+	if ips := bl.LocalAddressesLists("tv.lan", []string{"lan"}); len(ips) != 1 {
+		t.Errorf("LocalAddressesLists() from selected list = %v", ips)
+	}
+	if ips := bl.LocalAddressesLists("router.lan", []string{}); len(ips) != 1 {
+		t.Errorf("LocalAddressesLists() for manual record = %v", ips)
+	}
+}
+
 func TestBlacklist_LoadFromHostsContent(t *testing.T) {
 	bl := NewBlacklist()
 
@@ -449,8 +635,24 @@ invalid line
 `
 
 	added, err := bl.LoadFromHostsContent(content)
-	if err != nil {
-		t.Errorf("LoadFromHostsContent() unexpected error: %v", err)
+
+	// Die beiden ungültigen Zeilen werden gemeldet, localhost wird still übersprungen
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("LoadFromHostsContent() error = %v, want *LoadError", err)
+	}
+	if loadErr.Rejected != 2 {
+		t.Errorf("LoadError.Rejected = %d, want 2", loadErr.Rejected)
+	}
+	if loadErr.Reasons["missing hostname"] != 1 || loadErr.Reasons["invalid IP address"] != 1 {
+		t.Errorf("LoadError.Reasons = %v", loadErr.Reasons)
+	}
+	var lineErr *LineError
+	if !errors.As(err, &lineErr) || lineErr.Line != 13 {
+		t.Errorf("first LineError = %v, want line 13", lineErr)
+	}
+	if !strings.Contains(err.Error(), "2 lines rejected") {
+		t.Errorf("LoadError.Error() = %q", err.Error())
 	}
 
 	// Sollte 6 valide Domains hinzugefügt haben
@@ -479,6 +681,35 @@ invalid line
 	}
 }
 
+func TestBlacklist_LoadFromHostsContent_MultipleHostnames(t *testing.T) {
+	bl := NewBlacklist()
+
+	content := `0.0.0.0 ads.example.com tracker.example.com # both blocked
+192.168.1.10 nas.home nas.lan
+0.0.0.0 good.example.com bad_name.example.com
+`
+
+	added, err := bl.LoadFromHostsContent(content)
+	if !errors.Is(err, errInvalidHostname) {
+		t.Errorf("LoadFromHostsContent() error = %v, want invalid hostname", err)
+	}
+	if added != 5 {
+		t.Errorf("LoadFromHostsContent() added = %d, want 5", added)
+	}
+
+	for _, domain := range []string{"ads.example.com", "tracker.example.com", "good.example.com"} {
+		if !bl.IsBlocked(domain) {
+			t.Errorf("%s should be blocked", domain)
+		}
+	}
+	if bl.IsBlocked("both") || bl.IsBlocked("blocked") {
+		t.Error("inline comment should not be parsed as hostnames")
+	}
+	if len(bl.LocalAddresses("nas.lan")) != 1 {
+		t.Error("nas.lan should be a local record")
+	}
+}
+
 func TestBlacklist_LoadFromHostsContent_Empty(t *testing.T) {
 	bl := NewBlacklist()
 
@@ -524,3 +755,105 @@ func TestBlacklist_LoadFromHostsContent_Duplicates(t *testing.T) {
 		t.Errorf("LoadFromHostsContent() added %d, want 4", added)
 	}
 }
+
+func TestFindWildcard(t *testing.T) {
+	wildcards := map[string]bool{
+		"example.com":  true,
+		"ads.test.org": true,
+	}
+
+	tests := []struct {
+		domain string
+		want   string // getroffener Suffix, "" = kein Treffer
+	}{
+		{"example.com", "example.com"},
+		{"a.example.com", "example.com"},
+		{"a.b.c.example.com", "example.com"},
+		{"notexample.com", ""},
+		{"example.com.evil.net", ""},
+		{"test.org", ""},
+		{"ads.test.org", "ads.test.org"},
+		{"x.ads.test.org", "ads.test.org"},
+		{"com", ""},
+		{"", ""},
+	}
+
+	set := newDomainSet()
+	for suffix := range wildcards {
+		set.add("*." + suffix)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			got, ok := findWildcard(wildcards, tt.domain)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("findWildcard(%q) = %q, %v, want %q", tt.domain, got, ok, tt.want)
+			}
+
+			// domainSet.match nutzt findWildcard für die Wildcard-Einträge
+			entry, ok := set.match(tt.domain)
+			if tt.want == "" {
+				if ok {
+					t.Errorf("match(%q) = %q, want no match", tt.domain, entry)
+				}
+			} else if entry != "*."+tt.want {
+				t.Errorf("match(%q) = %q, want %q", tt.domain, entry, "*."+tt.want)
+			}
+		})
+	}
+}
+
+// matchWildcardLinear ist das frühere Verfahren (alle Wildcards per HasSuffix prüfen)
+// Dient als Vergleich in den Benchmarks
+func matchWildcardLinear(wildcards map[string]bool, domain string) bool {
+	for suffix := range wildcards {
+		if strings.HasSuffix(domain, "."+suffix) || domain == suffix {
+			return true
+		}
+	}
+	return false
+}
+
+// benchmarkWildcards erzeugt n Wildcard-Regeln der Form "trackerN.example.net"
+func benchmarkWildcards(n int) map[string]bool {
+	wildcards := make(map[string]bool, n)
+	for i := 0; i < n; i++ {
+		wildcards[fmt.Sprintf("tracker%d.example.net", i)] = true
+	}
+	return wildcards
+}
+
+func BenchmarkMatchWildcard_LabelWalk(b *testing.B) {
+	for _, n := range []int{100, 10000, 50000} {
+		wildcards := benchmarkWildcards(n)
+		b.Run(fmt.Sprintf("rules=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				findWildcard(wildcards, "cdn.images.unlisted.example.org")
+			}
+		})
+	}
+}
+
+func BenchmarkMatchWildcard_Linear(b *testing.B) {
+	for _, n := range []int{100, 10000, 50000} {
+		wildcards := benchmarkWildcards(n)
+		b.Run(fmt.Sprintf("rules=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				matchWildcardLinear(wildcards, "cdn.images.unlisted.example.org")
+			}
+		})
+	}
+}
+
+func BenchmarkBlacklist_IsBlocked_Wildcards(b *testing.B) {
+	bl := NewBlacklist()
+	for i := 0; i < 50000; i++ {
+		bl.AddDomain(fmt.Sprintf("*.tracker%d.example.net", i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bl.IsBlocked("a.b.tracker4711.example.net")
+		bl.IsBlocked("cdn.images.unlisted.example.org")
+	}
+}
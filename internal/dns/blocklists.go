@@ -0,0 +1,491 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ListInfo beschreibt eine benannte Blockliste
+type ListInfo struct {
+	Name     string     // eindeutiger Name, z.B. "stevenblack"
+	Source   string     // URL (http/https) oder Dateipfad, leer bei Listen aus LoadListContent
+	Category string     // z.B. "ads", "tracking", "social"
+	Enabled  bool       // deaktivierte Listen bleiben geladen, blockieren aber nicht
+	Format   ListFormat // FormatAuto erkennt das Format beim Laden
+	Count    int        // Anzahl der Regeln, wird nur von GetLists und GetList gefüllt
+	Updated  time.Time  // Zeitpunkt, an dem die aktuellen Regeln geladen wurden
+
+	// Block bestimmt die Antwort auf Domains dieser Liste, BlockModeDefault übernimmt
+	// die globale Einstellung des Proxys
+	Block BlockResponse
+
+	// Schedule beschränkt die Liste auf Wochentage und Uhrzeiten, nil = immer aktiv
+	Schedule *Schedule
+}
+
+// MatchResult beschreibt, welche Regel eine Domain getroffen hat
+type MatchResult struct {
+	Blocked   bool
+	List      string // Name der Liste, leer bei manuellen Regeln
+	Category  string
+	Rule      string // getroffene Regel, z.B. "ads.com", "*.ads.com" oder "/^ad[0-9]+\./"
+	Exception bool   // die Regel ist eine Ausnahme (@@), die Domain wird nicht blockiert
+
+	Block BlockResponse // Block-Antwort der Liste, BlockModeDefault bei manuellen Regeln
+}
+
+// blockList ist eine benannte Liste mit eigenem Regelsatz
+type blockList struct {
+	info  ListInfo
+	rules *ruleSet
+
+	// Validatoren für bedingte Downloads (URL) bzw. Änderungszeit (Datei)
+	etag         string
+	lastModified string
+	modTime      time.Time
+
+	loadMu sync.Mutex // serialisiert das Laden derselben Liste
+}
+
+// sourceState sind die Werte einer Liste, die für das Laden aus der Quelle gebraucht werden
+type sourceState struct {
+	source       string
+	format       ListFormat
+	etag         string
+	lastModified string
+	modTime      time.Time
+	maxSize      int64
+	compact      bool
+}
+
+// AddList legt eine neue, leere benannte Liste an
+// Die Regeln werden mit LoadList (aus Source) oder LoadListContent geladen
+func (b *Blacklist) AddList(info ListInfo) error {
+	if info.Name == "" {
+		return fmt.Errorf("list name cannot be empty")
+	}
+	if err := info.Block.validate(); err != nil {
+		return fmt.Errorf("invalid block response for list '%s': %w", info.Name, err)
+	}
+	if info.Schedule != nil {
+		if err := info.Schedule.validate(); err != nil {
+			return fmt.Errorf("invalid schedule for list '%s': %w", info.Name, err)
+		}
+		schedule := *info.Schedule
+		info.Schedule = &schedule
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.findList(info.Name) != nil {
+		return fmt.Errorf("list with name '%s' already exists", info.Name)
+	}
+
+	info.Count = 0
+	b.lists = append(b.lists, &blockList{info: info, rules: newRuleSet()})
+	return nil
+}
+
+// RemoveList entfernt eine benannte Liste mit allen Regeln
+func (b *Blacklist) RemoveList(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, l := range b.lists {
+		if l.info.Name == name {
+			b.lists = append(b.lists[:i], b.lists[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("list with name '%s' not found", name)
+}
+
+// SetListEnabled aktiviert oder deaktiviert eine Liste zur Laufzeit
+func (b *Blacklist) SetListEnabled(name string, enabled bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	l := b.findList(name)
+	if l == nil {
+		return fmt.Errorf("list with name '%s' not found", name)
+	}
+	l.info.Enabled = enabled
+	return nil
+}
+
+// SetListBlockResponse setzt die Antwort auf blockierte Domains einer Liste
+// BlockResponse{} übernimmt wieder die globale Einstellung des Proxys
+func (b *Blacklist) SetListBlockResponse(name string, resp BlockResponse) error {
+	if err := resp.validate(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	l := b.findList(name)
+	if l == nil {
+		return fmt.Errorf("list with name '%s' not found", name)
+	}
+	l.info.Block = resp
+	return nil
+}
+
+// SetListSchedule beschränkt eine Liste auf einen Zeitplan, nil macht sie wieder immer aktiv
+// Außerhalb des Zeitplans blockiert die Liste nicht, bleibt aber geladen und aktiviert
+func (b *Blacklist) SetListSchedule(name string, schedule *Schedule) error {
+	if schedule != nil {
+		if err := schedule.validate(); err != nil {
+			return err
+		}
+		copied := *schedule
+		schedule = &copied
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	l := b.findList(name)
+	if l == nil {
+		return fmt.Errorf("list with name '%s' not found", name)
+	}
+	l.info.Schedule = schedule
+	return nil
+}
+
+// SetCategorySchedule setzt den Zeitplan aller Listen einer Kategorie, siehe SetListSchedule
+// Gibt die Anzahl der betroffenen Listen zurück
+func (b *Blacklist) SetCategorySchedule(category string, schedule *Schedule) (int, error) {
+	if schedule != nil {
+		if err := schedule.validate(); err != nil {
+			return 0, err
+		}
+		copied := *schedule
+		schedule = &copied
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	changed := 0
+	for _, l := range b.lists {
+		if l.info.Category == category {
+			l.info.Schedule = schedule
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// SetCategoryEnabled aktiviert oder deaktiviert alle Listen einer Kategorie
+// Gibt die Anzahl der betroffenen Listen zurück
+func (b *Blacklist) SetCategoryEnabled(category string, enabled bool) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	changed := 0
+	for _, l := range b.lists {
+		if l.info.Category == category {
+			l.info.Enabled = enabled
+			changed++
+		}
+	}
+	return changed
+}
+
+// GetList gibt die Beschreibung einer Liste zurück
+func (b *Blacklist) GetList(name string) (ListInfo, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	l := b.findList(name)
+	if l == nil {
+		return ListInfo{}, false
+	}
+	return l.snapshot(), true
+}
+
+// GetLists gibt alle benannten Listen in Reihenfolge des Hinzufügens zurück
+func (b *Blacklist) GetLists() []ListInfo {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	infos := make([]ListInfo, 0, len(b.lists))
+	for _, l := range b.lists {
+		infos = append(infos, l.snapshot())
+	}
+	return infos
+}
+
+// LoadList lädt die Regeln einer Liste aus ihrer Quelle (URL oder Datei)
+// Der neue Regelsatz wird vollständig aufgebaut und ersetzt dann den alten,
+// Abfragen sehen nie eine halb geladene Liste. Schlägt der Download fehl, bleiben
+// die bisherigen Regeln aktiv.
+func (b *Blacklist) LoadList(name string) (int, error) {
+	l, err := b.getList(name)
+	if err != nil {
+		return 0, err
+	}
+
+	_, added, err := b.loadFromSource(l, false)
+	return added, err
+}
+
+// RefreshList lädt eine Liste nur neu, wenn sich die Quelle geändert hat
+// URLs werden mit If-None-Match / If-Modified-Since abgefragt, bei Dateien zählt die Änderungszeit
+// updated ist false, wenn die Quelle unverändert war und die Regeln nicht getauscht wurden
+func (b *Blacklist) RefreshList(name string) (updated bool, err error) {
+	l, err := b.getList(name)
+	if err != nil {
+		return false, err
+	}
+
+	updated, _, err = b.loadFromSource(l, true)
+	return updated, err
+}
+
+// RefreshResult ist das Ergebnis der Aktualisierung einer Liste
+type RefreshResult struct {
+	Name    string
+	Updated bool
+	Err     error
+}
+
+// RefreshLists aktualisiert alle Listen mit Quelle nacheinander, siehe RefreshList
+// Auch deaktivierte Listen werden aktualisiert, damit sie beim Einschalten aktuell sind
+func (b *Blacklist) RefreshLists() []RefreshResult {
+	b.mu.RLock()
+	var lists []*blockList
+	for _, l := range b.lists {
+		if l.info.Source != "" {
+			lists = append(lists, l)
+		}
+	}
+	b.mu.RUnlock()
+
+	results := make([]RefreshResult, 0, len(lists))
+	for _, l := range lists {
+		updated, _, err := b.loadFromSource(l, true)
+		results = append(results, RefreshResult{Name: l.info.Name, Updated: updated, Err: err})
+	}
+	return results
+}
+
+// LoadListContent ersetzt die Regeln einer Liste durch den übergebenen Inhalt
+// Wie bei LoadFromAdblockContent werden gültige Regeln auch dann übernommen,
+// wenn einzelne Zeilen fehlerhaft sind
+func (b *Blacklist) LoadListContent(name string, content string) (int, error) {
+	l, err := b.getList(name)
+	if err != nil {
+		return 0, err
+	}
+
+	l.loadMu.Lock()
+	defer l.loadMu.Unlock()
+
+	state := b.sourceState(l)
+	rules, added, err := buildRules(strings.NewReader(content), state.format, state.maxSize)
+	if rules == nil {
+		return 0, err
+	}
+	if state.compact {
+		rules.compact()
+	}
+
+	// Validatoren zurücksetzen, die nächste Aktualisierung lädt die Quelle vollständig
+	b.swapRules(l, rules, sourceState{})
+	return added, err
+}
+
+// loadFromSource lädt eine Liste aus ihrer Quelle und tauscht den Regelsatz aus
+// Mit conditional werden unveränderte Quellen übersprungen (updated = false)
+func (b *Blacklist) loadFromSource(l *blockList, conditional bool) (updated bool, added int, err error) {
+	l.loadMu.Lock()
+	defer l.loadMu.Unlock()
+
+	state := b.sourceState(l)
+	if state.source == "" {
+		return false, 0, fmt.Errorf("list '%s' has no source", l.info.Name)
+	}
+	if !conditional {
+		state.etag, state.lastModified, state.modTime = "", "", time.Time{}
+	}
+
+	body, next, changed, err := readSource(state)
+	if err != nil || !changed {
+		return false, 0, err
+	}
+	defer body.Close()
+
+	// Neuen Regelsatz ohne Lock aufbauen, nur der Austausch ist geschützt
+	rules, added, err := buildRules(body, state.format, state.maxSize)
+	if rules == nil {
+		return false, 0, err
+	}
+	if state.compact {
+		rules.compact()
+	}
+
+	b.swapRules(l, rules, next)
+	return true, added, err
+}
+
+// buildRules baut einen neuen Regelsatz aus einer Liste
+// Gibt nil zurück, wenn keine einzige Regel gültig war und ein Fehler vorliegt oder
+// die Liste nicht vollständig gelesen werden konnte (Lesefehler, ErrListTooLarge)
+func buildRules(r io.Reader, format ListFormat, maxSize int64) (*ruleSet, int, error) {
+	rules := newRuleSet()
+	added, err := rules.load(r, format, maxSize)
+
+	var loadErr *LoadError
+	if err != nil && (added == 0 || !errors.As(err, &loadErr)) {
+		return nil, 0, err
+	}
+	return rules, added, err
+}
+
+// swapRules ersetzt den Regelsatz einer Liste und speichert die Validatoren der Quelle
+func (b *Blacklist) swapRules(l *blockList, rules *ruleSet, state sourceState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	l.rules = rules
+	l.etag = state.etag
+	l.lastModified = state.lastModified
+	l.modTime = state.modTime
+	l.info.Updated = time.Now()
+}
+
+// sourceState liest Quelle, Format und Validatoren einer Liste unter dem Lock
+func (b *Blacklist) sourceState(l *blockList) sourceState {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return sourceState{
+		source:       l.info.Source,
+		format:       l.info.Format,
+		etag:         l.etag,
+		lastModified: l.lastModified,
+		modTime:      l.modTime,
+		maxSize:      b.maxListSize,
+		compact:      b.compact,
+	}
+}
+
+// getList sucht eine Liste anhand des Namens
+func (b *Blacklist) getList(name string) (*blockList, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	l := b.findList(name)
+	if l == nil {
+		return nil, fmt.Errorf("list with name '%s' not found", name)
+	}
+	return l, nil
+}
+
+// Match prüft eine Domain gegen die manuellen Regeln und alle aktivierten Listen
+// Die Arten werden über alle Listen hinweg in Prioritätsreihenfolge ausgewertet:
+// eine Ausnahme in Liste A hebt eine Block-Regel in Liste B auf, $important gilt übergreifend
+// qtype 0 wertet nur Regeln ohne $dnstype aus
+// Listen und Regeln mit Zeitplan gelten nur, wenn der Zeitplan zur aktuellen Zeit aktiv ist
+func (b *Blacklist) Match(domain string, qtype uint16) MatchResult {
+	return b.MatchLists(domain, qtype, nil)
+}
+
+// MatchLists arbeitet wie Match, wertet aber nur die aktivierten Listen aus, deren Name oder
+// Kategorie in lists steht (nil = alle aktivierten Listen). Manuelle Regeln gelten immer.
+// Client-Gruppen wählen so ihre Listen aus, ohne sie mehrfach zu laden.
+func (b *Blacklist) MatchLists(domain string, qtype uint16, lists []string) MatchResult {
+	if domain == "" {
+		return MatchResult{}
+	}
+
+	domain = normalizeDomain(domain)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	now := b.now()
+	for _, kind := range rulePriority {
+		if rule, ok := b.rules.match(kind, domain, qtype, now); ok {
+			return MatchResult{Blocked: kind.blocks(), Rule: rule, Exception: !kind.blocks()}
+		}
+		for _, l := range b.lists {
+			if !l.info.Enabled || !l.info.Schedule.Active(now) || !l.selectedBy(lists) {
+				continue
+			}
+			if rule, ok := l.rules.match(kind, domain, qtype, now); ok {
+				return MatchResult{
+					Blocked:   kind.blocks(),
+					List:      l.info.Name,
+					Category:  l.info.Category,
+					Rule:      rule,
+					Exception: !kind.blocks(),
+					Block:     l.info.Block,
+				}
+			}
+		}
+	}
+
+	return MatchResult{}
+}
+
+// findList sucht eine Liste anhand des Namens, der Aufrufer hält den Lock
+func (b *Blacklist) findList(name string) *blockList {
+	for _, l := range b.lists {
+		if l.info.Name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// selectedBy prüft, ob Name oder Kategorie der Liste ausgewählt sind (nil wählt alle aus)
+func (l *blockList) selectedBy(lists []string) bool {
+	return lists == nil || slices.Contains(lists, l.info.Name) ||
+		(l.info.Category != "" && slices.Contains(lists, l.info.Category))
+}
+
+// snapshot gibt eine Kopie der Beschreibung mit aktueller Regelanzahl zurück
+func (l *blockList) snapshot() ListInfo {
+	info := l.info
+	info.Count = l.rules.count()
+	return info
+}
+
+// readSource öffnet eine Quelle per HTTP(S) oder vom Dateisystem, body muss geschlossen werden
+// Sind Validatoren gesetzt und die Quelle unverändert, ist changed false (body ist dann nil)
+// next enthält die Validatoren für die nächste Aktualisierung
+func readSource(state sourceState) (body io.ReadCloser, next sourceState, changed bool, err error) {
+	if strings.HasPrefix(state.source, "http://") || strings.HasPrefix(state.source, "https://") {
+		result, err := fetchConditional(state.source, state.etag, state.lastModified)
+		if err != nil || result.notModified {
+			return nil, state, false, err
+		}
+		return result.body, sourceState{etag: result.etag, lastModified: result.lastModified}, true, nil
+	}
+
+	file, err := os.Open(state.source)
+	if err != nil {
+		return nil, state, false, fmt.Errorf("failed to read file: %w", err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, state, false, fmt.Errorf("failed to read file: %w", err)
+	}
+	if !state.modTime.IsZero() && stat.ModTime().Equal(state.modTime) {
+		file.Close()
+		return nil, state, false, nil
+	}
+
+	return file, sourceState{modTime: stat.ModTime()}, true, nil
+}
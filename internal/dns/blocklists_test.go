@@ -0,0 +1,179 @@
+package dns
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestBlacklist_AddList(t *testing.T) {
+	bl := NewBlacklist()
+
+	if err := bl.AddList(ListInfo{Name: "ads", Category: "ads", Enabled: true}); err != nil {
+		t.Fatalf("AddList() error = %v", err)
+	}
+	if err := bl.AddList(ListInfo{Name: "ads"}); err == nil {
+		t.Error("AddList() with duplicate name should return error")
+	}
+	if err := bl.AddList(ListInfo{}); err == nil {
+		t.Error("AddList() with empty name should return error")
+	}
+	if err := bl.RemoveList("missing"); err == nil {
+		t.Error("RemoveList() for unknown list should return error")
+	}
+	if err := bl.SetListEnabled("missing", true); err == nil {
+		t.Error("SetListEnabled() for unknown list should return error")
+	}
+	if _, err := bl.LoadListContent("missing", "0.0.0.0 a.com"); err == nil {
+		t.Error("LoadListContent() for unknown list should return error")
+	}
+	if _, err := bl.LoadList("ads"); err == nil {
+		t.Error("LoadList() without source should return error")
+	}
+
+	if err := bl.RemoveList("ads"); err != nil {
+		t.Errorf("RemoveList() error = %v", err)
+	}
+	if len(bl.GetLists()) != 0 {
+		t.Errorf("GetLists() = %v, want empty", bl.GetLists())
+	}
+}
+
+func TestBlacklist_Match(t *testing.T) {
+	bl := NewBlacklist()
+	bl.AddDomain("manual.example.com")
+	bl.AddList(ListInfo{Name: "stevenblack", Category: "ads", Enabled: true})
+	bl.AddList(ListInfo{Name: "social", Category: "social", Enabled: true})
+	bl.AddList(ListInfo{Name: "fixes", Category: "allow", Enabled: true, Format: FormatAdblock})
+
+	if _, err := bl.LoadListContent("stevenblack", "0.0.0.0 ads.example.com\n0.0.0.0 cdn.example.net\n"); err != nil {
+		t.Fatalf("LoadListContent() error = %v", err)
+	}
+	if _, err := bl.LoadListContent("social", "||facebook.com^\n||instagram.com^\n"); err != nil {
+		t.Fatalf("LoadListContent() error = %v", err)
+	}
+	if _, err := bl.LoadListContent("fixes", "@@||cdn.example.net^\n"); err != nil {
+		t.Fatalf("LoadListContent() error = %v", err)
+	}
+
+	tests := []struct {
+		domain string
+		want   MatchResult
+	}{
+		{domain: "manual.example.com", want: MatchResult{Blocked: true, Rule: "manual.example.com"}},
+		{domain: "ADS.example.com", want: MatchResult{Blocked: true, List: "stevenblack", Category: "ads", Rule: "ads.example.com"}},
+		{domain: "www.facebook.com", want: MatchResult{Blocked: true, List: "social", Category: "social", Rule: "*.facebook.com"}},
+		// Ausnahmen gelten listenübergreifend
+		{domain: "cdn.example.net", want: MatchResult{List: "fixes", Category: "allow", Rule: "*.cdn.example.net", Exception: true}},
+		{domain: "example.org", want: MatchResult{}},
+		{domain: "", want: MatchResult{}},
+	}
+
+	for _, tt := range tests {
+		if got := bl.Match(tt.domain, 0); got != tt.want {
+			t.Errorf("Match(%q) = %+v, want %+v", tt.domain, got, tt.want)
+		}
+	}
+	if bl.Count() != 6 {
+		t.Errorf("Count() = %d, want 6", bl.Count())
+	}
+}
+
+func TestBlacklist_ListToggles(t *testing.T) {
+	bl := NewBlacklist()
+	bl.AddList(ListInfo{Name: "facebook", Category: "social", Enabled: true})
+	bl.AddList(ListInfo{Name: "tiktok", Category: "social", Enabled: true})
+	bl.AddList(ListInfo{Name: "ads", Category: "ads", Enabled: true})
+	bl.LoadListContent("facebook", "0.0.0.0 facebook.com")
+	bl.LoadListContent("tiktok", "0.0.0.0 tiktok.com")
+	bl.LoadListContent("ads", "0.0.0.0 ads.com")
+
+	if err := bl.SetListEnabled("facebook", false); err != nil {
+		t.Fatalf("SetListEnabled() error = %v", err)
+	}
+	if bl.IsBlocked("facebook.com") {
+		t.Error("disabled list should not block")
+	}
+	if !bl.IsBlocked("tiktok.com") {
+		t.Error("other list of the category should still block")
+	}
+
+	if n := bl.SetCategoryEnabled("social", false); n != 2 {
+		t.Errorf("SetCategoryEnabled() = %d, want 2", n)
+	}
+	if bl.IsBlocked("tiktok.com") || !bl.IsBlocked("ads.com") {
+		t.Error("SetCategoryEnabled() should only disable the social category")
+	}
+
+	bl.SetCategoryEnabled("social", true)
+	if !bl.IsBlocked("facebook.com") {
+		t.Error("re-enabled list should block again")
+	}
+
+	info, ok := bl.GetList("facebook")
+	if !ok || !info.Enabled || info.Count != 1 {
+		t.Errorf("GetList() = %+v, %v", info, ok)
+	}
+
+	// Clear leert die Regeln, die Listen selbst bleiben bestehen
+	bl.Clear()
+	if bl.Count() != 0 || len(bl.GetLists()) != 3 {
+		t.Errorf("after Clear(): Count() = %d, lists = %d", bl.Count(), len(bl.GetLists()))
+	}
+}
+
+func TestBlacklist_LoadList_ReplacesRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte("0.0.0.0 old.example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	bl := NewBlacklist()
+	bl.AddList(ListInfo{Name: "file", Source: path, Enabled: true})
+
+	if added, err := bl.LoadList("file"); err != nil || added != 1 {
+		t.Fatalf("LoadList() = %d, %v", added, err)
+	}
+
+	os.WriteFile(path, []byte("0.0.0.0 new.example.com\n"), 0o644)
+	if _, err := bl.LoadList("file"); err != nil {
+		t.Fatalf("LoadList() error = %v", err)
+	}
+	if bl.IsBlocked("old.example.com") || !bl.IsBlocked("new.example.com") {
+		t.Error("LoadList() should replace the previous rules of the list")
+	}
+
+	// Fehlende Quelle lässt die bisherigen Regeln aktiv
+	os.Remove(path)
+	if _, err := bl.LoadList("file"); err == nil {
+		t.Error("LoadList() with missing file should return error")
+	}
+	if !bl.IsBlocked("new.example.com") {
+		t.Error("failed LoadList() should keep the previous rules")
+	}
+}
+
+func TestBlacklist_ListsConcurrentAccess(t *testing.T) {
+	bl := NewBlacklist()
+	bl.AddList(ListInfo{Name: "ads", Category: "ads", Enabled: true})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			bl.LoadListContent("ads", "0.0.0.0 ads.com\n0.0.0.0 tracker.com\n")
+		}()
+		go func() {
+			defer wg.Done()
+			bl.SetCategoryEnabled("ads", i%2 == 0)
+		}()
+		go func() {
+			defer wg.Done()
+			bl.Match("ads.com", 0)
+			bl.GetLists()
+		}()
+	}
+	wg.Wait()
+}
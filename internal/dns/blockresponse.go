@@ -0,0 +1,146 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	mdns "github.com/miekg/dns"
+)
+
+// ErrBlocked wird von Lookup zurückgegeben, wenn eine blockierte Domain mit
+// NXDOMAIN oder REFUSED beantwortet wird
+var ErrBlocked = errors.New("domain is blocked")
+
+// BlockMode legt fest, wie blockierte Domains beantwortet werden
+type BlockMode int
+
+const (
+	// BlockModeDefault übernimmt die globale Einstellung des Proxys (nur für Listen)
+	BlockModeDefault BlockMode = iota
+	// BlockNullIP antwortet mit 0.0.0.0 bzw. ::
+	BlockNullIP
+	// BlockNXDomain antwortet, als gäbe es die Domain nicht
+	BlockNXDomain
+	// BlockRefused verweigert die Antwort
+	BlockRefused
+	// BlockNoData antwortet ohne Records (NOERROR, leere Antwort)
+	BlockNoData
+	// BlockCustomIP antwortet mit eigenen IPs, z.B. einer internen Block-Seite
+	BlockCustomIP
+)
+
+// defaultBlockTTL ist die TTL von Block-Antworten, wenn keine eigene gesetzt ist
+const defaultBlockTTL = 300
+
+// String gibt den Namen des Modus zurück
+func (m BlockMode) String() string {
+	switch m {
+	case BlockModeDefault:
+		return "default"
+	case BlockNullIP:
+		return "null-ip"
+	case BlockNXDomain:
+		return "nxdomain"
+	case BlockRefused:
+		return "refused"
+	case BlockNoData:
+		return "nodata"
+	case BlockCustomIP:
+		return "custom-ip"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseBlockMode wandelt einen Namen ("null-ip", "nxdomain", ...) in einen BlockMode um
+func ParseBlockMode(name string) (BlockMode, error) {
+	for m := BlockModeDefault; m <= BlockCustomIP; m++ {
+		if strings.EqualFold(name, m.String()) {
+			return m, nil
+		}
+	}
+	return BlockModeDefault, fmt.Errorf("unknown block mode: %s", name)
+}
+
+// BlockResponse beschreibt die Antwort auf blockierte Domains
+type BlockResponse struct {
+	Mode BlockMode
+	IPv4 string // nur bei BlockCustomIP, Antwort auf A-Anfragen
+	IPv6 string // nur bei BlockCustomIP, Antwort auf AAAA-Anfragen
+	TTL  uint32 // TTL der Antwort in Sekunden, 0 übernimmt die globale TTL
+}
+
+// validate prüft, dass eigene IPs gesetzt und zur Adressfamilie passend sind
+func (r BlockResponse) validate() error {
+	if r.Mode < BlockModeDefault || r.Mode > BlockCustomIP {
+		return fmt.Errorf("unknown block mode: %d", r.Mode)
+	}
+	if r.Mode != BlockCustomIP {
+		if r.IPv4 != "" || r.IPv6 != "" {
+			return fmt.Errorf("custom IPs require block mode %s", BlockCustomIP)
+		}
+		return nil
+	}
+
+	if r.IPv4 == "" && r.IPv6 == "" {
+		return fmt.Errorf("block mode %s needs at least one IPv4 or IPv6 address", BlockCustomIP)
+	}
+	if r.IPv4 != "" && !isIPv4Literal(r.IPv4) {
+		return fmt.Errorf("invalid IPv4 address: %s", r.IPv4)
+	}
+	if r.IPv6 != "" && !isIPv6Literal(r.IPv6) {
+		return fmt.Errorf("invalid IPv6 address: %s", r.IPv6)
+	}
+	return nil
+}
+
+// resolve ergänzt die Antwort einer Liste um die globale Einstellung
+// Ohne eigenen Modus gilt die globale Antwort, ohne eigene TTL die globale TTL
+func (r BlockResponse) resolve(global BlockResponse) BlockResponse {
+	if r.Mode == BlockModeDefault {
+		r.Mode, r.IPv4, r.IPv6 = global.Mode, global.IPv4, global.IPv6
+	}
+	if r.TTL == 0 {
+		r.TTL = global.TTL
+	}
+	return r
+}
+
+// result erzeugt das Ergebnis einer Abfrage für eine blockierte Domain
+func (r BlockResponse) result(match MatchResult) *Result {
+	res := &Result{Rcode: mdns.RcodeSuccess, TTL: r.TTL, Blocked: true, Match: match}
+
+	switch r.Mode {
+	case BlockNXDomain:
+		res.Rcode = mdns.RcodeNameError
+	case BlockRefused:
+		res.Rcode = mdns.RcodeRefused
+	case BlockNoData:
+		// NOERROR ohne Records
+	case BlockCustomIP:
+		for _, ip := range []string{r.IPv4, r.IPv6} {
+			if ip != "" {
+				res.IPs = append(res.IPs, ip)
+			}
+		}
+	default:
+		res.IPs = []string{"0.0.0.0", "::"}
+	}
+
+	return res
+}
+
+// Result ist das Ergebnis einer Abfrage über Resolve
+type Result struct {
+	IPs     []string
+	Rcode   int    // DNS-Rcode der Antwort, z.B. dns.RcodeNameError bei NXDOMAIN-Blocking
+	TTL     uint32 // TTL der Antwort in Sekunden, 0 überlässt die Wahl dem Server
+	Blocked bool
+	Local   bool        // Antwort stammt aus einem lokalen Record (hosts-Datei), nicht vom Upstream
+	Match   MatchResult // bei Blocked: Liste und Regel, die getroffen haben
+	CNAME   string      // bei CNAME-Cloaking: das gelistete Glied der CNAME-Kette
+	IP      string      // bei IP-Regeln und Rebinding: die blockierte Adresse der Antwort
+	Rewrite string      // bei SafeSearch: CNAME-Ziel, zu dem die IPs gehören
+	Group   string      // Client-Gruppe der Anfrage, leer ohne Gruppe
+}
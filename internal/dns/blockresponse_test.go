@@ -0,0 +1,226 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	mdns "github.com/miekg/dns"
+)
+
+func TestParseBlockMode(t *testing.T) {
+	for m := BlockModeDefault; m <= BlockCustomIP; m++ {
+		got, err := ParseBlockMode(m.String())
+		if err != nil || got != m {
+			t.Errorf("ParseBlockMode(%q) = %v, %v", m.String(), got, err)
+		}
+	}
+	if got, err := ParseBlockMode("NXDOMAIN"); err != nil || got != BlockNXDomain {
+		t.Errorf("ParseBlockMode(NXDOMAIN) = %v, %v", got, err)
+	}
+	if _, err := ParseBlockMode("drop"); err == nil {
+		t.Error("ParseBlockMode() with unknown mode should return error")
+	}
+}
+
+func TestBlockResponse_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    BlockResponse
+		wantErr bool
+	}{
+		{name: "Default", resp: BlockResponse{}},
+		{name: "NXDOMAIN with TTL", resp: BlockResponse{Mode: BlockNXDomain, TTL: 10}},
+		{name: "Custom IPv4", resp: BlockResponse{Mode: BlockCustomIP, IPv4: "10.0.0.1"}},
+		{name: "Custom both", resp: BlockResponse{Mode: BlockCustomIP, IPv4: "10.0.0.1", IPv6: "fd00::1"}},
+		{name: "Custom without IP", resp: BlockResponse{Mode: BlockCustomIP}, wantErr: true},
+		{name: "Custom IPv6 in IPv4", resp: BlockResponse{Mode: BlockCustomIP, IPv4: "fd00::1"}, wantErr: true},
+		{name: "Custom invalid IPv6", resp: BlockResponse{Mode: BlockCustomIP, IPv6: "10.0.0.1"}, wantErr: true},
+		{name: "IP without custom mode", resp: BlockResponse{Mode: BlockNullIP, IPv4: "10.0.0.1"}, wantErr: true},
+		{name: "Unknown mode", resp: BlockResponse{Mode: BlockMode(42)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.resp.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProxy_SetBlockResponse(t *testing.T) {
+	proxy := NewProxy(NewRegistry(), NewBlacklist())
+
+	if got := proxy.GetBlockResponse(); got.Mode != BlockNullIP || got.TTL != defaultBlockTTL {
+		t.Errorf("default block response = %+v", got)
+	}
+	if err := proxy.SetBlockResponse(BlockResponse{}); err == nil {
+		t.Error("SetBlockResponse() without mode should return error")
+	}
+	if err := proxy.SetBlockResponse(BlockResponse{Mode: BlockCustomIP}); err == nil {
+		t.Error("SetBlockResponse() with custom mode and no IP should return error")
+	}
+	if err := proxy.SetBlockResponse(BlockResponse{Mode: BlockNXDomain}); err != nil {
+		t.Fatalf("SetBlockResponse() error = %v", err)
+	}
+	if got := proxy.GetBlockResponse().TTL; got != defaultBlockTTL {
+		t.Errorf("TTL 0 should fall back to %d, got %d", defaultBlockTTL, got)
+	}
+}
+
+func TestProxy_Resolve_BlockModes(t *testing.T) {
+	tests := []struct {
+		name      string
+		global    BlockResponse
+		list      BlockResponse
+		domain    string
+		wantIPs   []string
+		wantRcode int
+		wantTTL   uint32
+		wantList  string
+	}{
+		{
+			name:      "Default null IP",
+			global:    BlockResponse{Mode: BlockNullIP},
+			domain:    "manual.example.com",
+			wantIPs:   []string{"0.0.0.0", "::"},
+			wantRcode: mdns.RcodeSuccess,
+			wantTTL:   defaultBlockTTL,
+		},
+		{
+			name:      "Global NXDOMAIN",
+			global:    BlockResponse{Mode: BlockNXDomain, TTL: 10},
+			domain:    "manual.example.com",
+			wantRcode: mdns.RcodeNameError,
+			wantTTL:   10,
+		},
+		{
+			name:      "Global REFUSED",
+			global:    BlockResponse{Mode: BlockRefused},
+			domain:    "manual.example.com",
+			wantRcode: mdns.RcodeRefused,
+			wantTTL:   defaultBlockTTL,
+		},
+		{
+			name:      "Global NODATA",
+			global:    BlockResponse{Mode: BlockNoData, TTL: 60},
+			domain:    "manual.example.com",
+			wantRcode: mdns.RcodeSuccess,
+			wantTTL:   60,
+		},
+		{
+			name:      "Global custom IP",
+			global:    BlockResponse{Mode: BlockCustomIP, IPv4: "10.0.0.53", IPv6: "fd00::53"},
+			domain:    "manual.example.com",
+			wantIPs:   []string{"10.0.0.53", "fd00::53"},
+			wantRcode: mdns.RcodeSuccess,
+			wantTTL:   defaultBlockTTL,
+		},
+		{
+			name:      "List inherits global",
+			global:    BlockResponse{Mode: BlockNXDomain, TTL: 30},
+			domain:    "ads.example.com",
+			wantRcode: mdns.RcodeNameError,
+			wantTTL:   30,
+			wantList:  "ads",
+		},
+		{
+			name:      "List overrides mode, inherits TTL",
+			global:    BlockResponse{Mode: BlockNXDomain, TTL: 30},
+			list:      BlockResponse{Mode: BlockCustomIP, IPv4: "10.0.0.80"},
+			domain:    "ads.example.com",
+			wantIPs:   []string{"10.0.0.80"},
+			wantRcode: mdns.RcodeSuccess,
+			wantTTL:   30,
+			wantList:  "ads",
+		},
+		{
+			name:      "List overrides TTL only",
+			global:    BlockResponse{Mode: BlockNullIP},
+			list:      BlockResponse{TTL: 5},
+			domain:    "ads.example.com",
+			wantIPs:   []string{"0.0.0.0", "::"},
+			wantRcode: mdns.RcodeSuccess,
+			wantTTL:   5,
+			wantList:  "ads",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blacklist := NewBlacklist()
+			blacklist.AddDomain("manual.example.com")
+			if err := blacklist.AddList(ListInfo{Name: "ads", Enabled: true, Block: tt.list}); err != nil {
+				t.Fatalf("AddList() error = %v", err)
+			}
+			blacklist.LoadListContent("ads", "0.0.0.0 ads.example.com")
+
+			proxy := NewProxy(NewRegistry(), blacklist)
+			if err := proxy.SetBlockResponse(tt.global); err != nil {
+				t.Fatalf("SetBlockResponse() error = %v", err)
+			}
+
+			result, err := proxy.Resolve(context.Background(), tt.domain)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if !result.Blocked {
+				t.Error("Resolve() result should be blocked")
+			}
+			if !slices.Equal(result.IPs, tt.wantIPs) {
+				t.Errorf("IPs = %v, want %v", result.IPs, tt.wantIPs)
+			}
+			if result.Rcode != tt.wantRcode {
+				t.Errorf("Rcode = %d, want %d", result.Rcode, tt.wantRcode)
+			}
+			if result.TTL != tt.wantTTL {
+				t.Errorf("TTL = %d, want %d", result.TTL, tt.wantTTL)
+			}
+			if result.Match.List != tt.wantList {
+				t.Errorf("Match.List = %q, want %q", result.Match.List, tt.wantList)
+			}
+		})
+	}
+}
+
+func TestProxy_Lookup_BlockedNXDomain(t *testing.T) {
+	blacklist := NewBlacklist()
+	blacklist.AddDomain("blocked.com")
+	proxy := NewProxy(NewRegistry(), blacklist)
+	proxy.SetBlockResponse(BlockResponse{Mode: BlockNXDomain})
+
+	ips, err := proxy.Lookup("blocked.com")
+	if !errors.Is(err, ErrBlocked) {
+		t.Errorf("Lookup() error = %v, want ErrBlocked", err)
+	}
+	if len(ips) != 0 {
+		t.Errorf("Lookup() = %v, want no IPs", ips)
+	}
+
+	// NODATA ist kein Fehler, nur eine leere Antwort
+	proxy.SetBlockResponse(BlockResponse{Mode: BlockNoData})
+	ips, err = proxy.Lookup("blocked.com")
+	if err != nil || len(ips) != 0 {
+		t.Errorf("Lookup() with NODATA = %v, %v, want empty, nil", ips, err)
+	}
+}
+
+func TestBlacklist_SetListBlockResponse(t *testing.T) {
+	bl := NewBlacklist()
+	if err := bl.AddList(ListInfo{Name: "bad", Block: BlockResponse{Mode: BlockCustomIP}}); err == nil {
+		t.Error("AddList() with invalid block response should return error")
+	}
+
+	bl.AddList(ListInfo{Name: "ads", Enabled: true})
+	if err := bl.SetListBlockResponse("ads", BlockResponse{Mode: BlockRefused}); err != nil {
+		t.Fatalf("SetListBlockResponse() error = %v", err)
+	}
+	if err := bl.SetListBlockResponse("missing", BlockResponse{}); err == nil {
+		t.Error("SetListBlockResponse() for unknown list should return error")
+	}
+	if info, _ := bl.GetList("ads"); info.Block.Mode != BlockRefused {
+		t.Errorf("GetList().Block = %+v", info.Block)
+	}
+}
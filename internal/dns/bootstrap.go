@@ -0,0 +1,239 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	mdns "github.com/miekg/dns"
+)
+
+const (
+	// minBootstrapTTL verhindert, dass sehr kurze TTLs zu Dauer-Auflösungen führen
+	minBootstrapTTL = 30 * time.Second
+	// defaultBootstrapTTL gilt für Resolver, die keine TTL liefern (z.B. net.Resolver)
+	defaultBootstrapTTL = 5 * time.Minute
+)
+
+// Bootstrap löst Hostnamen von Upstream-Servern (z.B. "dns.quad9.net") auf
+// Es werden ausschließlich Resolver mit IP-Literal genutzt, nie der System-Resolver,
+// damit die Auflösung nicht über den Proxy selbst läuft
+type Bootstrap struct {
+	servers  []string // host:port, nur IP-Literale
+	timeout  time.Duration
+	inFlight map[string]int // Hostnamen, die gerade aufgelöst werden (Loop-Guard)
+	mu       sync.Mutex
+}
+
+// NewBootstrap erstellt einen Bootstrap-Resolver aus einer Liste von IP-Adressen
+// Erlaubt sind "9.9.9.9", "9.9.9.9:53", "2620:fe::fe" und "[2620:fe::fe]:53"
+func NewBootstrap(servers ...string) (*Bootstrap, error) {
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("bootstrap needs at least one resolver")
+	}
+
+	b := &Bootstrap{
+		servers:  make([]string, 0, len(servers)),
+		timeout:  2 * time.Second,
+		inFlight: make(map[string]int),
+	}
+
+	for _, server := range servers {
+		addr, err := parseBootstrapAddress(server)
+		if err != nil {
+			return nil, err
+		}
+		b.servers = append(b.servers, addr)
+	}
+
+	return b, nil
+}
+
+// parseBootstrapAddress normalisiert eine Resolver-Adresse zu host:port
+// Hostnamen werden abgelehnt, da sie selbst wieder aufgelöst werden müssten
+func parseBootstrapAddress(server string) (string, error) {
+	server = strings.TrimSpace(server)
+
+	if addrPort, err := netip.ParseAddrPort(server); err == nil {
+		if !isUsableBootstrapAddr(addrPort.Addr()) || addrPort.Port() == 0 {
+			return "", fmt.Errorf("invalid bootstrap resolver: %s", server)
+		}
+		return addrPort.String(), nil
+	}
+
+	addr, err := netip.ParseAddr(server)
+	if err != nil {
+		return "", fmt.Errorf("bootstrap resolver must be an IP address: %s", server)
+	}
+	if !isUsableBootstrapAddr(addr) {
+		return "", fmt.Errorf("invalid bootstrap resolver: %s", server)
+	}
+
+	return netip.AddrPortFrom(addr, 53).String(), nil
+}
+
+// isUsableBootstrapAddr lehnt unspezifizierte Adressen (0.0.0.0, ::) ab
+func isUsableBootstrapAddr(addr netip.Addr) bool {
+	return addr.IsValid() && !addr.IsUnspecified()
+}
+
+// SetTimeout setzt das Timeout pro Bootstrap-Abfrage
+func (b *Bootstrap) SetTimeout(timeout time.Duration) {
+	b.timeout = timeout
+}
+
+// GetServers gibt die konfigurierten Bootstrap-Resolver zurück
+func (b *Bootstrap) GetServers() []string {
+	servers := make([]string, len(b.servers))
+	copy(servers, b.servers)
+	return servers
+}
+
+// IsResolving prüft, ob ein Hostname gerade vom Bootstrap aufgelöst wird
+// Kommt eine solche Anfrage beim Proxy an, läuft die Auflösung im Kreis
+func (b *Bootstrap) IsResolving(host string) bool {
+	host = normalizeHost(host)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.inFlight[host] > 0
+}
+
+// LookupNetIP erfüllt das BootstrapResolver-Interface
+// network: "ip", "ip4" oder "ip6"
+func (b *Bootstrap) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	addrs, _, err := b.ResolveTTL(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]netip.Addr, 0, len(addrs))
+	for _, addr := range addrs {
+		switch {
+		case network == "ip4" && !addr.Is4():
+		case network == "ip6" && !addr.Is6():
+		default:
+			filtered = append(filtered, addr)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("no %s addresses found for %s", network, host)
+	}
+
+	return filtered, nil
+}
+
+// ResolveTTL löst einen Hostnamen in IPv4- und IPv6-Adressen auf
+// Gibt zusätzlich die kleinste TTL der Antworten zurück (mindestens minBootstrapTTL)
+// Die Resolver werden der Reihe nach versucht, bis einer Adressen liefert
+func (b *Bootstrap) ResolveTTL(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+	host = normalizeHost(host)
+	if host == "" {
+		return nil, 0, fmt.Errorf("hostname cannot be empty")
+	}
+
+	b.enter(host)
+	defer b.leave(host)
+
+	var lastErr error
+	for _, server := range b.servers {
+		addrs, ttl, err := b.resolveWith(ctx, server, host)
+		if err == nil {
+			return addrs, ttl, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, 0, ctxErr
+		}
+		lastErr = err
+	}
+
+	return nil, 0, fmt.Errorf("bootstrap failed for %s: %w", host, lastErr)
+}
+
+// resolveWith fragt A und AAAA bei einem einzelnen Bootstrap-Resolver ab
+func (b *Bootstrap) resolveWith(ctx context.Context, server, host string) ([]netip.Addr, time.Duration, error) {
+	client := &mdns.Client{Net: "udp", Timeout: b.timeout}
+
+	var addrs []netip.Addr
+	var minTTL uint32
+	var lastErr error
+
+	for _, qtype := range []uint16{mdns.TypeA, mdns.TypeAAAA} {
+		msg := new(mdns.Msg)
+		msg.SetQuestion(mdns.Fqdn(host), qtype)
+		msg.RecursionDesired = true
+
+		resp, _, err := client.ExchangeContext(ctx, msg, server)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.Rcode != mdns.RcodeSuccess {
+			lastErr = fmt.Errorf("%s answered %s", server, mdns.RcodeToString[resp.Rcode])
+			continue
+		}
+
+		for _, rr := range resp.Answer {
+			var ip net.IP
+			switch record := rr.(type) {
+			case *mdns.A:
+				ip = record.A
+			case *mdns.AAAA:
+				ip = record.AAAA
+			default:
+				continue
+			}
+			addr, ok := netip.AddrFromSlice(ip)
+			if !ok {
+				continue
+			}
+			addrs = append(addrs, addr.Unmap())
+			if minTTL == 0 || rr.Header().Ttl < minTTL {
+				minTTL = rr.Header().Ttl
+			}
+		}
+	}
+
+	if len(addrs) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("%s returned no addresses", server)
+		}
+		return nil, 0, lastErr
+	}
+
+	ttl := time.Duration(minTTL) * time.Second
+	if ttl < minBootstrapTTL {
+		ttl = minBootstrapTTL
+	}
+
+	return addrs, ttl, nil
+}
+
+// enter markiert einen Hostnamen als in Auflösung befindlich
+func (b *Bootstrap) enter(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.inFlight[host]++
+}
+
+// leave entfernt die Markierung wieder
+func (b *Bootstrap) leave(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.inFlight[host]--
+	if b.inFlight[host] <= 0 {
+		delete(b.inFlight, host)
+	}
+}
+
+// normalizeHost bringt Hostnamen in eine vergleichbare Form (lowercase, ohne Punkt am Ende)
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
@@ -0,0 +1,348 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mdns "github.com/miekg/dns"
+)
+
+// bootstrapHandler beantwortet A- und AAAA-Anfragen mit festen Adressen und TTL
+func bootstrapHandler(ipv4, ipv6 string, ttl uint32) mdns.HandlerFunc {
+	return func(w mdns.ResponseWriter, r *mdns.Msg) {
+		msg := new(mdns.Msg)
+		msg.SetReply(r)
+		for _, q := range r.Question {
+			hdr := mdns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: mdns.ClassINET, Ttl: ttl}
+			if q.Qtype == mdns.TypeA && ipv4 != "" {
+				msg.Answer = append(msg.Answer, &mdns.A{Hdr: hdr, A: net.ParseIP(ipv4)})
+			}
+			if q.Qtype == mdns.TypeAAAA && ipv6 != "" {
+				msg.Answer = append(msg.Answer, &mdns.AAAA{Hdr: hdr, AAAA: net.ParseIP(ipv6)})
+			}
+		}
+		w.WriteMsg(msg)
+	}
+}
+
+func TestNewBootstrap(t *testing.T) {
+	tests := []struct {
+		name      string
+		servers   []string
+		want      []string
+		wantError bool
+	}{
+		{
+			name:    "IPv4 without port",
+			servers: []string{"9.9.9.9"},
+			want:    []string{"9.9.9.9:53"},
+		},
+		{
+			name:    "IPv4 with port",
+			servers: []string{"9.9.9.9:5353"},
+			want:    []string{"9.9.9.9:5353"},
+		},
+		{
+			name:    "IPv6 with and without port",
+			servers: []string{"2620:fe::fe", "[2620:fe::9]:53"},
+			want:    []string{"[2620:fe::fe]:53", "[2620:fe::9]:53"},
+		},
+		{
+			name:      "Empty list",
+			servers:   nil,
+			wantError: true,
+		},
+		{
+			name:      "Hostname is rejected",
+			servers:   []string{"dns.quad9.net"},
+			wantError: true,
+		},
+		{
+			name:      "Unspecified address is rejected",
+			servers:   []string{"0.0.0.0"},
+			wantError: true,
+		},
+		{
+			name:      "Port zero is rejected",
+			servers:   []string{"9.9.9.9:0"},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewBootstrap(tt.servers...)
+			if tt.wantError {
+				if err == nil {
+					t.Error("NewBootstrap() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewBootstrap() unexpected error: %v", err)
+			}
+			got := b.GetServers()
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("GetServers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBootstrap_ResolveTTL(t *testing.T) {
+	port := startTestUpstreamHandler(t, "127.0.0.1:0", bootstrapHandler("192.0.2.53", "2001:db8::53", 600))
+
+	b, _ := NewBootstrap(fmt.Sprintf("127.0.0.1:%d", port))
+
+	addrs, ttl, err := b.ResolveTTL(context.Background(), "dns.example.net")
+	if err != nil {
+		t.Fatalf("ResolveTTL() unexpected error: %v", err)
+	}
+	if len(addrs) != 2 {
+		t.Fatalf("ResolveTTL() returned %d addresses, want 2", len(addrs))
+	}
+	if addrs[0].String() != "192.0.2.53" || addrs[1].String() != "2001:db8::53" {
+		t.Errorf("ResolveTTL() = %v, want [192.0.2.53 2001:db8::53]", addrs)
+	}
+	if ttl != 600*time.Second {
+		t.Errorf("ResolveTTL() ttl = %v, want 10m", ttl)
+	}
+}
+
+func TestBootstrap_ResolveTTL_MinimumTTL(t *testing.T) {
+	port := startTestUpstreamHandler(t, "127.0.0.1:0", bootstrapHandler("192.0.2.53", "", 1))
+
+	b, _ := NewBootstrap(fmt.Sprintf("127.0.0.1:%d", port))
+
+	_, ttl, err := b.ResolveTTL(context.Background(), "dns.example.net")
+	if err != nil {
+		t.Fatalf("ResolveTTL() unexpected error: %v", err)
+	}
+	if ttl != minBootstrapTTL {
+		t.Errorf("ResolveTTL() ttl = %v, want minimum %v", ttl, minBootstrapTTL)
+	}
+}
+
+func TestBootstrap_Failover(t *testing.T) {
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() failed: %v", err)
+	}
+	defer silent.Close()
+	port := startTestUpstreamHandler(t, "127.0.0.1:0", bootstrapHandler("192.0.2.53", "", 300))
+
+	b, _ := NewBootstrap(silent.LocalAddr().String(), fmt.Sprintf("127.0.0.1:%d", port))
+	b.SetTimeout(100 * time.Millisecond)
+
+	addrs, _, err := b.ResolveTTL(context.Background(), "dns.example.net")
+	if err != nil {
+		t.Fatalf("ResolveTTL() should fail over to the second resolver, got: %v", err)
+	}
+	if len(addrs) != 1 || addrs[0].String() != "192.0.2.53" {
+		t.Errorf("ResolveTTL() = %v, want [192.0.2.53]", addrs)
+	}
+}
+
+func TestBootstrap_LookupNetIP_Network(t *testing.T) {
+	port := startTestUpstreamHandler(t, "127.0.0.1:0", bootstrapHandler("192.0.2.53", "2001:db8::53", 300))
+	b, _ := NewBootstrap(fmt.Sprintf("127.0.0.1:%d", port))
+
+	v4, err := b.LookupNetIP(context.Background(), "ip4", "dns.example.net")
+	if err != nil || len(v4) != 1 || !v4[0].Is4() {
+		t.Errorf("LookupNetIP(ip4) = %v, %v, want one IPv4 address", v4, err)
+	}
+
+	v6, err := b.LookupNetIP(context.Background(), "ip6", "dns.example.net")
+	if err != nil || len(v6) != 1 || !v6[0].Is6() {
+		t.Errorf("LookupNetIP(ip6) = %v, %v, want one IPv6 address", v6, err)
+	}
+}
+
+func TestServer_RefreshOnTTL(t *testing.T) {
+	var current atomic.Value
+	current.Store("192.0.2.1")
+
+	port := startTestUpstreamHandler(t, "127.0.0.1:0", func(w mdns.ResponseWriter, r *mdns.Msg) {
+		bootstrapHandler(current.Load().(string), "", 300)(w, r)
+	})
+	b, _ := NewBootstrap(fmt.Sprintf("127.0.0.1:%d", port))
+
+	server, err := NewServerFromHost(context.Background(), "Encrypted", "dns.example.net", 853, b)
+	if err != nil {
+		t.Fatalf("NewServerFromHost() unexpected error: %v", err)
+	}
+	if server.GetIPv4() != "192.0.2.1" {
+		t.Fatalf("GetIPv4() = %v, want 192.0.2.1", server.GetIPv4())
+	}
+	if server.NeedsRefresh() {
+		t.Error("NeedsRefresh() should be false right after resolving")
+	}
+
+	// Upstream wechselt die Adresse, die TTL läuft ab
+	current.Store("192.0.2.2")
+	server.mu.Lock()
+	server.expires = time.Now().Add(-time.Second)
+	server.mu.Unlock()
+
+	if !server.NeedsRefresh() {
+		t.Fatal("NeedsRefresh() should be true after the TTL expired")
+	}
+	if err := server.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() unexpected error: %v", err)
+	}
+	if server.GetIPv4() != "192.0.2.2" {
+		t.Errorf("GetIPv4() after refresh = %v, want 192.0.2.2", server.GetIPv4())
+	}
+}
+
+func TestServer_RefreshKeepsAddressesOnError(t *testing.T) {
+	var fail atomic.Bool
+
+	port := startTestUpstreamHandler(t, "127.0.0.1:0", func(w mdns.ResponseWriter, r *mdns.Msg) {
+		if fail.Load() {
+			msg := new(mdns.Msg)
+			msg.SetRcode(r, mdns.RcodeServerFailure)
+			w.WriteMsg(msg)
+			return
+		}
+		bootstrapHandler("192.0.2.1", "", 300)(w, r)
+	})
+	b, _ := NewBootstrap(fmt.Sprintf("127.0.0.1:%d", port))
+
+	server, err := NewServerFromHost(context.Background(), "Encrypted", "dns.example.net", 853, b)
+	if err != nil {
+		t.Fatalf("NewServerFromHost() unexpected error: %v", err)
+	}
+
+	fail.Store(true)
+	if err := server.Refresh(context.Background()); err == nil {
+		t.Error("Refresh() should report the bootstrap failure")
+	}
+	if server.GetIPv4() != "192.0.2.1" {
+		t.Errorf("GetIPv4() after failed refresh = %v, want previous address", server.GetIPv4())
+	}
+	if server.NeedsRefresh() {
+		t.Error("Failed refresh should back off before the next attempt")
+	}
+}
+
+func TestProxy_BootstrapLoopGuard(t *testing.T) {
+	// Der Bootstrap-Resolver ist (fehlkonfiguriert) der Proxy selbst:
+	// jede Bootstrap-Anfrage landet wieder in proxy.LookupContext
+	var proxyRef atomic.Pointer[Proxy]
+	var mu sync.Mutex
+	var loopErr error
+	port := startTestUpstreamHandler(t, "127.0.0.1:0", func(w mdns.ResponseWriter, r *mdns.Msg) {
+		_, err := proxyRef.Load().LookupContext(context.Background(), r.Question[0].Name)
+		mu.Lock()
+		loopErr = err
+		mu.Unlock()
+
+		msg := new(mdns.Msg)
+		msg.SetRcode(r, mdns.RcodeServerFailure)
+		w.WriteMsg(msg)
+	})
+
+	b, _ := NewBootstrap(fmt.Sprintf("127.0.0.1:%d", port))
+	proxy := NewProxy(NewRegistry(), NewBlacklist())
+	proxy.SetBootstrap(b)
+	proxyRef.Store(proxy)
+
+	_, err := NewServerFromHost(context.Background(), "Loop", "dns.example.net", 53, b)
+	if err == nil {
+		t.Fatal("NewServerFromHost() should fail when bootstrap loops through the proxy")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if loopErr == nil || !strings.Contains(loopErr.Error(), "bootstrap loop") {
+		t.Errorf("Proxy should refuse the looping query, got: %v", loopErr)
+	}
+	if b.IsResolving("dns.example.net") {
+		t.Error("IsResolving() should be false after the bootstrap finished")
+	}
+}
+
+func TestProxy_LookupRefreshesHostnameServer(t *testing.T) {
+	upstreamPort := startTestUpstream(t, "127.0.0.1:0", "10.0.0.1")
+
+	bootstrapPort := startTestUpstreamHandler(t, "127.0.0.1:0", bootstrapHandler("127.0.0.1", "", 300))
+	b, _ := NewBootstrap(fmt.Sprintf("127.0.0.1:%d", bootstrapPort))
+
+	server, err := NewServerFromHost(context.Background(), "Local", "upstream.example.net", upstreamPort, b)
+	if err != nil {
+		t.Fatalf("NewServerFromHost() unexpected error: %v", err)
+	}
+
+	// Abgelaufene Adresse, die erst der Refresh korrigiert
+	server.mu.Lock()
+	server.IPv4 = "192.0.2.99"
+	server.expires = time.Now().Add(-time.Second)
+	server.mu.Unlock()
+
+	registry := NewRegistry()
+	registry.AddServer(server)
+	proxy := NewProxy(registry, NewBlacklist())
+
+	// Die erste Anfrage startet den Refresh im Hintergrund und nutzt noch die alte Adresse
+	proxy.SetTimeout(100 * time.Millisecond)
+	proxy.Lookup("example.com")
+
+	deadline := time.Now().Add(time.Second)
+	for server.GetIPv4() != "127.0.0.1" {
+		if time.Now().After(deadline) {
+			t.Fatalf("GetIPv4() after refresh = %v, want 127.0.0.1", server.GetIPv4())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	ips, err := proxy.Lookup("example.com")
+	if err != nil {
+		t.Fatalf("Lookup() should use the refreshed address, got: %v", err)
+	}
+	if len(ips) != 1 || ips[0] != "10.0.0.1" {
+		t.Errorf("Lookup() = %v, want [10.0.0.1]", ips)
+	}
+}
+
+// hangingResolver ist ein Bootstrap-Resolver, der nie antwortet
+type hangingResolver struct{}
+
+func (hangingResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestProxy_LookupDoesNotWaitForRefresh(t *testing.T) {
+	upstreamPort := startTestUpstream(t, "127.0.0.1:0", "10.0.0.1")
+
+	server, _ := NewServer("Local", "127.0.0.1", "", upstreamPort)
+	server.Hostname = "upstream.example.net"
+	server.resolver = hangingResolver{}
+	server.expires = time.Now().Add(-time.Second)
+
+	registry := NewRegistry()
+	registry.AddServer(server)
+	proxy := NewProxy(registry, NewBlacklist())
+	proxy.SetTimeout(500 * time.Millisecond)
+
+	// Ein hängender Bootstrap-Resolver darf die Anfrage nicht aufhalten
+	start := time.Now()
+	ips, err := proxy.LookupContext(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Lookup() should use the previous address, got: %v", err)
+	}
+	if len(ips) != 1 || ips[0] != "10.0.0.1" {
+		t.Errorf("Lookup() = %v, want [10.0.0.1]", ips)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("Lookup() took %v, should not wait for the bootstrap resolver", elapsed)
+	}
+}
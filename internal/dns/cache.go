@@ -8,6 +8,7 @@ import (
 // CacheEntry repräsentiert einen Cache-Eintrag mit Timestamp
 type CacheEntry struct {
 	IPs       []string
+	CNAMEs    []string // CNAME-Kette der Upstream-Antwort, für die Prüfung auf CNAME-Cloaking
 	Timestamp time.Time
 }
 
@@ -54,13 +55,35 @@ func (c *Cache) Get(domain string) []string {
 	return entry.IPs
 }
 
+// GetEntry holt einen Eintrag samt CNAME-Kette aus dem Cache
+// Gibt nil zurück, wenn der Eintrag nicht existiert oder abgelaufen ist
+func (c *Cache) GetEntry(domain string) *CacheEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, exists := c.entries[domain]
+	if !exists || time.Since(entry.Timestamp) > c.ttl {
+		return nil
+	}
+
+	return entry
+}
+
 // Set speichert einen Eintrag im Cache
 func (c *Cache) Set(domain string, ips []string) {
+	c.SetWithCNAMEs(domain, ips, nil)
+}
+
+// SetWithCNAMEs speichert einen Eintrag mit der CNAME-Kette der Upstream-Antwort
+// Die Kette wird bei jedem Cache-Treffer erneut gegen die Blacklist geprüft,
+// damit später hinzugefügte Regeln auch für bereits gecachte Antworten greifen
+func (c *Cache) SetWithCNAMEs(domain string, ips []string, cnames []string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.entries[domain] = &CacheEntry{
 		IPs:       ips,
+		CNAMEs:    cnames,
 		Timestamp: time.Now(),
 	}
 }
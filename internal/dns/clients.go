@@ -0,0 +1,146 @@
+package dns
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync"
+)
+
+// ClientGroup ist eine Richtlinie für eine Gruppe von Clients
+// Beispiel: strenge Filterung für Kinder, keine für Server, nur Werbung für Erwachsene
+type ClientGroup struct {
+	Name     string   // eindeutiger Name, z.B. "kids"
+	Networks []string // Client-Adressen und Netze, z.B. "192.168.20.0/24" oder "10.0.0.5"
+
+	// Lists wählt Listen der Blacklist über Name oder Kategorie aus, nil = alle aktivierten Listen
+	// Manuelle Regeln der Blacklist gelten immer
+	Lists []string
+	// Unfiltered schaltet Blacklist, CNAME-Prüfung und IP-Regeln für die Gruppe ab (z.B. Server)
+	Unfiltered bool
+
+	Allowlist *Allowlist    // ersetzt die Allowlist des Proxys, nil = Allowlist des Proxys
+	Registry  *Registry     // eigene Upstream-Gruppe, nil = Upstreams des Proxys
+	Block     BlockResponse // Block-Antwort der Gruppe, BlockModeDefault = globale Einstellung
+
+	// SafeSearch erzwingt SafeSearch für die Gruppe (z.B. Kinder- oder Gästenetz), nil = Einstellung
+	// des Proxys. Eine Tabelle mit abgeschalteten Anbietern hebt ein globales SafeSearch auf.
+	SafeSearch *SafeSearch
+}
+
+// clientGroup ist eine Gruppe mit geparsten Netzen
+type clientGroup struct {
+	info     ClientGroup
+	prefixes []netip.Prefix
+}
+
+// ClientGroups ordnet Clients anhand ihrer Adresse einer Gruppe zu
+// Bei überlappenden Netzen gewinnt das spezifischste (längstes Präfix). Clients ohne Gruppe
+// bekommen die Einstellungen des Proxys.
+type ClientGroups struct {
+	groups []*clientGroup // in Reihenfolge des Hinzufügens
+	mu     sync.RWMutex
+}
+
+// NewClientGroups erstellt eine leere Gruppen-Zuordnung
+func NewClientGroups() *ClientGroups {
+	return &ClientGroups{}
+}
+
+// AddGroup fügt eine Gruppe hinzu
+// Netze dürfen nicht in mehreren Gruppen vorkommen
+func (c *ClientGroups) AddGroup(group ClientGroup) error {
+	if group.Name == "" {
+		return fmt.Errorf("group name cannot be empty")
+	}
+	if err := group.Block.validate(); err != nil {
+		return fmt.Errorf("invalid block response for group '%s': %w", group.Name, err)
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(group.Networks))
+	for _, network := range group.Networks {
+		prefix, err := parseCIDR(network)
+		if err != nil {
+			return fmt.Errorf("invalid network for group '%s': %w", group.Name, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, g := range c.groups {
+		if g.info.Name == group.Name {
+			return fmt.Errorf("group with name '%s' already exists", group.Name)
+		}
+		for _, prefix := range prefixes {
+			if slices.Contains(g.prefixes, prefix) {
+				return fmt.Errorf("network %s already belongs to group '%s'", prefix, g.info.Name)
+			}
+		}
+	}
+
+	group.Networks = slices.Clone(group.Networks)
+	group.Lists = slices.Clone(group.Lists)
+	c.groups = append(c.groups, &clientGroup{info: group, prefixes: prefixes})
+	return nil
+}
+
+// RemoveGroup entfernt eine Gruppe, ihre Clients bekommen wieder die Einstellungen des Proxys
+func (c *ClientGroups) RemoveGroup(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, g := range c.groups {
+		if g.info.Name == name {
+			c.groups = append(c.groups[:i], c.groups[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("group with name '%s' not found", name)
+}
+
+// GetGroups gibt alle Gruppen in Reihenfolge des Hinzufügens zurück
+func (c *ClientGroups) GetGroups() []ClientGroup {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	groups := make([]ClientGroup, len(c.groups))
+	for i, g := range c.groups {
+		groups[i] = g.info
+	}
+	return groups
+}
+
+// Count gibt die Anzahl der Gruppen zurück
+func (c *ClientGroups) Count() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.groups)
+}
+
+// Match sucht die Gruppe eines Clients anhand seiner Adresse
+func (c *ClientGroups) Match(addr netip.Addr) (ClientGroup, bool) {
+	if !addr.IsValid() {
+		return ClientGroup{}, false
+	}
+	addr = addr.Unmap().WithZone("")
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var best *clientGroup
+	bestBits := -1
+	for _, g := range c.groups {
+		for _, prefix := range g.prefixes {
+			if prefix.Bits() > bestBits && prefix.Contains(addr) {
+				best, bestBits = g, prefix.Bits()
+			}
+		}
+	}
+	if best == nil {
+		return ClientGroup{}, false
+	}
+	return best.info, true
+}
@@ -0,0 +1,103 @@
+package dns
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestClientGroups_AddGroup(t *testing.T) {
+	groups := NewClientGroups()
+
+	if err := groups.AddGroup(ClientGroup{Name: "kids", Networks: []string{"192.168.20.0/24", "192.168.1.50"}}); err != nil {
+		t.Fatalf("AddGroup() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		group ClientGroup
+	}{
+		{name: "Empty name", group: ClientGroup{Networks: []string{"10.0.0.0/8"}}},
+		{name: "Duplicate name", group: ClientGroup{Name: "kids"}},
+		{name: "Invalid network", group: ClientGroup{Name: "servers", Networks: []string{"10.0.0.0/40"}}},
+		{name: "Network of other group", group: ClientGroup{Name: "adults", Networks: []string{"192.168.20.0/24"}}},
+		{name: "Invalid block response", group: ClientGroup{Name: "adults", Block: BlockResponse{Mode: BlockCustomIP}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := groups.AddGroup(tt.group); err == nil {
+				t.Errorf("AddGroup(%+v) should return error", tt.group)
+			}
+		})
+	}
+
+	if groups.Count() != 1 {
+		t.Errorf("Count() = %d, want 1", groups.Count())
+	}
+	if err := groups.RemoveGroup("kids"); err != nil {
+		t.Errorf("RemoveGroup() error = %v", err)
+	}
+	if err := groups.RemoveGroup("kids"); err == nil {
+		t.Error("RemoveGroup() for unknown group should return error")
+	}
+}
+
+func TestClientGroups_Match(t *testing.T) {
+	groups := NewClientGroups()
+	groups.AddGroup(ClientGroup{Name: "lan", Networks: []string{"192.168.0.0/16", "fd00::/8"}})
+	groups.AddGroup(ClientGroup{Name: "kids", Networks: []string{"192.168.20.0/24"}})
+	groups.AddGroup(ClientGroup{Name: "tablet", Networks: []string{"192.168.20.7"}})
+
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{addr: "192.168.1.10", want: "lan"},
+		{addr: "192.168.20.10", want: "kids"},
+		{addr: "192.168.20.7", want: "tablet"},
+		{addr: "::ffff:192.168.20.10", want: "kids"},
+		{addr: "fd00::1", want: "lan"},
+		{addr: "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			group, ok := groups.Match(netip.MustParseAddr(tt.addr))
+			if group.Name != tt.want || ok != (tt.want != "") {
+				t.Errorf("Match(%s) = %q, %v, want %q", tt.addr, group.Name, ok, tt.want)
+			}
+		})
+	}
+
+	if _, ok := groups.Match(netip.Addr{}); ok {
+		t.Error("Match() with invalid address should not match")
+	}
+}
+
+func TestBlacklist_MatchLists(t *testing.T) {
+	b := NewBlacklist()
+	b.AddDomain("manual.example.com")
+	b.AddList(ListInfo{Name: "easylist", Category: "ads", Enabled: true})
+	b.AddList(ListInfo{Name: "social", Category: "social", Enabled: true})
+	b.LoadListContent("easylist", "||ads.example.com^\n")
+	b.LoadListContent("social", "||facebook.com^\n")
+
+	tests := []struct {
+		name   string
+		domain string
+		lists  []string
+		want   bool
+	}{
+		{name: "All lists", domain: "facebook.com", lists: nil, want: true},
+		{name: "Selected by category", domain: "ads.example.com", lists: []string{"ads"}, want: true},
+		{name: "Selected by name", domain: "ads.example.com", lists: []string{"easylist"}, want: true},
+		{name: "Not selected", domain: "facebook.com", lists: []string{"ads"}, want: false},
+		{name: "No lists", domain: "facebook.com", lists: []string{}, want: false},
+		{name: "Manual rules always apply", domain: "manual.example.com", lists: []string{}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := b.MatchLists(tt.domain, 0, tt.lists); got.Blocked != tt.want {
+				t.Errorf("MatchLists(%q, %v) = %+v, want blocked %v", tt.domain, tt.lists, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package dns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// compactBlockSize ist die Anzahl Einträge pro Block im compactSet
+// Größere Blöcke sparen Speicher, verlängern aber die lineare Suche im Block
+const compactBlockSize = 16
+
+// compactSet ist eine unveränderliche, speichersparende Menge von Domains
+// Die Domains werden mit umgedrehten Labels gespeichert ("ads.example.com" -> "com.example.ads"),
+// sortiert und blockweise front-codiert: jeder Eintrag speichert nur die Länge des gemeinsamen
+// Präfixes mit dem Vorgänger und den restlichen Teil. Durch die umgedrehten Labels teilen sich
+// Domains derselben Zone lange Präfixe, typischerweise bleiben so 10-20 Bytes pro Domain
+// (statt 100+ Bytes in einer map[string]bool).
+// Die Suche ist eine Binärsuche über die Blockanfänge und ein linearer Durchlauf eines Blocks.
+//
+// Der Preis ist die Abfragezeit: bei 200.000 Domains belegt eine Domain ca. 9 statt 83 Bytes,
+// ein Treffer kostet aber 0,3-1 µs statt 60-80 ns für eine Map, je nach CPU das 5- bis 15-fache
+// (BenchmarkDomainSet_Memory, BenchmarkDomainSet_Match). Die Binärsuche springt quer durch
+// data und verursacht Cache-Misses, die eine Map nicht hat.
+type compactSet struct {
+	data   []byte   // Einträge: uvarint(gemeinsam), uvarint(Länge Rest), Rest
+	blocks []uint32 // Offset des ersten Eintrags jedes Blocks (vollständig) hinter seinen uvarints
+	heads  []uint16 // Länge des ersten Eintrags jedes Blocks, die Binärsuche dekodiert nichts
+	n      int
+}
+
+// newCompactSet baut ein compactSet aus Domains (doppelte Einträge werden entfernt)
+func newCompactSet(domains []string) *compactSet {
+	keys := make([]string, len(domains))
+	var buf []byte
+	for i, domain := range domains {
+		buf = reverseLabels(buf, domain)
+		keys[i] = string(buf)
+	}
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	s := &compactSet{n: len(keys)}
+	prev := ""
+	for i, key := range keys {
+		shared := 0
+		if i%compactBlockSize != 0 {
+			shared = commonPrefixLen(prev, key)
+		}
+		s.data = binary.AppendUvarint(s.data, uint64(shared))
+		s.data = binary.AppendUvarint(s.data, uint64(len(key)-shared))
+		if i%compactBlockSize == 0 {
+			s.blocks = append(s.blocks, uint32(len(s.data)))
+			s.heads = append(s.heads, uint16(len(key)))
+		}
+		s.data = append(s.data, key[shared:]...)
+		prev = key
+	}
+	s.data = slices.Clip(s.data)
+	s.blocks = slices.Clip(s.blocks)
+	s.heads = slices.Clip(s.heads)
+	return s
+}
+
+// reverseLabels schreibt die Labels einer Domain in umgekehrter Reihenfolge nach dst
+// "ads.example.com" -> "com.example.ads"; die Funktion ist ihre eigene Umkehrung
+func reverseLabels(dst []byte, domain string) []byte {
+	dst = dst[:0]
+	for end := len(domain); end > 0; {
+		start := strings.LastIndexByte(domain[:end], '.') + 1
+		if len(dst) > 0 {
+			dst = append(dst, '.')
+		}
+		dst = append(dst, domain[start:end]...)
+		end = start - 1
+	}
+	return dst
+}
+
+// commonPrefixLen gibt die Länge des gemeinsamen Präfixes zweier Strings zurück
+func commonPrefixLen[T string | []byte](a, b T) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// count gibt die Anzahl der Domains zurück
+func (s *compactSet) count() int {
+	if s == nil {
+		return 0
+	}
+	return s.n
+}
+
+// entry dekodiert den Eintrag an Offset off und gibt gemeinsame Länge, Rest und nächsten Offset zurück
+func (s *compactSet) entry(off int) (shared int, suffix []byte, next int) {
+	sh, n := binary.Uvarint(s.data[off:])
+	off += n
+	length, n := binary.Uvarint(s.data[off:])
+	off += n
+	return int(sh), s.data[off : off+int(length)], off + int(length)
+}
+
+// head gibt den ersten, vollständigen Eintrag von Block i zurück
+func (s *compactSet) head(i int) []byte {
+	off := int(s.blocks[i])
+	return s.data[off : off+int(s.heads[i])]
+}
+
+// containsKey prüft, ob ein Schlüssel mit umgedrehten Labels enthalten ist
+func (s *compactSet) containsKey(key []byte) bool {
+	if s.count() == 0 {
+		return false
+	}
+
+	// Letzter Block, dessen erster Eintrag <= key ist
+	i := sort.Search(len(s.blocks), func(i int) bool {
+		return bytes.Compare(s.head(i), key) > 0
+	}) - 1
+	if i < 0 {
+		return false
+	}
+
+	// Die Einträge werden nicht zusammengesetzt: matched ist die Länge des gemeinsamen
+	// Präfixes von key und dem aktuellen Eintrag, der immer < key ist. Teilt der nächste
+	// Eintrag mehr als matched mit seinem Vorgänger, ist er ebenfalls < key; teilt er weniger,
+	// ist er > key. Nur bei Gleichheit wird der Rest verglichen.
+	head := s.head(i)
+	if bytes.Equal(head, key) {
+		return true
+	}
+	matched := commonPrefixLen(head, key)
+
+	off := int(s.blocks[i]) + len(head)
+	for j := 1; j < compactBlockSize && off < len(s.data); j++ {
+		var shared int
+		var suffix []byte
+		shared, suffix, off = s.entry(off)
+		switch {
+		case shared > matched:
+			continue
+		case shared < matched:
+			return false
+		}
+
+		rest := key[matched:]
+		n := commonPrefixLen(suffix, rest)
+		switch {
+		case n == len(suffix) && n == len(rest):
+			return true
+		case n == len(rest) || (n < len(suffix) && suffix[n] > rest[n]):
+			// Eintrag ist länger als key oder an Stelle n größer
+			return false
+		}
+		matched += n
+	}
+	return false
+}
+
+// contains prüft, ob eine Domain enthalten ist
+func (s *compactSet) contains(domain string) bool {
+	var buf [256]byte
+	return s.containsKey(reverseLabels(buf[:0], domain))
+}
+
+// findSuffix sucht die längste Eltern-Domain (inkl. der Domain selbst), die enthalten ist
+// reversed ist die Domain mit umgedrehten Labels: jede Eltern-Domain ist ein Präfix davon,
+// "com.example.ads" prüft "com.example.ads", "com.example" und "com"
+func (s *compactSet) findSuffix(domain string, reversed []byte) (string, bool) {
+	if s.count() == 0 {
+		return "", false
+	}
+
+	for end := len(reversed); end > 0; {
+		if s.containsKey(reversed[:end]) {
+			return domain[len(domain)-end:], true
+		}
+		end = bytes.LastIndexByte(reversed[:end], '.')
+	}
+	return "", false
+}
+
+// each ruft fn für jede Domain (in normaler Schreibweise) auf
+func (s *compactSet) each(fn func(domain string)) {
+	if s.count() == 0 {
+		return
+	}
+
+	var cur, out []byte
+	for off := 0; off < len(s.data); {
+		var shared int
+		var suffix []byte
+		shared, suffix, off = s.entry(off)
+		cur = append(cur[:shared], suffix...)
+		out = reverseLabels(out, string(cur))
+		fn(string(out))
+	}
+}
+
+// all gibt alle Domains zurück
+func (s *compactSet) all() []string {
+	domains := make([]string, 0, s.count())
+	s.each(func(domain string) {
+		domains = append(domains, domain)
+	})
+	return domains
+}
+
+// without gibt ein neues compactSet ohne die Domain zurück
+// Entfernen ist selten (manuelle Regeln), daher wird die Menge einfach neu aufgebaut
+func (s *compactSet) without(domain string) *compactSet {
+	if !s.contains(domain) {
+		return s
+	}
+
+	domains := make([]string, 0, s.count()-1)
+	s.each(func(d string) {
+		if d != domain {
+			domains = append(domains, d)
+		}
+	})
+	return newCompactSet(domains)
+}
+
+// memoryUsage gibt den belegten Speicher in Bytes zurück (ohne Struktur-Overhead)
+func (s *compactSet) memoryUsage() int {
+	if s == nil {
+		return 0
+	}
+	return cap(s.data) + 4*cap(s.blocks) + 2*cap(s.heads)
+}
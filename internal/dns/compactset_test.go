@@ -0,0 +1,254 @@
+package dns
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReverseLabels(t *testing.T) {
+	tests := map[string]string{
+		"ads.example.com": "com.example.ads",
+		"example.com":     "com.example",
+		"com":             "com",
+		"":                "",
+	}
+	for in, want := range tests {
+		if got := string(reverseLabels(nil, in)); got != want {
+			t.Errorf("reverseLabels(%q) = %q, want %q", in, got, want)
+		}
+		if back := string(reverseLabels(nil, want)); back != in {
+			t.Errorf("reverseLabels(%q) = %q, want %q", want, back, in)
+		}
+	}
+}
+
+func TestCompactSet(t *testing.T) {
+	// Mehr als ein Block, damit die Binärsuche über Blockgrenzen geprüft wird
+	var domains []string
+	for i := range 100 {
+		domains = append(domains, fmt.Sprintf("ads%d.example.com", i))
+	}
+	domains = append(domains, "tracker.net", "a.b.c.example.org", "ads0.example.com")
+
+	set := newCompactSet(domains)
+	if set.count() != 102 {
+		t.Fatalf("count() = %d, want 102 (duplicates removed)", set.count())
+	}
+
+	for _, d := range domains {
+		if !set.contains(d) {
+			t.Errorf("contains(%q) = false", d)
+		}
+	}
+	for _, d := range []string{"example.com", "ads100.example.com", "ads1.example.co", "a.b.c.example.org.x", "zzz", ""} {
+		if set.contains(d) {
+			t.Errorf("contains(%q) = true", d)
+		}
+	}
+
+	all := set.all()
+	slices.Sort(all)
+	want := slices.Compact(slices.Sorted(slices.Values(domains)))
+	if !slices.Equal(all, want) {
+		t.Errorf("all() returned %d domains, want %d", len(all), len(want))
+	}
+
+	smaller := set.without("tracker.net")
+	if smaller.contains("tracker.net") || smaller.count() != 101 || !set.contains("tracker.net") {
+		t.Error("without() should return a new set without the domain")
+	}
+
+	var empty *compactSet
+	if empty.contains("example.com") || empty.count() != 0 || len(empty.all()) != 0 {
+		t.Error("nil compactSet should be empty")
+	}
+}
+
+func TestCompactSet_MatchesMap(t *testing.T) {
+	// Die Suche im Block setzt die Einträge nicht zusammen; jede Domain und ihre
+	// Nachbarn (Präfixe, Verlängerungen, Geschwister) müssen wie bei einer Map gefunden werden
+	domains := benchmarkDomains(2000)
+	domains = append(domains, "a.com", "ab.com", "b.a.com", "a.a.com", "com")
+	set := newCompactSet(domains)
+
+	want := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		want[d] = true
+	}
+
+	var probes []string
+	for _, d := range domains {
+		probes = append(probes, d, "x."+d, d[1:], d+"x", strings.TrimSuffix(d, ".com")+".co")
+	}
+	for _, d := range probes {
+		if strings.HasPrefix(d, ".") {
+			continue // keine gültige Domain, wird vorher normalisiert
+		}
+		if got := set.contains(d); got != want[d] {
+			t.Errorf("contains(%q) = %v, want %v", d, got, want[d])
+		}
+	}
+}
+
+func TestDomainSet_Compact(t *testing.T) {
+	s := newDomainSet()
+	s.add("ads.example.com")
+	s.add("*.tracker.net")
+	s.add("*.cdn.example.org")
+	s.compact()
+
+	if len(s.domains) != 0 || len(s.wildcards) != 0 {
+		t.Fatal("compact() should empty the maps")
+	}
+
+	tests := []struct {
+		domain string
+		want   string
+		ok     bool
+	}{
+		{"ads.example.com", "ads.example.com", true},
+		{"x.ads.example.com", "", false},
+		{"tracker.net", "*.tracker.net", true},
+		{"a.b.tracker.net", "*.tracker.net", true},
+		{"cdn.example.org", "*.cdn.example.org", true},
+		{"example.org", "", false},
+		{"nottracker.net", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := s.match(tt.domain)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("match(%q) = %q, %v, want %q, %v", tt.domain, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	// Nach compact hinzugefügte Einträge landen in der Map, Duplikate werden nicht doppelt gezählt
+	s.add("ads.example.com")
+	s.add("new.example.com")
+	if s.count() != 4 || !s.contains("new.example.com") {
+		t.Errorf("count() = %d, want 4", s.count())
+	}
+
+	s.remove("*.tracker.net")
+	s.remove("ads.example.com")
+	if s.contains("tracker.net") || s.contains("ads.example.com") {
+		t.Error("remove() should remove compacted entries")
+	}
+
+	s.compact()
+	if got := s.allDomains(); len(got) != 1 || got[0] != "new.example.com" {
+		t.Errorf("allDomains() = %v", got)
+	}
+	if got := s.allWildcards(); len(got) != 1 || got[0] != "*.cdn.example.org" {
+		t.Errorf("allWildcards() = %v", got)
+	}
+}
+
+func TestBlacklist_SetCompact(t *testing.T) {
+	bl := NewBlacklist()
+	bl.AddDomain("manual.example.com")
+	bl.SetCompact(true)
+
+	bl.AddList(ListInfo{Name: "ads", Enabled: true})
+	if _, err := bl.LoadListContent("ads", "||ads.example.com^\n@@||ok.ads.example.com^\n"); err != nil {
+		t.Fatalf("LoadListContent() error = %v", err)
+	}
+	bl.LoadFromHostsContent("0.0.0.0 hosts.example.com\n")
+
+	for _, domain := range []string{"manual.example.com", "cdn.ads.example.com", "hosts.example.com"} {
+		if !bl.IsBlocked(domain) {
+			t.Errorf("%s should be blocked", domain)
+		}
+	}
+	if bl.IsBlocked("ok.ads.example.com") {
+		t.Error("exceptions should work in compact mode")
+	}
+	if match := bl.Match("cdn.ads.example.com", 0); match.List != "ads" || match.Rule != "*.ads.example.com" {
+		t.Errorf("Match() = %+v", match)
+	}
+	if bl.Count() != 4 {
+		t.Errorf("Count() = %d, want 4", bl.Count())
+	}
+}
+
+// benchmarkDomains erzeugt n Domains, die sich Zonen teilen wie in echten Listen
+func benchmarkDomains(n int) []string {
+	domains := make([]string, n)
+	for i := range domains {
+		domains[i] = fmt.Sprintf("tracker-%d.ads%d.example-network%d.com", i, i%97, i%13)
+	}
+	return domains
+}
+
+// heapInUse gibt den belegten Heap nach einer Garbage Collection zurück
+func heapInUse() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+func BenchmarkDomainSet_Memory(b *testing.B) {
+	const n = 200000
+	domains := benchmarkDomains(n)
+
+	for _, compact := range []bool{false, true} {
+		name := "map"
+		if compact {
+			name = "compact"
+		}
+		b.Run(name, func(b *testing.B) {
+			var perDomain float64
+			for b.Loop() {
+				before := heapInUse()
+				// Eigene Kopien wie beim Laden einer Liste, damit die Strings mitgezählt werden
+				s := newDomainSet()
+				for _, d := range domains {
+					s.add(strings.Clone(d))
+				}
+				if compact {
+					s.compact()
+				}
+				perDomain = float64(heapInUse()-before) / n
+				runtime.KeepAlive(s)
+			}
+			b.ReportMetric(perDomain, "bytes/domain")
+		})
+	}
+}
+
+func BenchmarkDomainSet_Match(b *testing.B) {
+	const n = 200000
+	domains := benchmarkDomains(n)
+
+	for _, compact := range []bool{false, true} {
+		s := newDomainSet()
+		for _, d := range domains {
+			s.add(d)
+		}
+		s.add("*.example-network3.com")
+		name := "map"
+		if compact {
+			name = "compact"
+			s.compact()
+		}
+
+		b.Run(name+"/hit", func(b *testing.B) {
+			for i := 0; b.Loop(); i++ {
+				s.match(domains[i%n])
+			}
+		})
+		b.Run(name+"/wildcard", func(b *testing.B) {
+			for b.Loop() {
+				s.match("www.cdn.example-network3.com")
+			}
+		})
+		b.Run(name+"/miss", func(b *testing.B) {
+			for b.Loop() {
+				s.match("www.some-regular-site.example.org")
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package dns
+
+import (
+	"context"
+	"net/netip"
+)
+
+// contextKey ist der Typ für Werte, die der Proxy aus dem Context liest
+type contextKey int
+
+const (
+	queryTypeKey contextKey = iota
+	clientAddrKey
+)
+
+// WithQueryType hängt den Query-Typ der ursprünglichen Anfrage (z.B. dns.TypeAAAA) an den Context
+// Der Proxy wertet damit $dnstype-Regeln der Blacklist aus
+func WithQueryType(ctx context.Context, qtype uint16) context.Context {
+	return context.WithValue(ctx, queryTypeKey, qtype)
+}
+
+// QueryTypeFromContext gibt den Query-Typ aus dem Context zurück, 0 wenn keiner gesetzt ist
+func QueryTypeFromContext(ctx context.Context) uint16 {
+	qtype, _ := ctx.Value(queryTypeKey).(uint16)
+	return qtype
+}
+
+// WithClientAddr hängt die Adresse des anfragenden Clients an den Context
+// Der Proxy wählt damit die Client-Gruppe (siehe ClientGroups)
+func WithClientAddr(ctx context.Context, addr netip.Addr) context.Context {
+	return context.WithValue(ctx, clientAddrKey, addr)
+}
+
+// ClientAddrFromContext gibt die Client-Adresse aus dem Context zurück, ungültig wenn keine gesetzt ist
+func ClientAddrFromContext(ctx context.Context) netip.Addr {
+	addr, _ := ctx.Value(clientAddrKey).(netip.Addr)
+	return addr
+}
@@ -0,0 +1,185 @@
+package dns
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// domainSet speichert exakte Domains und Wildcard-Suffixe
+// Nach compact liegen die Einträge in speichersparenden compactSets, später hinzugefügte
+// Einträge landen wieder in den Maps, bis erneut kompaktiert wird
+// Nicht thread-safe - Blacklist und Allowlist sichern den Zugriff mit ihrem Mutex ab
+type domainSet struct {
+	domains   map[string]bool
+	wildcards map[string]bool
+
+	compactDomains   *compactSet // nil, solange nicht kompaktiert wurde
+	compactWildcards *compactSet
+}
+
+// newDomainSet erstellt ein leeres domainSet
+func newDomainSet() *domainSet {
+	return &domainSet{
+		domains:   make(map[string]bool),
+		wildcards: make(map[string]bool),
+	}
+}
+
+// normalizeDomain bringt eine Domain in die gespeicherte Form (lowercase, ohne Whitespace)
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSpace(domain))
+}
+
+// add fügt eine Domain hinzu, "*.example.com" wird als Wildcard gespeichert
+func (s *domainSet) add(domain string) error {
+	if domain == "" {
+		return fmt.Errorf("domain cannot be empty")
+	}
+
+	domain = normalizeDomain(domain)
+
+	// Prüfe ob es ein Wildcard ist (beginnt mit *.)
+	if strings.HasPrefix(domain, "*.") {
+		// Entferne *. und speichere als Wildcard
+		suffix := domain[2:]
+		if suffix == "" {
+			return fmt.Errorf("invalid wildcard domain: %s", domain)
+		}
+		if !s.compactWildcards.contains(suffix) {
+			s.wildcards[suffix] = true
+		}
+	} else if !s.compactDomains.contains(domain) {
+		s.domains[domain] = true
+	}
+
+	return nil
+}
+
+// merge übernimmt alle Domains und Wildcards eines anderen domainSet
+func (s *domainSet) merge(other *domainSet) {
+	maps.Copy(s.domains, other.domains)
+	maps.Copy(s.wildcards, other.wildcards)
+	if other.compactDomains.count() > 0 || other.compactWildcards.count() > 0 {
+		s.compactDomains = mergeCompact(s.compactDomains, other.compactDomains)
+		s.compactWildcards = mergeCompact(s.compactWildcards, other.compactWildcards)
+	}
+}
+
+// mergeCompact vereinigt zwei compactSets
+func mergeCompact(a, b *compactSet) *compactSet {
+	if b.count() == 0 {
+		return a
+	}
+	if a.count() == 0 {
+		return b
+	}
+	return newCompactSet(append(a.all(), b.all()...))
+}
+
+// compact verschiebt alle Einträge aus den Maps in compactSets
+// Für große, selten geänderte Listen: statt 100+ Bytes belegt eine Domain typischerweise
+// 10-20 Bytes, eine Abfrage kostet dafür eine Binärsuche statt eines Map-Zugriffs
+func (s *domainSet) compact() {
+	if len(s.domains) > 0 {
+		s.compactDomains = newCompactSet(append(s.compactDomains.all(), slices.Collect(maps.Keys(s.domains))...))
+		s.domains = make(map[string]bool)
+	}
+	if len(s.wildcards) > 0 {
+		s.compactWildcards = newCompactSet(append(s.compactWildcards.all(), slices.Collect(maps.Keys(s.wildcards))...))
+		s.wildcards = make(map[string]bool)
+	}
+}
+
+// remove entfernt eine Domain oder Wildcard
+func (s *domainSet) remove(domain string) {
+	domain = normalizeDomain(domain)
+
+	if strings.HasPrefix(domain, "*.") {
+		delete(s.wildcards, domain[2:])
+		s.compactWildcards = s.compactWildcards.without(domain[2:])
+	} else {
+		delete(s.domains, domain)
+		s.compactDomains = s.compactDomains.without(domain)
+	}
+}
+
+// contains prüft exakte Einträge und Wildcards
+// domain muss bereits normalisiert sein
+func (s *domainSet) contains(domain string) bool {
+	_, ok := s.match(domain)
+	return ok
+}
+
+// match gibt den Eintrag zurück, der die Domain trifft ("example.com" oder "*.example.com")
+// domain muss bereits normalisiert sein
+func (s *domainSet) match(domain string) (string, bool) {
+	if s.domains[domain] {
+		return domain, true
+	}
+
+	// z.B. "ads.example.com" matched "*.example.com"
+	if suffix, ok := findWildcard(s.wildcards, domain); ok {
+		return "*." + suffix, true
+	}
+
+	if s.compactDomains == nil && s.compactWildcards == nil {
+		return "", false
+	}
+	var buf [256]byte
+	reversed := reverseLabels(buf[:0], domain)
+	if s.compactDomains.containsKey(reversed) {
+		return domain, true
+	}
+	if suffix, ok := s.compactWildcards.findSuffix(domain, reversed); ok {
+		return "*." + suffix, true
+	}
+	return "", false
+}
+
+// count gibt die Anzahl aller Einträge zurück (Domains + Wildcards)
+func (s *domainSet) count() int {
+	return len(s.domains) + len(s.wildcards) + s.compactDomains.count() + s.compactWildcards.count()
+}
+
+// allDomains gibt alle exakten Domains zurück
+func (s *domainSet) allDomains() []string {
+	domains := s.compactDomains.all()
+	for domain := range s.domains {
+		domains = append(domains, domain)
+	}
+	return domains
+}
+
+// allWildcards gibt alle Wildcards mit *. Präfix zurück
+func (s *domainSet) allWildcards() []string {
+	wildcards := make([]string, 0, len(s.wildcards)+s.compactWildcards.count())
+	s.compactWildcards.each(func(suffix string) {
+		wildcards = append(wildcards, "*."+suffix)
+	})
+	for suffix := range s.wildcards {
+		wildcards = append(wildcards, "*."+suffix)
+	}
+	return wildcards
+}
+
+// findWildcard sucht die Domain oder eine ihrer Eltern-Domains in den Wildcards und gibt den
+// getroffenen Suffix zurück
+// Statt alle Wildcards zu durchlaufen, werden nur die Labels der Domain abgelaufen:
+// "a.ads.example.com" prüft "a.ads.example.com", "ads.example.com", "example.com" und "com"
+// Die Kosten hängen damit von der Anzahl der Labels ab, nicht von der Größe der Liste
+func findWildcard(wildcards map[string]bool, domain string) (string, bool) {
+	for suffix := domain; suffix != ""; {
+		if wildcards[suffix] {
+			return suffix, true
+		}
+		dot := strings.IndexByte(suffix, '.')
+		if dot < 0 {
+			break
+		}
+		suffix = suffix[dot+1:]
+	}
+
+	return "", false
+}
@@ -0,0 +1,32 @@
+package dns
+
+import "testing"
+
+func TestDomainSet(t *testing.T) {
+	set := newDomainSet()
+
+	if err := set.add("Ads.Example.com "); err != nil {
+		t.Fatalf("add() unexpected error: %v", err)
+	}
+	if err := set.add("*.tracker.net"); err != nil {
+		t.Fatalf("add() unexpected error: %v", err)
+	}
+	if err := set.add("*."); err == nil {
+		t.Error("add() with invalid wildcard should return error")
+	}
+
+	if !set.contains("ads.example.com") {
+		t.Error("contains() should find normalized exact domain")
+	}
+	if !set.contains("a.tracker.net") || !set.contains("tracker.net") {
+		t.Error("contains() should match wildcard and its base domain")
+	}
+	if set.count() != 2 {
+		t.Errorf("count() = %d, want 2", set.count())
+	}
+
+	set.remove("*.TRACKER.net")
+	if set.contains("a.tracker.net") {
+		t.Error("remove() should delete wildcard regardless of case")
+	}
+}
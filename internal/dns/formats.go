@@ -0,0 +1,326 @@
+package dns
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// listRule ist eine Regel aus einer Zeile einer Liste, unabhängig vom Format
+type listRule struct {
+	domain string        // "example.com", "*.example.com" oder ein Muster
+	kind   ruleKind      // Art der Regel (Block, Ausnahme, $important)
+	filter dnsTypeFilter // $dnstype aus Adblock-Listen
+	addr   netip.Addr    // gültig: lokaler Record statt Block-Regel
+}
+
+// lineParser parst eine Zeile einer Liste
+// Leerzeilen und Kommentare liefern keine Regeln und keinen Fehler. Bei einem Fehler können
+// trotzdem Regeln zurückgegeben werden (die gültigen Hostnamen einer Zeile).
+type lineParser func(line string) ([]listRule, error)
+
+// addressRule erstellt die Regel für einen Eintrag mit IP-Adresse
+// 0.0.0.0, :: und Loopback blockieren, routbare Adressen werden zu lokalen Records
+func addressRule(domain string, addr netip.Addr) (listRule, error) {
+	addr, local, err := classifyListAddress(addr)
+	if err != nil {
+		return listRule{}, err
+	}
+	if !local {
+		return listRule{domain: domain, kind: ruleBlock}, nil
+	}
+	// Lokale Records gelten nur für den Namen selbst
+	return listRule{domain: strings.TrimPrefix(domain, "*."), addr: addr}, nil
+}
+
+// parseDomainLine parst eine Zeile einer reinen Domain-Liste (OISD, Hagezi, Threat-Intel-Feeds)
+// Format: "example.com" (nur die Domain) oder "*.example.com" (Domain und alle Subdomains)
+// Kommentare beginnen mit "#" oder "!"
+func parseDomainLine(line string) ([]listRule, error) {
+	if idx := strings.IndexByte(line, '#'); idx >= 0 {
+		line = line[:idx]
+	}
+	line = strings.ToLower(strings.TrimSpace(line))
+	if line == "" || strings.HasPrefix(line, "!") {
+		return nil, nil
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) > 1 {
+		return nil, fmt.Errorf("%w: expected one domain per line", errInvalidHostname)
+	}
+
+	domain := strings.TrimSuffix(fields[0], ".")
+	if !isListDomain(strings.TrimPrefix(domain, "*.")) {
+		return nil, fmt.Errorf("%w: %s", errInvalidHostname, domain)
+	}
+	return []listRule{{domain: domain, kind: ruleBlock}}, nil
+}
+
+// parseDnsmasqLine parst eine Zeile einer dnsmasq-Konfiguration
+// Unterstützt:
+//   - "address=/example.com/0.0.0.0", "address=/example.com/" oder "address=/example.com/#": blockiert
+//   - "address=/nas.home/192.168.1.10": lokaler Record
+//   - "server=/example.com/" und "local=/example.com/": blockiert (keine Weiterleitung)
+//   - "server=/example.com/#": Ausnahme (normale Auflösung)
+//
+// Einträge gelten wie bei dnsmasq für die Domain und alle Subdomains, eine Zeile darf
+// mehrere Domains enthalten ("address=/a.com/b.com/0.0.0.0")
+func parseDnsmasqLine(line string) ([]listRule, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	option, value, ok := strings.Cut(line, "=")
+	if !ok || !strings.HasPrefix(value, "/") {
+		return nil, fmt.Errorf("%w: dnsmasq option %s", errUnsupportedRule, option)
+	}
+
+	parts := strings.Split(value[1:], "/")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("%w: missing closing slash", errMissingHostname)
+	}
+	target := parts[len(parts)-1]
+	names := parts[:len(parts)-1]
+
+	var rule func(domain string) (listRule, error)
+	switch option {
+	case "address":
+		if target == "" || target == "#" {
+			rule = blockRule
+			break
+		}
+		addr, err := netip.ParseAddr(target)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errInvalidAddress, target)
+		}
+		rule = func(domain string) (listRule, error) { return addressRule("*."+domain, addr) }
+	case "server", "local":
+		switch target {
+		case "":
+			rule = blockRule
+		case "#":
+			rule = exceptionRule
+		default:
+			// Weiterleitung an einen anderen Server, keine Block-Regel
+			return nil, fmt.Errorf("%w: upstream server %s", errUnsupportedRule, target)
+		}
+	default:
+		return nil, fmt.Errorf("%w: dnsmasq option %s", errUnsupportedRule, option)
+	}
+
+	return collectRules(names, rule)
+}
+
+// parseUnboundLine parst eine Zeile einer unbound-Konfiguration
+// Unterstützt:
+//   - local-zone: "example.com" always_nxdomain (ebenso static, refuse, deny, always_null, ...): blockiert
+//   - local-zone: "example.com" always_transparent: Ausnahme
+//   - local-data: "example.com A 0.0.0.0": blockiert, mit routbarer IP ein lokaler Record
+//
+// local-zone gilt für die Domain und alle Subdomains, local-data nur für den Namen selbst
+func parseUnboundLine(line string) ([]listRule, error) {
+	if idx := strings.IndexByte(line, '#'); idx >= 0 {
+		line = line[:idx]
+	}
+	line = strings.TrimSpace(line)
+	if line == "" || line == "server:" {
+		return nil, nil
+	}
+
+	key, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return nil, fmt.Errorf("%w: unbound line without option", errUnsupportedRule)
+	}
+	value = strings.TrimSpace(value)
+
+	switch strings.TrimSpace(key) {
+	case "local-zone":
+		fields := strings.Fields(value)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%w: local-zone without type", errMissingHostname)
+		}
+		name := strings.Trim(fields[0], `"`)
+		switch fields[1] {
+		case "always_nxdomain", "always_refuse", "always_null", "always_deny", "static",
+			"refuse", "deny", "inform_deny", "redirect":
+			return collectRules([]string{name}, blockRule)
+		case "always_transparent":
+			return collectRules([]string{name}, exceptionRule)
+		default:
+			return nil, fmt.Errorf("%w: local-zone type %s", errUnsupportedRule, fields[1])
+		}
+	case "local-data":
+		fields := strings.Fields(strings.Trim(value, `"`))
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%w: incomplete local-data", errMissingHostname)
+		}
+		name := strings.ToLower(strings.TrimSuffix(fields[0], "."))
+		if !isListDomain(name) {
+			return nil, fmt.Errorf("%w: %s", errInvalidHostname, name)
+		}
+		rrtype, rdata := strings.ToUpper(fields[len(fields)-2]), fields[len(fields)-1]
+		if rrtype != "A" && rrtype != "AAAA" {
+			return nil, fmt.Errorf("%w: local-data type %s", errUnsupportedRule, rrtype)
+		}
+		addr, err := netip.ParseAddr(rdata)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errInvalidAddress, rdata)
+		}
+		rule, err := addressRule(name, addr)
+		if err != nil {
+			return nil, err
+		}
+		return []listRule{rule}, nil
+	default:
+		return nil, fmt.Errorf("%w: unbound option %s", errUnsupportedRule, key)
+	}
+}
+
+// rpzParser parst eine RPZ-Zonendatei (Response Policy Zone) zeilenweise
+// Der Parser merkt sich $ORIGIN und überspringt mehrzeilige Records in Klammern (SOA)
+type rpzParser struct {
+	origin string // aktueller $ORIGIN ohne abschließenden Punkt
+	depth  int    // offene Klammern eines mehrzeiligen Records
+}
+
+// parseLine parst eine Zeile einer RPZ-Zone
+// Unterstützte Aktionen:
+//   - "example.com CNAME ." (NXDOMAIN), "CNAME *." (NODATA), "CNAME rpz-drop.": blockiert
+//   - "example.com CNAME rpz-passthru.": Ausnahme
+//   - "nas.home A 192.168.1.10": lokaler Record, "A 0.0.0.0" blockiert
+//
+// Wie alle Listen antwortet der Proxy mit der Block-Antwort der Liste, nicht mit der RPZ-Aktion.
+// Trigger auf IPs oder Nameserver (rpz-ip, rpz-nsdname, ...) und CNAME-Umschreibungen
+// werden nicht unterstützt und übersprungen.
+func (p *rpzParser) parseLine(line string) ([]listRule, error) {
+	if idx := strings.IndexByte(line, ';'); idx >= 0 {
+		line = line[:idx]
+	}
+
+	// Mehrzeilige Records (z.B. SOA in Klammern) überspringen
+	opened := p.depth > 0
+	p.depth += strings.Count(line, "(") - strings.Count(line, ")")
+	if opened || strings.Contains(line, "(") {
+		return nil, nil
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	switch strings.ToUpper(fields[0]) {
+	case "$ORIGIN":
+		if len(fields) > 1 {
+			p.origin = strings.ToLower(strings.TrimSuffix(fields[1], "."))
+		}
+		return nil, nil
+	case "$TTL", "$INCLUDE", "@":
+		return nil, nil
+	}
+
+	// Eigentümer [TTL] [Klasse] Typ Daten
+	owner := strings.ToLower(fields[0])
+	rest := fields[1:]
+	for len(rest) > 0 && (isTTLField(rest[0]) || strings.EqualFold(rest[0], "IN")) {
+		rest = rest[1:]
+	}
+	if len(rest) < 2 {
+		return nil, fmt.Errorf("%w: incomplete record", errMissingHostname)
+	}
+	rrtype, rdata := strings.ToUpper(rest[0]), strings.ToLower(rest[1])
+
+	domain := p.ownerName(owner)
+	if strings.Contains(domain, ".rpz-") {
+		return nil, fmt.Errorf("%w: trigger %s", errUnsupportedRule, owner)
+	}
+
+	switch rrtype {
+	case "SOA", "NS":
+		return nil, nil
+	}
+
+	// Wie in der Zone gilt "example.com" nur für den Namen selbst, "*.example.com" für Subdomains
+	if !isListDomain(strings.TrimPrefix(domain, "*.")) {
+		return nil, fmt.Errorf("%w: %s", errInvalidHostname, domain)
+	}
+
+	switch rrtype {
+	case "CNAME":
+		switch rdata {
+		case ".", "*.", "rpz-drop.":
+			return []listRule{{domain: domain, kind: ruleBlock}}, nil
+		case "rpz-passthru.":
+			return []listRule{{domain: domain, kind: ruleException}}, nil
+		default:
+			return nil, fmt.Errorf("%w: CNAME rewrite to %s", errUnsupportedRule, rdata)
+		}
+	case "A", "AAAA":
+		addr, err := netip.ParseAddr(rdata)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errInvalidAddress, rdata)
+		}
+		rule, err := addressRule(domain, addr)
+		if err != nil {
+			return nil, err
+		}
+		return []listRule{rule}, nil
+	default:
+		return nil, fmt.Errorf("%w: record type %s", errUnsupportedRule, rrtype)
+	}
+}
+
+// ownerName entfernt den $ORIGIN von einem absoluten Namen
+// Relative Namen sind bereits relativ zur RPZ-Zone und entsprechen der Domain
+func (p *rpzParser) ownerName(owner string) string {
+	if !strings.HasSuffix(owner, ".") {
+		return owner
+	}
+	owner = strings.TrimSuffix(owner, ".")
+	if p.origin != "" {
+		owner = strings.TrimSuffix(owner, "."+p.origin)
+	}
+	return owner
+}
+
+// isTTLField prüft, ob ein Feld eine TTL ist ("3600", "1h")
+func isTTLField(field string) bool {
+	return field != "" && field[0] >= '0' && field[0] <= '9'
+}
+
+// blockRule erstellt eine Block-Regel für die Domain und alle Subdomains
+func blockRule(domain string) (listRule, error) {
+	return listRule{domain: "*." + domain, kind: ruleBlock}, nil
+}
+
+// exceptionRule erstellt eine Ausnahme für die Domain und alle Subdomains
+func exceptionRule(domain string) (listRule, error) {
+	return listRule{domain: "*." + domain, kind: ruleException}, nil
+}
+
+// collectRules prüft die Namen und erstellt für jeden gültigen Namen eine Regel
+// Ungültige Namen werden gemeldet, die Regeln der gültigen Namen trotzdem zurückgegeben
+func collectRules(names []string, rule func(domain string) (listRule, error)) ([]listRule, error) {
+	var rules []listRule
+	var invalid []string
+
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if !isListDomain(name) {
+			invalid = append(invalid, name)
+			continue
+		}
+		r, err := rule(name)
+		if err != nil {
+			return rules, err
+		}
+		rules = append(rules, r)
+	}
+
+	if len(invalid) > 0 {
+		return rules, fmt.Errorf("%w: %s", errInvalidHostname, strings.Join(invalid, ", "))
+	}
+	return rules, nil
+}
@@ -0,0 +1,146 @@
+package dns
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// formatRules gibt die Regeln einer Zeile als lesbare Liste zurück
+// Block-Regeln erscheinen als Domain, Ausnahmen mit "@@", lokale Records als "Domain=IP"
+func formatRules(rules []listRule) string {
+	var parts []string
+	for _, rule := range rules {
+		switch {
+		case rule.addr.IsValid():
+			parts = append(parts, rule.domain+"="+rule.addr.String())
+		case rule.kind == ruleException:
+			parts = append(parts, "@@"+rule.domain)
+		default:
+			parts = append(parts, rule.domain)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseListLines(t *testing.T) {
+	rpz := func(line string) ([]listRule, error) {
+		p := &rpzParser{origin: "rpz.local"}
+		return p.parseLine(line)
+	}
+
+	tests := []struct {
+		name    string
+		parse   lineParser
+		line    string
+		want    string
+		wantErr error
+	}{
+		{name: "Domain", parse: parseDomainLine, line: "Ads.Example.com", want: "ads.example.com"},
+		{name: "Domain wildcard", parse: parseDomainLine, line: "*.ads.example.com", want: "*.ads.example.com"},
+		{name: "Domain trailing dot and comment", parse: parseDomainLine, line: "ads.example.com. # tracker", want: "ads.example.com"},
+		{name: "Domain comment", parse: parseDomainLine, line: "! Title: list", want: ""},
+		{name: "Domain invalid", parse: parseDomainLine, line: "bad_name.example.com", wantErr: errInvalidHostname},
+		{name: "Domain two fields", parse: parseDomainLine, line: "0.0.0.0 ads.example.com", wantErr: errInvalidHostname},
+
+		{name: "Dnsmasq address", parse: parseDnsmasqLine, line: "address=/ads.example.com/0.0.0.0", want: "*.ads.example.com"},
+		{name: "Dnsmasq NXDOMAIN", parse: parseDnsmasqLine, line: "address=/ads.example.com/", want: "*.ads.example.com"},
+		{name: "Dnsmasq multiple domains", parse: parseDnsmasqLine, line: "address=/a.example.com/b.example.com/#", want: "*.a.example.com *.b.example.com"},
+		{name: "Dnsmasq local record", parse: parseDnsmasqLine, line: "address=/nas.home/192.168.1.10", want: "nas.home=192.168.1.10"},
+		{name: "Dnsmasq server block", parse: parseDnsmasqLine, line: "server=/ads.example.com/", want: "*.ads.example.com"},
+		{name: "Dnsmasq server exception", parse: parseDnsmasqLine, line: "server=/ok.example.com/#", want: "@@*.ok.example.com"},
+		{name: "Dnsmasq forward", parse: parseDnsmasqLine, line: "server=/corp.example.com/10.0.0.1", wantErr: errUnsupportedRule},
+		{name: "Dnsmasq other option", parse: parseDnsmasqLine, line: "cache-size=1000", wantErr: errUnsupportedRule},
+		{name: "Dnsmasq invalid IP", parse: parseDnsmasqLine, line: "address=/ads.example.com/1.2.3", wantErr: errInvalidAddress},
+		{name: "Dnsmasq invalid domain", parse: parseDnsmasqLine, line: "address=/-bad.example.com/0.0.0.0", wantErr: errInvalidHostname},
+
+		{name: "Unbound header", parse: parseUnboundLine, line: "server:", want: ""},
+		{name: "Unbound local-zone", parse: parseUnboundLine, line: `local-zone: "ads.example.com" always_nxdomain`, want: "*.ads.example.com"},
+		{name: "Unbound static with dot", parse: parseUnboundLine, line: `local-zone: "ads.example.com." static`, want: "*.ads.example.com"},
+		{name: "Unbound transparent", parse: parseUnboundLine, line: `local-zone: "ok.example.com" always_transparent`, want: "@@*.ok.example.com"},
+		{name: "Unbound typetransparent", parse: parseUnboundLine, line: `local-zone: "ok.example.com" typetransparent`, wantErr: errUnsupportedRule},
+		{name: "Unbound local-data block", parse: parseUnboundLine, line: `local-data: "ads.example.com A 0.0.0.0"`, want: "ads.example.com"},
+		{name: "Unbound local-data record", parse: parseUnboundLine, line: `local-data: "nas.home. 3600 IN AAAA 2001:db8::10"`, want: "nas.home=2001:db8::10"},
+		{name: "Unbound local-data TXT", parse: parseUnboundLine, line: `local-data: "ads.example.com TXT blocked"`, wantErr: errUnsupportedRule},
+
+		{name: "RPZ NXDOMAIN", parse: rpz, line: "ads.example.com CNAME .", want: "ads.example.com"},
+		{name: "RPZ wildcard NODATA", parse: rpz, line: "*.ads.example.com 300 IN CNAME *.", want: "*.ads.example.com"},
+		{name: "RPZ absolute name", parse: rpz, line: "ads.example.com.rpz.local. CNAME rpz-drop.", want: "ads.example.com"},
+		{name: "RPZ passthru", parse: rpz, line: "ok.example.com CNAME rpz-passthru. ; allow", want: "@@ok.example.com"},
+		{name: "RPZ local record", parse: rpz, line: "nas.home A 192.168.1.10", want: "nas.home=192.168.1.10"},
+		{name: "RPZ null IP", parse: rpz, line: "ads.example.com A 0.0.0.0", want: "ads.example.com"},
+		{name: "RPZ NS", parse: rpz, line: "@ IN NS localhost.", want: ""},
+		{name: "RPZ rewrite", parse: rpz, line: "www.example.com CNAME safe.example.com.", wantErr: errUnsupportedRule},
+		{name: "RPZ IP trigger", parse: rpz, line: "32.1.2.0.192.rpz-ip CNAME .", wantErr: errUnsupportedRule},
+		{name: "RPZ invalid name", parse: rpz, line: "bad_name.example.com CNAME .", wantErr: errInvalidHostname},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := tt.parse(tt.line)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("parse(%q) error = %v, want %v", tt.line, err, tt.wantErr)
+			}
+			if got := formatRules(rules); got != tt.want {
+				t.Errorf("parse(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlacklist_LoadFromContent_RPZ(t *testing.T) {
+	bl := NewBlacklist()
+
+	content := `$TTL 300
+@ IN SOA localhost. root.localhost. (
+        2024010101 ; serial
+        43200      ; refresh
+        3600       ; retry
+        604800     ; expire
+        300 )      ; minimum
+  IN NS localhost.
+
+ads.example.com      CNAME .
+*.ads.example.com    CNAME .
+ok.ads.example.com   CNAME rpz-passthru.
+nas.home             A     192.168.1.10
+tracker.example.com  CNAME safe.example.com.
+bad_name.example.com CNAME .
+`
+
+	added, err := bl.LoadFromContent(content, FormatAuto)
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) || loadErr.Rejected != 1 {
+		t.Fatalf("LoadFromContent() error = %v, want one rejected line", err)
+	}
+	if added != 4 {
+		t.Errorf("LoadFromContent() added = %d, want 4", added)
+	}
+
+	if !bl.IsBlocked("ads.example.com") || !bl.IsBlocked("x.ads.example.com") {
+		t.Error("ads.example.com and subdomains should be blocked")
+	}
+	if bl.IsBlocked("ok.ads.example.com") {
+		t.Error("rpz-passthru should be an exception")
+	}
+	if bl.IsBlocked("tracker.example.com") {
+		t.Error("CNAME rewrites are not supported and should not block")
+	}
+	if ips := bl.LocalAddresses("nas.home"); len(ips) != 1 {
+		t.Errorf("LocalAddresses() = %v, want local record", ips)
+	}
+}
+
+func TestBlacklist_LoadList_Formats(t *testing.T) {
+	bl := NewBlacklist()
+	bl.AddList(ListInfo{Name: "dnsmasq", Format: FormatDnsmasq, Enabled: true})
+
+	// Ohne explizites Format würde die Zeile als Domain-Liste erkannt
+	if _, err := bl.LoadListContent("dnsmasq", "address=/ads.example.com/0.0.0.0\n"); err != nil {
+		t.Fatalf("LoadListContent() error = %v", err)
+	}
+	match := bl.Match("cdn.ads.example.com", 0)
+	if !match.Blocked || match.List != "dnsmasq" || match.Rule != "*.ads.example.com" {
+		t.Errorf("Match() = %+v", match)
+	}
+}
@@ -0,0 +1,298 @@
+package dns
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RebindingRule ist die Regel in IPMatch, wenn eine Antwort wegen DNS-Rebinding blockiert wurde
+const RebindingRule = "rebinding"
+
+// privateSuffixes sind Namen, die nicht öffentlich aufgelöst werden und daher auf private
+// Adressen zeigen dürfen
+var privateSuffixes = []string{"localhost", "local", "lan", "internal", "home.arpa"}
+
+// IPMatch beschreibt die Adresse einer Antwort, die eine IP-Regel getroffen hat
+type IPMatch struct {
+	IP        string // die blockierte Adresse der Antwort
+	Rule      string // getroffenes Netz (z.B. "203.0.113.0/24") oder RebindingRule
+	Rebinding bool   // öffentlicher Name mit privater Adresse
+}
+
+// IPFilter blockiert Upstream-Antworten anhand der aufgelösten Adressen
+// Die Blacklist prüft nur die angefragten Namen; der IPFilter prüft die Antwort gegen
+// IP/CIDR-Regeln (z.B. Threat-Intel-Listen) und schützt optional vor DNS-Rebinding.
+type IPFilter struct {
+	prefixes   []netip.Prefix // sortiert und überschneidungsfrei, für die Binärsuche
+	rebinding  bool
+	exemptions *domainSet // Domains, die auf private Adressen zeigen dürfen
+	mu         sync.RWMutex
+}
+
+// NewIPFilter erstellt einen neuen leeren IPFilter ohne Rebinding-Schutz
+func NewIPFilter() *IPFilter {
+	return &IPFilter{
+		exemptions: newDomainSet(),
+	}
+}
+
+// parseCIDR parst ein Netz ("203.0.113.0/24") oder eine einzelne Adresse ("203.0.113.7")
+func parseCIDR(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("%w: %s", errInvalidAddress, s)
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), max(prefix.Bits()-96, 0))
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%w: %s", errInvalidAddress, s)
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// normalizePrefixes sortiert die Netze und entfernt Netze, die in einem anderen enthalten sind
+// CIDR-Netze sind entweder verschachtelt oder disjunkt, danach sind sie also überschneidungsfrei
+func normalizePrefixes(prefixes []netip.Prefix) []netip.Prefix {
+	slices.SortFunc(prefixes, func(a, b netip.Prefix) int {
+		if c := a.Addr().Compare(b.Addr()); c != 0 {
+			return c
+		}
+		return a.Bits() - b.Bits()
+	})
+
+	out := prefixes[:0]
+	for _, prefix := range prefixes {
+		if n := len(out); n > 0 && out[n-1].Contains(prefix.Addr()) {
+			continue
+		}
+		out = append(out, prefix)
+	}
+	return slices.Clip(out)
+}
+
+// AddCIDR fügt ein Netz oder eine einzelne Adresse hinzu
+func (f *IPFilter) AddCIDR(cidr string) error {
+	prefix, err := parseCIDR(strings.TrimSpace(cidr))
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.prefixes = normalizePrefixes(append(slices.Clone(f.prefixes), prefix))
+	return nil
+}
+
+// GetAllCIDRs gibt alle Netze zurück (in einem größeren Netz enthaltene Netze sind zusammengefasst)
+func (f *IPFilter) GetAllCIDRs() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	cidrs := make([]string, len(f.prefixes))
+	for i, prefix := range f.prefixes {
+		cidrs[i] = prefix.String()
+	}
+	return cidrs
+}
+
+// Count gibt die Anzahl der Netze zurück
+func (f *IPFilter) Count() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return len(f.prefixes)
+}
+
+// Clear entfernt alle Netze, Rebinding-Schutz und Ausnahmen bleiben erhalten
+func (f *IPFilter) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.prefixes = nil
+}
+
+// SetRebindingProtection aktiviert den Schutz vor DNS-Rebinding
+// Öffentliche Namen, die auf RFC-1918-, Loopback- oder Link-Local-Adressen zeigen, werden blockiert
+func (f *IPFilter) SetRebindingProtection(enabled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rebinding = enabled
+}
+
+// AddRebindingExemption erlaubt einer Domain, auf private Adressen zu zeigen
+// Unterstützt Wildcards (z.B. "*.fritz.box")
+func (f *IPFilter) AddRebindingExemption(domain string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.exemptions.add(domain)
+}
+
+// RemoveRebindingExemption entfernt eine Ausnahme vom Rebinding-Schutz
+func (f *IPFilter) RemoveRebindingExemption(domain string) error {
+	if domain == "" {
+		return fmt.Errorf("domain cannot be empty")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.exemptions.remove(domain)
+	return nil
+}
+
+// Check prüft die Adressen einer Antwort für domain
+// Gibt den ersten Treffer zurück; gelistete Netze haben Vorrang vor dem Rebinding-Schutz
+func (f *IPFilter) Check(domain string, ips []string) (IPMatch, bool) {
+	domain = normalizeDomain(domain)
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	rebinding := f.rebinding && isPublicName(domain) && !f.exemptions.contains(domain)
+
+	var rebindMatch IPMatch
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			continue
+		}
+		addr = addr.Unmap().WithZone("")
+
+		if prefix, ok := f.matchPrefix(addr); ok {
+			return IPMatch{IP: ip, Rule: prefix.String()}, true
+		}
+		if rebinding && rebindMatch.IP == "" && isRebindingAddress(addr) {
+			rebindMatch = IPMatch{IP: ip, Rule: RebindingRule, Rebinding: true}
+		}
+	}
+
+	return rebindMatch, rebindMatch.IP != ""
+}
+
+// matchPrefix sucht das Netz, das addr enthält
+// Da die Netze überschneidungsfrei sind, kommt nur das letzte mit Anfang <= addr infrage
+func (f *IPFilter) matchPrefix(addr netip.Addr) (netip.Prefix, bool) {
+	i := sort.Search(len(f.prefixes), func(i int) bool {
+		return f.prefixes[i].Addr().Compare(addr) > 0
+	}) - 1
+	if i >= 0 && f.prefixes[i].Contains(addr) {
+		return f.prefixes[i], true
+	}
+	return netip.Prefix{}, false
+}
+
+// isRebindingAddress prüft, ob eine Adresse nur im lokalen Netz erreichbar ist
+// (RFC 1918 und ULA, Loopback, Link-Local)
+func isRebindingAddress(addr netip.Addr) bool {
+	return addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast()
+}
+
+// isPublicName prüft, ob ein Name öffentlich aufgelöst wird
+// Namen ohne Punkt und lokale Zonen ("nas.local", "router.home.arpa") zählen nicht dazu
+func isPublicName(domain string) bool {
+	if !strings.Contains(domain, ".") {
+		return false
+	}
+	for _, suffix := range privateSuffixes {
+		if domain == suffix || strings.HasSuffix(domain, "."+suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseIPFilterLine parst eine Zeile einer IP-Liste
+// Erlaubt sind Netze und Adressen, Kommentare mit "#" oder ";" (Spamhaus DROP: "1.2.3.0/24 ; SBL123")
+func parseIPFilterLine(line string) (netip.Prefix, bool, error) {
+	if idx := strings.IndexAny(line, "#;"); idx >= 0 {
+		line = line[:idx]
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return netip.Prefix{}, false, nil
+	}
+
+	prefix, err := parseCIDR(fields[0])
+	if err != nil {
+		return netip.Prefix{}, false, err
+	}
+	return prefix, true, nil
+}
+
+// LoadFromContent lädt Netze aus einem Listen-Inhalt
+// Gibt die Anzahl der hinzugefügten Netze zurück
+func (f *IPFilter) LoadFromContent(content string) (int, error) {
+	return f.LoadFromReader(strings.NewReader(content))
+}
+
+// LoadFromReader liest eine IP-Liste zeilenweise (auch gzip- oder zstd-komprimiert)
+// Gültige Einträge werden immer übernommen, ungültige Zeilen fasst ein *LoadError zusammen
+func (f *IPFilter) LoadFromReader(r io.Reader) (int, error) {
+	rc, err := openListReader(r, DefaultMaxListSize)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	var prefixes []netip.Prefix
+	var loadErr LoadError
+	err = scanLines(rc, func(lineNo int, line string) {
+		prefix, ok, err := parseIPFilterLine(line)
+		if err != nil {
+			loadErr.add(lineNo, line, err)
+			return
+		}
+		if ok {
+			prefixes = append(prefixes, prefix)
+		}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read list: %w", err)
+	}
+
+	f.mu.Lock()
+	f.prefixes = normalizePrefixes(append(slices.Clone(f.prefixes), prefixes...))
+	f.mu.Unlock()
+
+	return len(prefixes), loadErr.errorOrNil()
+}
+
+// LoadFromURL lädt eine IP-Liste von einer URL
+// Unterstützt HTTP und HTTPS URLs
+func (f *IPFilter) LoadFromURL(url string) (int, error) {
+	body, err := openURL(url)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	return f.LoadFromReader(body)
+}
+
+// LoadFromFile lädt eine IP-Liste vom Dateisystem
+func (f *IPFilter) LoadFromFile(filepath string) (int, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	return f.LoadFromReader(file)
+}
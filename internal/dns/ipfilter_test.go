@@ -0,0 +1,106 @@
+package dns
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestIPFilter_AddCIDR(t *testing.T) {
+	f := NewIPFilter()
+
+	for _, cidr := range []string{"203.0.113.0/24", "203.0.113.128/25", "198.51.100.7", "2001:db8::/32", "::ffff:192.0.2.0/120"} {
+		if err := f.AddCIDR(cidr); err != nil {
+			t.Fatalf("AddCIDR(%q) error = %v", cidr, err)
+		}
+	}
+	for _, cidr := range []string{"", "203.0.113.0/33", "not-an-ip"} {
+		if err := f.AddCIDR(cidr); !errors.Is(err, errInvalidAddress) {
+			t.Errorf("AddCIDR(%q) error = %v, want invalid IP address", cidr, err)
+		}
+	}
+
+	// Das /25 liegt im /24 und wird zusammengefasst, IPv4-mapped wird zu IPv4
+	want := []string{"192.0.2.0/24", "198.51.100.7/32", "203.0.113.0/24", "2001:db8::/32"}
+	if got := f.GetAllCIDRs(); !slices.Equal(got, want) {
+		t.Errorf("GetAllCIDRs() = %v, want %v", got, want)
+	}
+
+	f.Clear()
+	if f.Count() != 0 {
+		t.Errorf("Count() after Clear() = %d, want 0", f.Count())
+	}
+}
+
+func TestIPFilter_Check(t *testing.T) {
+	f := NewIPFilter()
+	f.AddCIDR("203.0.113.0/24")
+	f.AddCIDR("198.51.100.7")
+	f.AddCIDR("2001:db8::/32")
+	f.AddCIDR("10.10.0.0/16")
+	f.SetRebindingProtection(true)
+	f.AddRebindingExemption("*.fritz.box")
+
+	tests := []struct {
+		name   string
+		domain string
+		ips    []string
+		want   IPMatch
+	}{
+		{name: "Listed network", domain: "example.com", ips: []string{"93.184.216.34", "203.0.113.9"}, want: IPMatch{IP: "203.0.113.9", Rule: "203.0.113.0/24"}},
+		{name: "Listed address", domain: "example.com", ips: []string{"198.51.100.7"}, want: IPMatch{IP: "198.51.100.7", Rule: "198.51.100.7/32"}},
+		{name: "Neighbour of listed address", domain: "example.com", ips: []string{"198.51.100.8"}},
+		{name: "Listed IPv6 network", domain: "example.com", ips: []string{"2001:db8::1"}, want: IPMatch{IP: "2001:db8::1", Rule: "2001:db8::/32"}},
+		{name: "Public address", domain: "example.com", ips: []string{"93.184.216.34", "2606:2800::1"}},
+		{name: "Rebinding RFC 1918", domain: "evil.example.com", ips: []string{"93.184.216.34", "192.168.1.1"}, want: IPMatch{IP: "192.168.1.1", Rule: RebindingRule, Rebinding: true}},
+		{name: "Rebinding loopback", domain: "evil.example.com", ips: []string{"127.0.0.1"}, want: IPMatch{IP: "127.0.0.1", Rule: RebindingRule, Rebinding: true}},
+		{name: "Rebinding link-local IPv6", domain: "evil.example.com", ips: []string{"fe80::1"}, want: IPMatch{IP: "fe80::1", Rule: RebindingRule, Rebinding: true}},
+		{name: "Listed network before rebinding", domain: "evil.example.com", ips: []string{"172.16.0.1", "10.10.1.1"}, want: IPMatch{IP: "10.10.1.1", Rule: "10.10.0.0/16"}},
+		{name: "Exempt domain", domain: "Router.Fritz.Box", ips: []string{"192.168.178.1"}},
+		{name: "Local zone", domain: "nas.home.arpa", ips: []string{"192.168.1.10"}},
+		{name: "Single label", domain: "printer", ips: []string{"192.168.1.20"}},
+		{name: "Unparseable address", domain: "example.com", ips: []string{"bogus"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, blocked := f.Check(tt.domain, tt.ips)
+			if got != tt.want || blocked != (tt.want.IP != "") {
+				t.Errorf("Check(%q, %v) = %+v, %v, want %+v", tt.domain, tt.ips, got, blocked, tt.want)
+			}
+		})
+	}
+
+	// Ohne Rebinding-Schutz sind private Adressen erlaubt
+	f.SetRebindingProtection(false)
+	if match, blocked := f.Check("evil.example.com", []string{"192.168.1.1"}); blocked {
+		t.Errorf("Check() without rebinding protection = %+v, want allowed", match)
+	}
+}
+
+func TestIPFilter_LoadFromContent(t *testing.T) {
+	content := `# Threat-Intel-Liste
+; Spamhaus DROP
+203.0.113.0/24 ; SBL123
+198.51.100.7   # einzelne Adresse
+2001:db8::/32
+not-a-network
+192.0.2.0/40
+`
+	f := NewIPFilter()
+	added, err := f.LoadFromContent(content)
+	if added != 3 {
+		t.Errorf("LoadFromContent() added = %d, want 3", added)
+	}
+
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("LoadFromContent() error = %v, want *LoadError", err)
+	}
+	if loadErr.Rejected != 2 || loadErr.Reasons["invalid IP address"] != 2 || loadErr.Lines[0].Line != 6 {
+		t.Errorf("LoadError = %+v, want 2 invalid addresses starting at line 6", loadErr)
+	}
+	if f.Count() != 3 {
+		t.Errorf("Count() = %d, want 3", f.Count())
+	}
+}
@@ -0,0 +1,228 @@
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// LineError beschreibt eine ungültige Zeile beim Laden einer Liste
+type LineError struct {
+	Line    int    // Zeilennummer, beginnend bei 1
+	Content string // Zeile ohne führende und folgende Leerzeichen
+	Err     error
+}
+
+// Error gibt Zeilennummer, Fehler und Inhalt der Zeile aus
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v: %q", e.Line, e.Err, e.Content)
+}
+
+// Unwrap gibt den ursprünglichen Fehler zurück
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Gründe für abgelehnte Zeilen, über errors.Is prüfbar
+var (
+	errInvalidHostname = errors.New("invalid hostname")
+	errInvalidAddress  = errors.New("invalid IP address")
+	errMissingHostname = errors.New("missing hostname")
+	errNonRoutable     = errors.New("non-routable address")
+)
+
+// maxReportedLines begrenzt die Anzahl der Zeilen, die ein LoadError im Detail enthält,
+// maxErrorLines die Anzahl davon, die in der Fehlermeldung erscheinen
+const (
+	maxReportedLines = 20
+	maxErrorLines    = 3
+)
+
+// LoadError fasst die abgelehnten Zeilen beim Laden einer Liste zusammen
+// Alle gültigen Zeilen wurden trotzdem geladen
+type LoadError struct {
+	Rejected int            // Anzahl abgelehnter Zeilen
+	Reasons  map[string]int // Grund (z.B. "invalid hostname") -> Anzahl
+	Lines    []*LineError   // die ersten abgelehnten Zeilen im Detail
+}
+
+// Error gibt eine Zusammenfassung mit Gründen und den ersten abgelehnten Zeilen aus
+func (e *LoadError) Error() string {
+	reasons := make([]string, 0, len(e.Reasons))
+	for reason, count := range e.Reasons {
+		reasons = append(reasons, fmt.Sprintf("%s: %d", reason, count))
+	}
+	sort.Strings(reasons)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d lines rejected (%s)", e.Rejected, strings.Join(reasons, ", "))
+	for i, line := range e.Lines {
+		if i == maxErrorLines {
+			b.WriteString("; ...")
+			break
+		}
+		b.WriteString("; ")
+		b.WriteString(line.Error())
+	}
+	return b.String()
+}
+
+// Unwrap gibt die gespeicherten Zeilenfehler zurück (für errors.Is / errors.As)
+func (e *LoadError) Unwrap() []error {
+	errs := make([]error, len(e.Lines))
+	for i, line := range e.Lines {
+		errs[i] = line
+	}
+	return errs
+}
+
+// add zählt eine abgelehnte Zeile
+func (e *LoadError) add(line int, content string, err error) {
+	e.Rejected++
+	if e.Reasons == nil {
+		e.Reasons = make(map[string]int)
+	}
+	e.Reasons[rejectReason(err)]++
+	if len(e.Lines) < maxReportedLines {
+		e.Lines = append(e.Lines, &LineError{Line: line, Content: strings.TrimSpace(content), Err: err})
+	}
+}
+
+// errorOrNil gibt den LoadError nur zurück, wenn Zeilen abgelehnt wurden
+func (e *LoadError) errorOrNil() error {
+	if e.Rejected == 0 {
+		return nil
+	}
+	return e
+}
+
+// rejectReason ordnet einen Fehler einem Grund für die Zusammenfassung zu
+func rejectReason(err error) string {
+	for _, reason := range []error{errInvalidHostname, errInvalidAddress, errMissingHostname, errNonRoutable} {
+		if errors.Is(err, reason) {
+			return reason.Error()
+		}
+	}
+	return "invalid rule"
+}
+
+// ListFormat beschreibt das Format einer Blockliste
+type ListFormat int
+
+const (
+	// FormatAuto erkennt das Format anhand des Inhalts
+	FormatAuto ListFormat = iota
+	// FormatHosts: "0.0.0.0 example.com"
+	FormatHosts
+	// FormatAdblock: "||example.com^", "@@||example.com^", "$important", "$dnstype="
+	FormatAdblock
+	// FormatDomains: eine Domain pro Zeile, "example.com" oder "*.example.com"
+	FormatDomains
+	// FormatDnsmasq: "address=/example.com/0.0.0.0", "server=/example.com/"
+	FormatDnsmasq
+	// FormatUnbound: "local-zone: \"example.com\" always_nxdomain", "local-data: ..."
+	FormatUnbound
+	// FormatRPZ: Response Policy Zone, "example.com CNAME ."
+	FormatRPZ
+)
+
+// listFormatNames ordnet die Formate ihren Namen zu
+var listFormatNames = map[ListFormat]string{
+	FormatAuto:    "auto",
+	FormatHosts:   "hosts",
+	FormatAdblock: "adblock",
+	FormatDomains: "domains",
+	FormatDnsmasq: "dnsmasq",
+	FormatUnbound: "unbound",
+	FormatRPZ:     "rpz",
+}
+
+// String gibt den Namen des Formats zurück
+func (f ListFormat) String() string {
+	if name, ok := listFormatNames[f]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseListFormat wandelt einen Namen ("hosts", "adblock", "domains", "dnsmasq", "unbound",
+// "rpz" oder "auto") in ein ListFormat um
+func ParseListFormat(name string) (ListFormat, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for format, n := range listFormatNames {
+		if n == name {
+			return format, nil
+		}
+	}
+	return FormatAuto, fmt.Errorf("unknown list format: %s", name)
+}
+
+// formatDetectLines begrenzt die Anzahl der Zeilen, die für die Erkennung gelesen werden
+const formatDetectLines = 200
+
+// detectListFormat erkennt das Format einer Liste anhand der ersten Regeln
+//   - Adblock-Header, "!"-Kommentare oder Adblock-Syntax ("||", "@@", "^", "/regex/"): FormatAdblock
+//   - "address=/", "server=/", "local=/": FormatDnsmasq
+//   - "server:", "local-zone:", "local-data:": FormatUnbound
+//   - "$ORIGIN", "$TTL", SOA- oder CNAME-Records: FormatRPZ
+//   - "IP Hostname": FormatHosts
+//   - eine Domain pro Zeile: FormatDomains
+//
+// Ohne erkennbare Regel wird FormatHosts angenommen
+func detectListFormat(content string) ListFormat {
+	checked := 0
+	for line := range strings.Lines(content) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[Adblock") || strings.HasPrefix(line, "!") {
+			return FormatAdblock
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if format, ok := detectLineFormat(line); ok {
+			return format
+		}
+
+		checked++
+		if checked >= formatDetectLines {
+			break
+		}
+	}
+
+	return FormatHosts
+}
+
+// detectLineFormat erkennt das Format anhand einer einzelnen Regel
+func detectLineFormat(line string) (ListFormat, bool) {
+	switch {
+	case strings.HasPrefix(line, "||") || strings.HasPrefix(line, "@@") || strings.HasSuffix(line, "^") || isRegexRule(line):
+		return FormatAdblock, true
+	case strings.HasPrefix(line, "address=/") || strings.HasPrefix(line, "server=/") || strings.HasPrefix(line, "local=/"):
+		return FormatDnsmasq, true
+	case line == "server:" || strings.HasPrefix(line, "local-zone:") || strings.HasPrefix(line, "local-data:"):
+		return FormatUnbound, true
+	case strings.HasPrefix(line, "$ORIGIN") || strings.HasPrefix(line, "$TTL"):
+		return FormatRPZ, true
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) >= 2 {
+		if _, err := netip.ParseAddr(fields[0]); err == nil {
+			return FormatHosts, true
+		}
+		for _, field := range fields[1:] {
+			if strings.EqualFold(field, "SOA") || strings.EqualFold(field, "CNAME") {
+				return FormatRPZ, true
+			}
+		}
+	}
+	if len(fields) == 1 && isListDomain(strings.TrimPrefix(strings.ToLower(fields[0]), "*.")) {
+		return FormatDomains, true
+	}
+	return FormatAuto, false
+}
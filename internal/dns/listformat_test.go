@@ -0,0 +1,89 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectListFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    ListFormat
+	}{
+		{name: "Adblock header", content: "[Adblock Plus 2.0]\nexample.com\n", want: FormatAdblock},
+		{name: "Adblock comment", content: "! Title: list\n", want: FormatAdblock},
+		{name: "Adblock rule", content: "# comment\n||ads.com^\n", want: FormatAdblock},
+		{name: "Adblock exception", content: "@@||ok.com^\n", want: FormatAdblock},
+		{name: "Hosts", content: "# comment\n0.0.0.0 ads.com\n", want: FormatHosts},
+		{name: "Hosts IPv6", content: ":: ads.com\n", want: FormatHosts},
+		{name: "Plain domains", content: "# OISD\nads.com\ntracker.com\n", want: FormatDomains},
+		{name: "Wildcard domains", content: "*.ads.com\n", want: FormatDomains},
+		{name: "Dnsmasq", content: "# dnsmasq\naddress=/ads.com/0.0.0.0\n", want: FormatDnsmasq},
+		{name: "Dnsmasq server", content: "server=/ads.com/\n", want: FormatDnsmasq},
+		{name: "Unbound", content: "server:\nlocal-zone: \"ads.com\" always_nxdomain\n", want: FormatUnbound},
+		{name: "Unbound local-data", content: "local-data: \"ads.com A 0.0.0.0\"\n", want: FormatUnbound},
+		{name: "RPZ", content: "$TTL 300\n@ SOA localhost. root.localhost. (1 1h 15m 30d 2h)\n", want: FormatRPZ},
+		{name: "RPZ without header", content: "; comment\nads.com CNAME .\n", want: FormatRPZ},
+		{name: "Empty", content: "", want: FormatHosts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectListFormat(tt.content); got != tt.want {
+				t.Errorf("detectListFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlacklist_LoadFromContent(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		format    ListFormat
+		wantAdded int
+		blocked   string
+		wantErr   bool
+	}{
+		{name: "Auto hosts", content: "0.0.0.0 ads.com\n", format: FormatAuto, wantAdded: 1, blocked: "ads.com"},
+		{name: "Auto adblock", content: "||ads.com^\n", format: FormatAuto, wantAdded: 1, blocked: "sub.ads.com"},
+		{name: "Explicit adblock", content: "ads.com\n", format: FormatAdblock, wantAdded: 1, blocked: "ads.com"},
+		{name: "Explicit domains", content: "ads.com\n", format: FormatDomains, wantAdded: 1, blocked: "ads.com"},
+		{name: "Auto dnsmasq", content: "address=/ads.com/0.0.0.0\n", format: FormatAuto, wantAdded: 1, blocked: "sub.ads.com"},
+		{name: "Auto unbound", content: "local-zone: \"ads.com\" always_refuse\n", format: FormatAuto, wantAdded: 1, blocked: "ads.com"},
+		{name: "Auto RPZ", content: "$ORIGIN rpz.local.\nads.com CNAME .\n", format: FormatAuto, wantAdded: 1, blocked: "ads.com"},
+		{name: "Unknown format", content: "ads.com\n", format: ListFormat(99), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl := NewBlacklist()
+			added, err := bl.LoadFromContent(tt.content, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadFromContent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if added != tt.wantAdded {
+				t.Errorf("LoadFromContent() added = %d, want %d", added, tt.wantAdded)
+			}
+			if !bl.IsBlocked(tt.blocked) {
+				t.Errorf("%s should be blocked", tt.blocked)
+			}
+		})
+	}
+}
+
+func TestParseListFormat(t *testing.T) {
+	for format, name := range listFormatNames {
+		got, err := ParseListFormat(strings.ToUpper(name))
+		if err != nil || got != format {
+			t.Errorf("ParseListFormat(%q) = %v, %v, want %v", name, got, err, format)
+		}
+	}
+	if _, err := ParseListFormat("bind"); err == nil {
+		t.Error("ParseListFormat() with unknown name should return error")
+	}
+}
@@ -0,0 +1,187 @@
+package dns
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultMaxListSize ist die maximale Größe einer Liste nach dem Entpacken (256 MiB)
+const DefaultMaxListSize int64 = 256 << 20
+
+// maxLineLength begrenzt die Länge einer einzelnen Zeile
+const maxLineLength = 64 << 10
+
+// formatDetectBytes ist die Anzahl Bytes, die für die Format-Erkennung vorab gelesen werden
+const formatDetectBytes = 64 << 10
+
+// ErrListTooLarge wird zurückgegeben, wenn eine Liste die maximale Größe überschreitet
+// Die Liste wird dann nicht übernommen, eine abgeschnittene Liste wäre unvollständig
+var ErrListTooLarge = errors.New("list exceeds maximum size")
+
+// Magic Bytes der unterstützten Kompressionsformate
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// openListReader entpackt gzip- und zstd-komprimierte Listen (anhand der Magic Bytes)
+// und begrenzt die entpackte Größe auf maxSize Bytes
+// Unkomprimierte Listen werden unverändert durchgereicht. Der Aufrufer muss Close aufrufen,
+// die Quelle selbst wird dabei nicht geschlossen.
+func openListReader(src io.Reader, maxSize int64) (io.ReadCloser, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxListSize
+	}
+
+	buffered := bufio.NewReader(src)
+	magic, _ := buffered.Peek(len(zstdMagic))
+
+	var rc io.ReadCloser
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip list: %w", err)
+		}
+		rc = gz
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zstd list: %w", err)
+		}
+		rc = zr.IOReadCloser()
+	default:
+		rc = io.NopCloser(buffered)
+	}
+
+	return &limitedReader{ReadCloser: rc, remaining: maxSize, max: maxSize}, nil
+}
+
+// limitedReader liefert ErrListTooLarge statt io.EOF, sobald mehr als max Bytes gelesen wurden
+// (io.LimitReader würde die Liste still abschneiden)
+type limitedReader struct {
+	io.ReadCloser
+	remaining int64
+	max       int64
+}
+
+// Read liest höchstens bis zur Grenze und meldet eine Überschreitung als Fehler
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, fmt.Errorf("%w of %d bytes", ErrListTooLarge, r.max)
+	}
+	// Ein Byte mehr als erlaubt lesen, um eine Überschreitung zu erkennen
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return 0, fmt.Errorf("%w of %d bytes", ErrListTooLarge, r.max)
+	}
+	return n, err
+}
+
+// scanLines ruft fn für jede Zeile auf, ohne den ganzen Inhalt im Speicher zu halten
+// Zeilennummern beginnen bei 1, Lesefehler und zu lange Zeilen brechen das Lesen ab
+func scanLines(r io.Reader, fn func(lineNo int, line string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineLength)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		fn(lineNo, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("line %d exceeds maximum length of %d bytes", lineNo+1, maxLineLength)
+		}
+		return err
+	}
+	return nil
+}
+
+// parserFor gibt den Zeilen-Parser für ein Format zurück
+// Jeder Aufruf liefert einen neuen Parser, da RPZ-Zonen Zustand ($ORIGIN) haben
+func parserFor(format ListFormat) (lineParser, error) {
+	switch format {
+	case FormatHosts:
+		return parseHostsRules, nil
+	case FormatAdblock:
+		return parseAdblockRules, nil
+	case FormatDomains:
+		return parseDomainLine, nil
+	case FormatDnsmasq:
+		return parseDnsmasqLine, nil
+	case FormatUnbound:
+		return parseUnboundLine, nil
+	case FormatRPZ:
+		return new(rpzParser).parseLine, nil
+	default:
+		return nil, fmt.Errorf("unsupported list format: %s", format)
+	}
+}
+
+// load liest eine Liste zeilenweise aus src und fügt ihre Regeln ein
+// Komprimierte Quellen werden entpackt, bei FormatAuto wird das Format anhand des Anfangs erkannt.
+// Ungültige Zeilen fasst ein *LoadError zusammen; Lesefehler, zu große Listen und zu lange
+// Zeilen werden direkt zurückgegeben, die Liste ist dann unvollständig.
+func (r *ruleSet) load(src io.Reader, format ListFormat, maxSize int64) (int, error) {
+	rc, err := openListReader(src, maxSize)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	buffered := bufio.NewReaderSize(rc, formatDetectBytes)
+	if format == FormatAuto {
+		head, err := buffered.Peek(formatDetectBytes)
+		if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+			return 0, fmt.Errorf("failed to read list: %w", err)
+		}
+		format = detectListFormat(string(head))
+	}
+
+	parse, err := parserFor(format)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	var loadErr LoadError
+	err = scanLines(buffered, func(lineNo int, line string) {
+		rules, err := parse(line)
+		if err != nil && !errors.Is(err, errUnsupportedRule) {
+			loadErr.add(lineNo, line, err)
+		}
+
+		for _, rule := range rules {
+			if rule.addr.IsValid() {
+				r.addLocal(rule.domain, rule.addr)
+				added++
+				continue
+			}
+			if addErr := r.add(rule.domain, rule.kind, rule.filter); addErr != nil {
+				// z.B. ungültiges Muster, die Zeile wird nur einmal gezählt
+				if err == nil {
+					loadErr.add(lineNo, line, addErr)
+				}
+				continue
+			}
+			added++
+		}
+	})
+	if err != nil {
+		return added, fmt.Errorf("failed to read list: %w", err)
+	}
+
+	return added, loadErr.errorOrNil()
+}
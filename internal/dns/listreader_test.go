@@ -0,0 +1,157 @@
+package dns
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// gzipBytes komprimiert content mit gzip
+func gzipBytes(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(content))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// zstdBytes komprimiert content mit zstd
+func zstdBytes(t *testing.T, content string) []byte {
+	t.Helper()
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("zstd: %v", err)
+	}
+	defer zw.Close()
+	return zw.EncodeAll([]byte(content), nil)
+}
+
+func TestBlacklist_LoadFromReader_Compressed(t *testing.T) {
+	content := "0.0.0.0 ads.example.com\n0.0.0.0 tracker.example.com\n"
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "Plain", data: []byte(content)},
+		{name: "Gzip", data: gzipBytes(t, content)},
+		{name: "Zstd", data: zstdBytes(t, content)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bl := NewBlacklist()
+			added, err := bl.LoadFromReader(bytes.NewReader(tt.data), FormatAuto)
+			if err != nil {
+				t.Fatalf("LoadFromReader() error = %v", err)
+			}
+			if added != 2 || !bl.IsBlocked("tracker.example.com") {
+				t.Errorf("LoadFromReader() added = %d, tracker blocked = %v", added, bl.IsBlocked("tracker.example.com"))
+			}
+		})
+	}
+}
+
+func TestBlacklist_LoadFromReader_MaxSize(t *testing.T) {
+	var b strings.Builder
+	for b.Len() < 4096 {
+		b.WriteString("0.0.0.0 ads.example.com\n")
+	}
+
+	bl := NewBlacklist()
+	bl.SetMaxListSize(1024)
+
+	// Die Grenze gilt für den entpackten Inhalt
+	for _, data := range [][]byte{[]byte(b.String()), gzipBytes(t, b.String())} {
+		added, err := bl.LoadFromReader(bytes.NewReader(data), FormatHosts)
+		if !errors.Is(err, ErrListTooLarge) {
+			t.Errorf("LoadFromReader() error = %v, want ErrListTooLarge", err)
+		}
+		if added != 0 || bl.Count() != 0 {
+			t.Errorf("too large list should not be loaded, added = %d, Count() = %d", added, bl.Count())
+		}
+	}
+
+	bl.SetMaxListSize(0)
+	if _, err := bl.LoadFromReader(strings.NewReader(b.String()), FormatHosts); err != nil {
+		t.Errorf("LoadFromReader() with default limit error = %v", err)
+	}
+}
+
+func TestBlacklist_LoadFromReader_LongLine(t *testing.T) {
+	bl := NewBlacklist()
+	content := "0.0.0.0 ads.example.com\n" + strings.Repeat("a", maxLineLength+1) + "\n"
+
+	_, err := bl.LoadFromReader(strings.NewReader(content), FormatHosts)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("LoadFromReader() error = %v, want line 2 too long", err)
+	}
+	if bl.Count() != 0 {
+		t.Error("incompletely read list should not be loaded")
+	}
+}
+
+func TestBlacklist_LoadFromFile_Gzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.gz")
+	if err := os.WriteFile(path, gzipBytes(t, "||ads.example.com^\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	bl := NewBlacklist()
+	if _, err := bl.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if !bl.IsBlocked("cdn.ads.example.com") {
+		t.Error("adblock rule from gzip file should be loaded")
+	}
+
+	// Benannte Listen lesen ihre Quelle ebenfalls gestreamt
+	bl.AddList(ListInfo{Name: "gzip", Source: path, Enabled: true})
+	if _, err := bl.LoadList("gzip"); err != nil {
+		t.Fatalf("LoadList() error = %v", err)
+	}
+	if match := bl.Match("ads.example.com", 0); match.List != "" {
+		// Manuelle Regeln werden vor Listen geprüft
+		t.Errorf("Match() list = %q, want manual rule", match.List)
+	}
+	if info, _ := bl.GetList("gzip"); info.Count != 1 {
+		t.Errorf("GetList() count = %d, want 1", info.Count)
+	}
+}
+
+func TestAllowlist_LoadFromReader_Zstd(t *testing.T) {
+	al := NewAllowlist()
+	added, err := al.LoadFromReader(bytes.NewReader(zstdBytes(t, "# allow\nok.example.com\n0.0.0.0 cdn.example.com\n")))
+	if err != nil {
+		t.Fatalf("LoadFromReader() error = %v", err)
+	}
+	if added != 2 || !al.IsAllowed("cdn.example.com") {
+		t.Errorf("LoadFromReader() added = %d", added)
+	}
+}
+
+func BenchmarkBlacklist_LoadFromReader(b *testing.B) {
+	var content strings.Builder
+	for i := range 100000 {
+		fmt.Fprintf(&content, "0.0.0.0 ads%d.example.com\n", i)
+	}
+	data := content.String()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		bl := NewBlacklist()
+		if _, err := bl.LoadFromReader(strings.NewReader(data), FormatHosts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package dns
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// trigramLen ist die Länge der Schlüssel im Index des patternSet
+const trigramLen = 3
+
+// patternRule ist eine kompilierte Regex- oder Glob-Regel
+type patternRule struct {
+	expr     string // Originalform: "/regex/", "*tracking*.example.*" oder "*.ad*.example.com"
+	re       *regexp.Regexp
+	literals []string // Literale, die in jeder passenden Domain vorkommen müssen
+}
+
+// patternSet speichert Regex- und Glob-Regeln
+// Damit auch tausende Muster schnell bleiben, werden aus jedem Muster die Literale bestimmt,
+// die in jeder passenden Domain vorkommen müssen. Jede Regel wird unter einem Trigramm dieser
+// Literale indiziert - dem, unter dem bisher die wenigsten Regeln stehen, damit häufige Teile
+// wie ".com" nicht alle Regeln in einen Eimer legen. Bei einer Abfrage werden nur Regeln geprüft,
+// deren Trigramm in der Domain vorkommt. Muster ohne Literal mit mindestens drei Zeichen
+// werden immer geprüft.
+// Nicht thread-safe - die Blacklist sichert den Zugriff ab
+type patternSet struct {
+	rules     map[string]*patternRule
+	index     map[string][]*patternRule
+	unindexed []*patternRule
+}
+
+// newPatternSet erstellt ein leeres patternSet
+func newPatternSet() *patternSet {
+	return &patternSet{
+		rules: make(map[string]*patternRule),
+		index: make(map[string][]*patternRule),
+	}
+}
+
+// isPatternRule prüft, ob eine Regel als Regex ("/.../") oder Glob ("*" mitten im Namen)
+// gespeichert werden muss. "*.example.com" bleibt eine normale Wildcard-Regel.
+func isPatternRule(domain string) bool {
+	if isRegexRule(domain) {
+		return true
+	}
+	return strings.Contains(strings.TrimPrefix(domain, "*."), "*")
+}
+
+// isRegexRule prüft, ob eine Regel ein regulärer Ausdruck in Schrägstrichen ist
+func isRegexRule(domain string) bool {
+	return len(domain) > 2 && strings.HasPrefix(domain, "/") && strings.HasSuffix(domain, "/")
+}
+
+// compilePattern kompiliert eine Regex- oder Glob-Regel und bestimmt ihre Pflicht-Literale
+// Regexe werden unverankert gegen die Domain (lowercase, ohne Punkt am Ende) geprüft,
+// Globs müssen die ganze Domain treffen. "*" steht dabei für beliebige Zeichen inkl. Punkten,
+// ein führendes "*." trifft wie bei Wildcards die Domain selbst und alle Subdomains.
+func compilePattern(expr string) (*patternRule, error) {
+	if isRegexRule(expr) {
+		source := expr[1 : len(expr)-1]
+		re, err := regexp.Compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex rule %s: %w", expr, err)
+		}
+		return &patternRule{expr: expr, re: re, literals: regexLiterals(source)}, nil
+	}
+
+	glob := expr
+	prefix := "^"
+	if strings.HasPrefix(glob, "*.") {
+		glob = glob[2:]
+		prefix = `^(?:.*\.)?`
+	}
+	if err := validateGlob(glob); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	var literals []string
+	b.WriteString(prefix)
+	for i, part := range strings.Split(glob, "*") {
+		if i > 0 {
+			b.WriteString(".*")
+		}
+		b.WriteString(regexp.QuoteMeta(part))
+		if part != "" {
+			literals = append(literals, part)
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob rule %s: %w", expr, err)
+	}
+	return &patternRule{expr: expr, re: re, literals: literals}, nil
+}
+
+// validateGlob prüft, dass ein Glob nur Hostnamen-Zeichen und "*" enthält
+func validateGlob(glob string) error {
+	if strings.Trim(glob, "*.") == "" {
+		return fmt.Errorf("glob rule matches everything: %s", glob)
+	}
+	for _, c := range glob {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '.', c == '_', c == '*':
+		default:
+			return fmt.Errorf("invalid character %q in glob rule: %s", c, glob)
+		}
+	}
+	if strings.Contains(glob, "..") {
+		return fmt.Errorf("empty label in glob rule: %s", glob)
+	}
+	return nil
+}
+
+// regexLiterals bestimmt die Literale, die jeder Treffer des Ausdrucks enthalten muss
+// Gibt nil zurück, wenn sich kein solches Literal ableiten lässt
+func regexLiterals(source string) []string {
+	re, err := syntax.Parse(source, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	var literals []string
+	for _, lit := range requiredLiterals(re.Simplify()) {
+		literals = append(literals, strings.ToLower(lit))
+	}
+	return literals
+}
+
+// requiredLiterals läuft den Syntaxbaum entlang zwingender Teile (Verkettung, Gruppen) ab
+// Alternativen, Wiederholungen und Zeichenklassen liefern keine Pflicht-Literale
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpConcat:
+		var literals []string
+		for _, sub := range re.Sub {
+			literals = append(literals, requiredLiterals(sub)...)
+		}
+		return literals
+	default:
+		return nil
+	}
+}
+
+// add kompiliert und speichert eine Regel, bereits vorhandene Regeln werden ignoriert
+func (s *patternSet) add(expr string) error {
+	if _, exists := s.rules[expr]; exists {
+		return nil
+	}
+
+	rule, err := compilePattern(expr)
+	if err != nil {
+		return err
+	}
+
+	s.rules[expr] = rule
+	s.indexRule(rule)
+	return nil
+}
+
+// merge übernimmt die bereits kompilierten Regeln eines anderen patternSet
+func (s *patternSet) merge(other *patternSet) {
+	for expr, rule := range other.rules {
+		if _, exists := s.rules[expr]; exists {
+			continue
+		}
+		s.rules[expr] = rule
+		s.indexRule(rule)
+	}
+}
+
+// indexRule trägt eine Regel unter dem am wenigsten belegten Trigramm ihrer Literale ein
+func (s *patternSet) indexRule(rule *patternRule) {
+	best := ""
+	for _, literal := range rule.literals {
+		for i := 0; i+trigramLen <= len(literal); i++ {
+			gram := literal[i : i+trigramLen]
+			if best == "" || len(s.index[gram]) < len(s.index[best]) {
+				best = gram
+			}
+		}
+	}
+
+	if best == "" {
+		s.unindexed = append(s.unindexed, rule)
+		return
+	}
+	s.index[best] = append(s.index[best], rule)
+}
+
+// remove entfernt eine Regel und baut den Index neu auf
+// Entfernen ist selten, daher wird der Index nicht inkrementell gepflegt
+func (s *patternSet) remove(expr string) {
+	if _, exists := s.rules[expr]; !exists {
+		return
+	}
+	delete(s.rules, expr)
+
+	s.index = make(map[string][]*patternRule)
+	s.unindexed = nil
+	for _, rule := range s.rules {
+		s.indexRule(rule)
+	}
+}
+
+// contains prüft, ob eine Regel die Domain trifft
+// domain muss bereits normalisiert sein
+func (s *patternSet) contains(domain string) bool {
+	_, ok := s.match(domain)
+	return ok
+}
+
+// match gibt die erste Regel (in Originalform) zurück, die die Domain trifft
+// domain muss bereits normalisiert sein
+func (s *patternSet) match(domain string) (string, bool) {
+	if len(s.rules) == 0 {
+		return "", false
+	}
+
+	for _, rule := range s.unindexed {
+		if rule.re.MatchString(domain) {
+			return rule.expr, true
+		}
+	}
+
+	for i := 0; i+trigramLen <= len(domain); i++ {
+		for _, rule := range s.index[domain[i:i+trigramLen]] {
+			if rule.re.MatchString(domain) {
+				return rule.expr, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// count gibt die Anzahl der Regeln zurück
+func (s *patternSet) count() int {
+	return len(s.rules)
+}
+
+// all gibt alle Regeln in Originalform sortiert zurück
+func (s *patternSet) all() []string {
+	exprs := make([]string, 0, len(s.rules))
+	for expr := range s.rules {
+		exprs = append(exprs, expr)
+	}
+	sort.Strings(exprs)
+	return exprs
+}
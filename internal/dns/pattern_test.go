@@ -0,0 +1,173 @@
+package dns
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		name         string
+		expr         string
+		wantErr      bool
+		wantLiterals []string
+		matches      []string
+		misses       []string
+	}{
+		{
+			name:         "Regex anchored",
+			expr:         `/^ad[0-9]+\./`,
+			wantLiterals: []string{"ad", "."},
+			matches:      []string{"ad1.example.com", "ad42.net"},
+			misses:       []string{"bad1.example.com", "ads.example.com"},
+		},
+		{
+			name:         "Regex unanchored",
+			expr:         `/tracker[0-9]/`,
+			wantLiterals: []string{"tracker"},
+			matches:      []string{"tracker1.com", "cdn.tracker7.net"},
+			misses:       []string{"tracker.com"},
+		},
+		{
+			name:         "Glob in the middle",
+			expr:         "*tracking*.example.*",
+			wantLiterals: []string{"tracking", ".example."},
+			matches:      []string{"tracking.example.com", "my-tracking-01.example.org"},
+			misses:       []string{"tracking.example", "tracking.other.com"},
+		},
+		{
+			name:         "Glob with subdomains",
+			expr:         "*.ad*.example.com",
+			wantLiterals: []string{"ad", ".example.com"},
+			matches:      []string{"ad1.example.com", "x.ads.example.com"},
+			misses:       []string{"bad.example.com", "example.com"},
+		},
+		{name: "Invalid regex", expr: "/ad[0-9/", wantErr: true},
+		{name: "Glob matching everything", expr: "*.*", wantErr: true},
+		{name: "Glob with empty label", expr: "ad*..com", wantErr: true},
+		{name: "Glob with invalid character", expr: "ad*/path", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := compilePattern(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compilePattern(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !slices.Equal(rule.literals, tt.wantLiterals) {
+				t.Errorf("literals = %q, want %q", rule.literals, tt.wantLiterals)
+			}
+			for _, domain := range tt.matches {
+				if !rule.re.MatchString(domain) {
+					t.Errorf("%s should match %q", tt.expr, domain)
+				}
+			}
+			for _, domain := range tt.misses {
+				if rule.re.MatchString(domain) {
+					t.Errorf("%s should not match %q", tt.expr, domain)
+				}
+			}
+		})
+	}
+}
+
+func TestPatternSet(t *testing.T) {
+	set := newPatternSet()
+
+	// "ad" und "." sind zu kurz für den Index, "tracker" wird über ein Trigramm indiziert
+	for _, expr := range []string{`/^ad[0-9]+\./`, `/tracker[0-9]/`, "*tracking*.example.*"} {
+		if err := set.add(expr); err != nil {
+			t.Fatalf("add(%q) error = %v", expr, err)
+		}
+	}
+	if err := set.add("/ad[0-9/"); err == nil {
+		t.Error("add() with invalid regex should return error")
+	}
+	if len(set.unindexed) != 1 {
+		t.Errorf("unindexed = %d, want 1", len(set.unindexed))
+	}
+
+	for _, domain := range []string{"ad1.example.com", "cdn.tracker7.net", "x-tracking.example.org"} {
+		if !set.contains(domain) {
+			t.Errorf("contains(%q) = false, want true", domain)
+		}
+	}
+	if set.contains("example.com") {
+		t.Error("contains(example.com) = true, want false")
+	}
+
+	set.remove(`/tracker[0-9]/`)
+	if set.contains("cdn.tracker7.net") {
+		t.Error("remove() should delete the regex")
+	}
+	if !set.contains("ad1.example.com") {
+		t.Error("remove() should keep other rules")
+	}
+	if set.count() != 2 {
+		t.Errorf("count() = %d, want 2", set.count())
+	}
+}
+
+func TestBlacklist_PatternRules(t *testing.T) {
+	bl := NewBlacklist()
+
+	if err := bl.AddDomain(`/^ad[0-9]+\./`); err != nil {
+		t.Fatalf("AddDomain(regex) error = %v", err)
+	}
+	if err := bl.AddDomain("*Tracking*.example.*"); err != nil {
+		t.Fatalf("AddDomain(glob) error = %v", err)
+	}
+	if err := bl.AddDomain("/ad[0-9/"); err == nil {
+		t.Error("AddDomain() with invalid regex should return error")
+	}
+
+	if !bl.IsBlocked("ad7.example.com") || !bl.IsBlocked("TRACKING.example.net") {
+		t.Error("pattern rules should block matching domains")
+	}
+	if bl.Count() != 2 || len(bl.GetAllPatterns()) != 2 {
+		t.Errorf("Count() = %d, GetAllPatterns() = %v", bl.Count(), bl.GetAllPatterns())
+	}
+
+	bl.RemoveDomain("*tracking*.example.*")
+	if bl.IsBlocked("tracking.example.net") {
+		t.Error("RemoveDomain() should remove glob regardless of case")
+	}
+}
+
+// benchmarkPatternSet erzeugt n Regex- und Glob-Regeln mit unterschiedlichen Literalen
+func benchmarkPatternSet(b *testing.B, n int) *patternSet {
+	set := newPatternSet()
+	for i := 0; i < n; i++ {
+		var expr string
+		if i%2 == 0 {
+			expr = fmt.Sprintf(`/^ad%dserver[0-9]*\./`, i)
+		} else {
+			expr = fmt.Sprintf("*track%d*.example.*", i)
+		}
+		if err := set.add(expr); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return set
+}
+
+func BenchmarkPatternSet_Contains(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		set := benchmarkPatternSet(b, n)
+		b.Run(fmt.Sprintf("%d/miss", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				set.contains("www.some-regular-site.example.org")
+			}
+		})
+		b.Run(fmt.Sprintf("%d/hit", n), func(b *testing.B) {
+			domain := fmt.Sprintf("cdn-track%d-x.example.com", n-1)
+			for i := 0; i < b.N; i++ {
+				set.contains(domain)
+			}
+		})
+	}
+}
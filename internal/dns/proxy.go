@@ -3,21 +3,61 @@ package dns
 import (
 	"context"
 	"fmt"
-	"net"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
+
+	mdns "github.com/miekg/dns"
 )
 
 // Proxy ist der DNS-Proxy-Service, der Registry, Blacklist und Cache nutzt
 type Proxy struct {
 	registry      *Registry
 	blacklist     *Blacklist
+	allowlist     *Allowlist    // Optional: erlaubte Domains mit Vorrang vor der Blacklist
+	ipFilter      *IPFilter     // Optional: IP/CIDR-Regeln und Rebinding-Schutz für Upstream-Antworten
+	safeSearch    *SafeSearch   // Optional: erzwingt SafeSearch per CNAME-Umschreibung
+	clientGroups  *ClientGroups // Optional: eigene Listen, Upstreams und Block-Antwort je Client-Gruppe
 	cache         *Cache
+	bootstrap     *Bootstrap // Optional: für den Loop-Guard bei Hostname-Upstreams
 	timeout       time.Duration
-	serverIndex   uint32 // Für Round-Robin
+	fallbackDelay time.Duration // Wartezeit bis zum Start der zweiten Adressfamilie
+	idleTimeout   time.Duration // Schließt ungenutzte Upstream-Verbindungen
+	serverIndex   uint32        // Für Round-Robin
 	useRoundRobin bool
+	blockResponse BlockResponse    // Antwort auf blockierte Domains, Listen können sie überschreiben
+	pausedUntil   atomic.Int64     // Ende einer Pause der Blockierung in Unix-Nanosekunden, 0 = aktiv
+	now           func() time.Time // Uhr für das Ende einer Pause, in Tests austauschbar
+
+	transports     map[string]*transportEntry // Ein Transport pro Upstream-Adresse und Protokoll
+	transportSweep time.Time                  // Letztes Aufräumen ungenutzter Transports
+	transportMu    sync.Mutex
+}
+
+// transportEntry ist ein Transport mit dem Zeitpunkt seiner letzten Nutzung
+type transportEntry struct {
+	transport *Transport
+	lastUsed  time.Time
 }
 
+// refresher wird von Servern erfüllt, deren Adressen ablaufen können (Hostname-Upstreams)
+type refresher interface {
+	NeedsRefresh() bool
+	Refresh(ctx context.Context) error
+}
+
+const (
+	// defaultFallbackDelay entspricht der Empfehlung aus RFC 8305 (Happy Eyeballs)
+	defaultFallbackDelay = 300 * time.Millisecond
+	// unusedTransportTimeout schließt Transports, deren Adresse nicht mehr gefragt wird
+	// (z.B. alte Adressen von Hostname-Upstreams nach einem Refresh)
+	unusedTransportTimeout = 10 * time.Minute
+)
+
 // NewProxy erstellt einen neuen DNS-Proxy ohne Cache
 func NewProxy(registry *Registry, blacklist *Blacklist) *Proxy {
 	return &Proxy{
@@ -25,7 +65,12 @@ func NewProxy(registry *Registry, blacklist *Blacklist) *Proxy {
 		blacklist:     blacklist,
 		cache:         nil,
 		timeout:       5 * time.Second,
+		fallbackDelay: defaultFallbackDelay,
+		idleTimeout:   defaultIdleTimeout,
 		useRoundRobin: false,
+		blockResponse: BlockResponse{Mode: BlockNullIP, TTL: defaultBlockTTL},
+		now:           time.Now,
+		transports:    make(map[string]*transportEntry),
 	}
 }
 
@@ -36,7 +81,12 @@ func NewProxyWithCache(registry *Registry, blacklist *Blacklist, cache *Cache) *
 		blacklist:     blacklist,
 		cache:         cache,
 		timeout:       5 * time.Second,
+		fallbackDelay: defaultFallbackDelay,
+		idleTimeout:   defaultIdleTimeout,
 		useRoundRobin: true, // Mit Cache nutzen wir Round-Robin
+		blockResponse: BlockResponse{Mode: BlockNullIP, TTL: defaultBlockTTL},
+		now:           time.Now,
+		transports:    make(map[string]*transportEntry),
 	}
 }
 
@@ -45,59 +95,422 @@ func (p *Proxy) SetTimeout(timeout time.Duration) {
 	p.timeout = timeout
 }
 
+// SetFallbackDelay setzt die Wartezeit, nach der parallel die zweite Adressfamilie
+// eines Servers angefragt wird, falls die bevorzugte noch nicht geantwortet hat
+func (p *Proxy) SetFallbackDelay(delay time.Duration) {
+	p.fallbackDelay = delay
+}
+
+// SetIdleTimeout setzt die Zeit, nach der ungenutzte Upstream-Verbindungen geschlossen werden
+// Gilt für Transports, die nach dem Aufruf erstellt werden
+func (p *Proxy) SetIdleTimeout(timeout time.Duration) {
+	p.transportMu.Lock()
+	defer p.transportMu.Unlock()
+
+	p.idleTimeout = timeout
+}
+
+// Close schließt alle offenen Upstream-Verbindungen
+func (p *Proxy) Close() error {
+	p.transportMu.Lock()
+	defer p.transportMu.Unlock()
+
+	for key, entry := range p.transports {
+		entry.transport.Close()
+		delete(p.transports, key)
+	}
+
+	return nil
+}
+
+// SetAllowlist setzt die Allowlist, deren Einträge nie blockiert werden
+func (p *Proxy) SetAllowlist(allowlist *Allowlist) {
+	p.allowlist = allowlist
+}
+
+// SetIPFilter setzt den IPFilter, der die Adressen der Upstream-Antworten prüft
+func (p *Proxy) SetIPFilter(filter *IPFilter) {
+	p.ipFilter = filter
+}
+
+// GetIPFilter gibt den IPFilter zurück (nil, wenn keiner gesetzt ist)
+func (p *Proxy) GetIPFilter() *IPFilter {
+	return p.ipFilter
+}
+
+// SetSafeSearch setzt die SafeSearch-Umschreibungstabelle für alle Clients, nil schaltet sie ab
+// Für einzelne Netze (Kinder, Gäste) siehe ClientGroup.SafeSearch
+func (p *Proxy) SetSafeSearch(safeSearch *SafeSearch) {
+	p.safeSearch = safeSearch
+}
+
+// GetSafeSearch gibt die SafeSearch-Umschreibungstabelle zurück (nil, wenn keine gesetzt ist)
+func (p *Proxy) GetSafeSearch() *SafeSearch {
+	return p.safeSearch
+}
+
+// SetClientGroups setzt die Client-Gruppen, nil behandelt alle Clients gleich
+// Die Client-Adresse kommt über WithClientAddr aus dem Context der Anfrage
+func (p *Proxy) SetClientGroups(groups *ClientGroups) {
+	p.clientGroups = groups
+}
+
+// GetClientGroups gibt die Client-Gruppen zurück (nil, wenn keine gesetzt sind)
+func (p *Proxy) GetClientGroups() *ClientGroups {
+	return p.clientGroups
+}
+
+// SetBootstrap setzt den Bootstrap-Resolver der Hostname-Upstreams
+// Anfragen für Hostnamen, die dieser gerade auflöst, werden abgewiesen (Loop-Guard)
+func (p *Proxy) SetBootstrap(bootstrap *Bootstrap) {
+	p.bootstrap = bootstrap
+}
+
+// SetBlockResponse setzt die globale Antwort auf blockierte Domains
+// Standard ist BlockNullIP mit einer TTL von 300 Sekunden, TTL 0 setzt den Standard
+func (p *Proxy) SetBlockResponse(resp BlockResponse) error {
+	if resp.Mode == BlockModeDefault {
+		return fmt.Errorf("global block response needs a block mode")
+	}
+	if err := resp.validate(); err != nil {
+		return err
+	}
+	if resp.TTL == 0 {
+		resp.TTL = defaultBlockTTL
+	}
+
+	p.blockResponse = resp
+	return nil
+}
+
+// pausedTTL ist die TTL aller Antworten, solange die Blockierung pausiert ist
+const pausedTTL = 5
+
+// PauseBlocking schaltet die Blockierung für die angegebene Dauer ab
+// Danach blockiert der Proxy automatisch wieder, duration <= 0 pausiert bis ResumeBlocking.
+// Lokale Records und SafeSearch bleiben aktiv. Der Cache speichert nur Upstream-Antworten und
+// prüft sie bei jedem Treffer erneut, nach der Pause werden daher keine blockierten Antworten
+// aus dem Cache geliefert.
+func (p *Proxy) PauseBlocking(duration time.Duration) {
+	until := int64(math.MaxInt64)
+	if duration > 0 {
+		until = p.now().Add(duration).UnixNano()
+	}
+	p.pausedUntil.Store(until)
+}
+
+// ResumeBlocking beendet eine Pause sofort
+func (p *Proxy) ResumeBlocking() {
+	p.pausedUntil.Store(0)
+}
+
+// BlockingPaused prüft, ob die Blockierung gerade pausiert ist
+func (p *Proxy) BlockingPaused() bool {
+	until := p.pausedUntil.Load()
+	return until != 0 && p.now().UnixNano() < until
+}
+
+// PausedUntil gibt das Ende der Pause zurück
+// paused ist false, wenn nicht pausiert ist; ohne Ende (bis ResumeBlocking) ist until die Nullzeit
+func (p *Proxy) PausedUntil() (until time.Time, paused bool) {
+	if !p.BlockingPaused() {
+		return time.Time{}, false
+	}
+	if nanos := p.pausedUntil.Load(); nanos != math.MaxInt64 {
+		until = time.Unix(0, nanos)
+	}
+	return until, true
+}
+
+// GetBlockResponse gibt die globale Antwort auf blockierte Domains zurück
+func (p *Proxy) GetBlockResponse() BlockResponse {
+	return p.blockResponse
+}
+
 // Lookup führt eine DNS-Abfrage für eine Domain durch
-// Blockierte Domains geben spezielle IPs zurück (0.0.0.0 / ::)
+// Blockierte Domains geben je nach BlockResponse spezielle IPs zurück (Standard 0.0.0.0 / ::),
+// bei NXDOMAIN und REFUSED einen Fehler, der ErrBlocked umschließt
 // Nutzt Cache falls vorhanden, sonst DNS-Server (Round-Robin oder Fallback)
 func (p *Proxy) Lookup(domain string) ([]string, error) {
+	return p.LookupContext(context.Background(), domain)
+}
+
+// LookupContext arbeitet wie Lookup, berücksichtigt aber Deadline und Abbruch des Contexts
+// Das Proxy-Timeout gilt zusätzlich pro Upstream-Abfrage
+func (p *Proxy) LookupContext(ctx context.Context, domain string) ([]string, error) {
+	result, err := p.Resolve(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	if result.Rcode != mdns.RcodeSuccess {
+		return nil, fmt.Errorf("%w: %s (%s)", ErrBlocked, domain, mdns.RcodeToString[result.Rcode])
+	}
+	return result.IPs, nil
+}
+
+// Resolve arbeitet wie LookupContext, liefert aber das vollständige Ergebnis
+// Bei blockierten Domains enthält es Rcode und TTL der Block-Antwort sowie die getroffene Regel
+// Der Query-Typ aus dem Context (WithQueryType) wird für $dnstype-Regeln ausgewertet,
+// die Client-Adresse (WithClientAddr) bestimmt die Client-Gruppe
+func (p *Proxy) Resolve(ctx context.Context, domain string) (*Result, error) {
+	pol := p.policyFor(ctx)
+
+	result, err := p.resolve(ctx, domain, pol)
+	if err != nil {
+		return nil, err
+	}
+
+	// Während einer Pause bekommen Antworten eine kurze TTL, damit Clients sie nach
+	// dem Ende der Pause nicht weiter aus ihrem Cache verwenden
+	if pol.paused {
+		result.TTL = pausedTTL
+	}
+	result.Group = pol.group
+	return result, nil
+}
+
+// policy sind die Einstellungen, die für eine einzelne Anfrage gelten
+// Ohne Client-Gruppe entsprechen sie den Einstellungen des Proxys
+type policy struct {
+	group      string
+	blocking   bool     // false während einer Pause und für Gruppen ohne Filterung
+	paused     bool     // die Blockierung ist pausiert
+	lists      []string // ausgewählte Listen der Blacklist, nil = alle
+	allowlist  *Allowlist
+	registry   *Registry
+	block      BlockResponse // globale Block-Antwort der Anfrage
+	safeSearch *SafeSearch   // SafeSearch-Umschreibung, nil = keine
+}
+
+// policyFor ermittelt die Einstellungen für eine Anfrage anhand der Client-Adresse im Context
+func (p *Proxy) policyFor(ctx context.Context) policy {
+	paused := p.BlockingPaused()
+	pol := policy{
+		blocking:   !paused,
+		paused:     paused,
+		allowlist:  p.allowlist,
+		registry:   p.registry,
+		block:      p.blockResponse,
+		safeSearch: p.safeSearch,
+	}
+
+	if p.clientGroups == nil {
+		return pol
+	}
+	group, ok := p.clientGroups.Match(ClientAddrFromContext(ctx))
+	if !ok {
+		return pol
+	}
+
+	pol.group = group.Name
+	pol.blocking = pol.blocking && !group.Unfiltered
+	pol.lists = group.Lists
+	if group.Allowlist != nil {
+		pol.allowlist = group.Allowlist
+	}
+	if group.Registry != nil {
+		pol.registry = group.Registry
+	}
+	if group.SafeSearch != nil {
+		pol.safeSearch = group.SafeSearch
+	}
+	pol.block = group.Block.resolve(p.blockResponse)
+	return pol
+}
+
+// cacheKey gibt den Cache-Schlüssel einer Domain zurück
+// Gruppen mit eigenen Upstreams bekommen eigene Einträge, deren Antworten können sich unterscheiden
+func (pol policy) cacheKey(p *Proxy, domain string) string {
+	if pol.registry != p.registry {
+		return domain + "@" + pol.group
+	}
+	return domain
+}
+
+// resolve beantwortet eine Abfrage mit den Einstellungen pol
+func (p *Proxy) resolve(ctx context.Context, domain string, pol policy) (*Result, error) {
 	if domain == "" {
 		return nil, fmt.Errorf("domain cannot be empty")
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Loop-Guard: der Bootstrap eines Upstreams darf nicht über den Proxy selbst laufen
+	if p.bootstrap != nil && p.bootstrap.IsResolving(domain) {
+		return nil, fmt.Errorf("bootstrap loop detected for %s", domain)
+	}
 
-	// Prüfe Blacklist - gebe spezielle IPs zurück statt Fehler
-	if p.blacklist.IsBlocked(domain) {
-		return []string{"0.0.0.0", "::"}, nil
+	// Lokale Records aus hosts-Dateien ("192.168.1.10 nas.home") beantwortet der Proxy selbst
+	// Wie beim Blockieren zählen nur die Listen, die die Client-Gruppe ausgewählt hat
+	if ips := p.blacklist.LocalAddressesLists(domain, pol.lists); len(ips) > 0 {
+		return &Result{IPs: ips, Local: true}, nil
 	}
 
+	// Prüfe Blacklist - gebe die konfigurierte Block-Antwort statt Fehler zurück
+	// Einträge der Allowlist haben Vorrang
+	qtype := QueryTypeFromContext(ctx)
+	var match MatchResult
+	if pol.blocking {
+		var blocked bool
+		if match, blocked = p.blockMatch(pol, domain, qtype); blocked {
+			return p.blockResult(pol, match), nil
+		}
+	}
+
+	// SafeSearch: Suchmaschinen werden vor dem Upstream-Zugriff auf ihre SafeSearch-Variante
+	// umgeschrieben, die Adressen des Ziels durchlaufen die normale Prüfung
+	if pol.safeSearch != nil {
+		if target, ok := pol.safeSearch.Rewrite(domain); ok {
+			result, err := p.resolve(ctx, target, pol)
+			if err != nil {
+				return nil, err
+			}
+			if !result.Blocked {
+				result.Rewrite = target
+			}
+			return result, nil
+		}
+	}
+
+	// Erlaubte Domains und Ausnahmen (@@) werden auch über CNAME-Kette und Adressen nicht blockiert
+	checkAnswer := pol.blocking && !match.Exception && !pol.isAllowed(domain)
+
 	// Prüfe Cache
+	cacheKey := pol.cacheKey(p, domain)
 	if p.cache != nil {
-		if cached := p.cache.Get(domain); cached != nil {
-			return cached, nil
+		if cached := p.cache.GetEntry(cacheKey); cached != nil {
+			if checkAnswer {
+				if result := p.checkAnswer(pol, domain, cached.IPs, cached.CNAMEs, qtype); result != nil {
+					return result, nil
+				}
+			}
+			return &Result{IPs: cached.IPs}, nil
 		}
 	}
 
+	// Vor dem Upstream-Zugriff erneut prüfen, ob der Aufrufer noch wartet
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Hole alle verfügbaren Server
-	servers := p.registry.GetAllServers()
+	servers := pol.registry.GetAllServers()
 	if len(servers) == 0 {
 		return nil, fmt.Errorf("no DNS servers configured")
 	}
 
-	var ips []string
+	var answer upstreamAnswer
 	var err error
 
 	if p.useRoundRobin {
 		// Round-Robin: Versuche Server nacheinander, beginnend mit nächstem
-		ips, err = p.lookupRoundRobin(domain, servers)
+		answer, err = p.lookupRoundRobin(ctx, domain, servers)
 	} else {
 		// Fallback: Versuche alle Server bis einer erfolgreich ist
-		ips, err = p.lookupFallback(domain, servers)
+		answer, err = p.lookupFallback(ctx, domain, servers)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	// Speichere erfolgreiches Ergebnis im Cache
-	if p.cache != nil && len(ips) > 0 {
-		p.cache.Set(domain, ips)
+	// Speichere erfolgreiches Ergebnis im Cache, die Antwort wird bei Treffern erneut geprüft
+	if p.cache != nil && len(answer.ips) > 0 {
+		p.cache.SetWithCNAMEs(cacheKey, answer.ips, answer.cnames)
 	}
 
-	return ips, nil
+	if checkAnswer {
+		if result := p.checkAnswer(pol, domain, answer.ips, answer.cnames, qtype); result != nil {
+			return result, nil
+		}
+	}
+
+	return &Result{IPs: answer.ips}, nil
+}
+
+// blockMatch prüft Allowlist und Blacklist für eine Domain
+// qtype 0 (unbekannt) wertet nur Regeln ohne $dnstype aus
+func (p *Proxy) blockMatch(pol policy, domain string, qtype uint16) (MatchResult, bool) {
+	if pol.isAllowed(domain) {
+		return MatchResult{}, false
+	}
+	match := p.blacklist.MatchLists(domain, qtype, pol.lists)
+	return match, match.Blocked
+}
+
+// isAllowed prüft, ob eine Domain in der Allowlist steht
+func (pol policy) isAllowed(domain string) bool {
+	return pol.allowlist != nil && pol.allowlist.IsAllowed(domain)
+}
+
+// blockResult erzeugt die Block-Antwort für einen Treffer
+// Listen mit eigener Block-Antwort überschreiben die Einstellung der Gruppe bzw. des Proxys
+func (p *Proxy) blockResult(pol policy, match MatchResult) *Result {
+	return match.Block.resolve(pol.block).result(match)
+}
+
+// checkAnswer prüft eine Upstream-Antwort auf CNAME-Cloaking und gelistete Adressen
+// Gibt nil zurück, wenn die Antwort nicht blockiert ist
+func (p *Proxy) checkAnswer(pol policy, domain string, ips, cnames []string, qtype uint16) *Result {
+	// CNAME-Cloaking: Tracker hinter First-Party-Subdomains erkennen
+	if result := p.checkCNAMEChain(pol, domain, cnames, qtype); result != nil {
+		return result
+	}
+	return p.checkAddresses(pol, domain, ips)
+}
+
+// checkAddresses prüft die Adressen einer Antwort gegen den IPFilter
+// Treffer erhalten die Block-Antwort der Gruppe bzw. des Proxys, Result.IP enthält die gelistete Adresse
+func (p *Proxy) checkAddresses(pol policy, domain string, ips []string) *Result {
+	if p.ipFilter == nil {
+		return nil
+	}
+
+	ipMatch, blocked := p.ipFilter.Check(domain, ips)
+	if !blocked {
+		return nil
+	}
+
+	if ipMatch.Rebinding {
+		log.Printf("DNS rebinding blocked: %s -> %s", domain, ipMatch.IP)
+	} else {
+		log.Printf("IP rule blocked: %s -> %s (rule %q)", domain, ipMatch.IP, ipMatch.Rule)
+	}
+	result := p.blockResult(pol, MatchResult{Blocked: true, Rule: ipMatch.Rule})
+	result.IP = ipMatch.IP
+	return result
+}
+
+// checkCNAMEChain prüft jedes Glied der CNAME-Kette einer Upstream-Antwort gegen die Blacklist
+// Trifft ein Glied eine Block-Regel, wird die ganze Antwort blockiert (CNAME-Cloaking):
+// "metrics.shop.example" -> "shop.tracker.net" wird blockiert, wenn tracker.net gelistet ist
+// Gibt nil zurück, wenn kein Glied blockiert ist
+func (p *Proxy) checkCNAMEChain(pol policy, domain string, cnames []string, qtype uint16) *Result {
+	for _, cname := range cnames {
+		match, blocked := p.blockMatch(pol, cname, qtype)
+		if !blocked {
+			continue
+		}
+
+		log.Printf("CNAME cloaking blocked: %s -> %s (list %q, rule %q)", domain, cname, match.List, match.Rule)
+		result := p.blockResult(pol, match)
+		result.CNAME = cname
+		return result
+	}
+	return nil
+}
+
+// upstreamAnswer ist die Antwort eines Upstreams auf A- und AAAA-Anfragen
+type upstreamAnswer struct {
+	ips    []string
+	cnames []string // CNAME-Ziele in der Reihenfolge der Kette, lowercase ohne Punkt am Ende
 }
 
 // lookupRoundRobin versucht Server im Round-Robin-Verfahren
-func (p *Proxy) lookupRoundRobin(domain string, servers []DNSServer) ([]string, error) {
+func (p *Proxy) lookupRoundRobin(ctx context.Context, domain string, servers []DNSServer) (upstreamAnswer, error) {
 	if len(servers) == 0 {
-		return nil, fmt.Errorf("no servers available")
+		return upstreamAnswer{}, fmt.Errorf("no servers available")
 	}
 
 	// Hole nächsten Server-Index (atomic für Thread-Safety)
@@ -107,61 +520,274 @@ func (p *Proxy) lookupRoundRobin(domain string, servers []DNSServer) ([]string,
 	var lastErr error
 	for i := 0; i < len(servers); i++ {
 		serverIdx := (int(index) + i) % len(servers)
-		ips, err := p.lookupWithServer(domain, servers[serverIdx])
+		answer, err := p.lookupWithServer(ctx, domain, servers[serverIdx])
 		if err == nil {
-			return ips, nil
+			return answer, nil
+		}
+		// Abgebrochene Anfragen nicht an weitere Server weiterreichen
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return upstreamAnswer{}, ctxErr
 		}
 		lastErr = err
 	}
 
-	return nil, fmt.Errorf("all DNS servers failed, last error: %w", lastErr)
+	return upstreamAnswer{}, fmt.Errorf("all DNS servers failed, last error: %w", lastErr)
 }
 
 // lookupFallback versucht Server nacheinander (alte Methode)
-func (p *Proxy) lookupFallback(domain string, servers []DNSServer) ([]string, error) {
+func (p *Proxy) lookupFallback(ctx context.Context, domain string, servers []DNSServer) (upstreamAnswer, error) {
 	var lastErr error
 	for _, server := range servers {
-		ips, err := p.lookupWithServer(domain, server)
+		answer, err := p.lookupWithServer(ctx, domain, server)
 		if err == nil {
-			return ips, nil
+			return answer, nil
+		}
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return upstreamAnswer{}, ctxErr
 		}
 		lastErr = err
 	}
 
-	return nil, fmt.Errorf("all DNS servers failed, last error: %w", lastErr)
+	return upstreamAnswer{}, fmt.Errorf("all DNS servers failed, last error: %w", lastErr)
+}
+
+// contextError gibt den Fehler eines beendeten Contexts zurück
+// Die Socket-Deadline kann minimal vor dem Timer des Contexts ablaufen,
+// daher zählt eine überschrittene Deadline auch dann, wenn ctx.Err() noch nil ist
+func contextError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
 }
 
 // lookupWithServer führt eine DNS-Abfrage mit einem bestimmten Server durch
-func (p *Proxy) lookupWithServer(domain string, server DNSServer) ([]string, error) {
-	dnsAddress := server.GetAddress()
-
-	// Erstelle einen benutzerdefinierten Resolver
-	r := &net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-			d := net.Dialer{
-				Timeout: p.timeout,
-			}
-			return d.DialContext(ctx, "udp", dnsAddress)
-		},
+// Das Timeout wird vom Context des Aufrufers abgeleitet
+// Hat der Server IPv4 und IPv6, werden beide Familien im Happy-Eyeballs-Verfahren versucht
+func (p *Proxy) lookupWithServer(ctx context.Context, domain string, server DNSServer) (upstreamAnswer, error) {
+	// Hostname-Upstreams nach Ablauf der TTL im Hintergrund neu auflösen
+	// Bis dahin (und bei Fehlern) werden die bisherigen Adressen genutzt, damit ein langsamer
+	// Bootstrap-Resolver die Anfrage nicht außerhalb des Timeouts aufhält
+	if r, ok := server.(refresher); ok && r.NeedsRefresh() {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+			defer cancel()
+			r.Refresh(ctx)
+		}()
+	}
+
+	addrs := server.GetAddresses()
+	if len(addrs) == 0 {
+		return upstreamAnswer{}, fmt.Errorf("lookup failed for server %s: no address configured", server.GetName())
 	}
 
-	// Führe die DNS-Abfrage aus
-	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
 	defer cancel()
 
-	ipAddrs, err := r.LookupIP(ctx, "ip", domain)
+	answer, err := p.raceAddresses(ctx, domain, server, addrs)
+	if err != nil {
+		return upstreamAnswer{}, fmt.Errorf("lookup failed for server %s: %w", server.GetName(), err)
+	}
+
+	return answer, nil
+}
+
+// raceAddresses fragt die Adressen eines Servers nach Präferenz ab
+// Die nächste Adresse startet, sobald die vorherige fehlschlägt oder nach fallbackDelay
+// keine Antwort geliefert hat. Die erste erfolgreiche Antwort gewinnt.
+func (p *Proxy) raceAddresses(ctx context.Context, domain string, server DNSServer, addrs []string) (upstreamAnswer, error) {
+	type result struct {
+		answer upstreamAnswer
+		err    error
+	}
+
+	// Bricht die langsamere Abfrage ab, sobald ein Ergebnis vorliegt
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result, len(addrs))
+	next := 0
+	startNext := func() {
+		addr := addrs[next]
+		next++
+		go func() {
+			answer, err := p.lookupWithAddress(ctx, domain, server, addr)
+			results <- result{answer: answer, err: err}
+		}()
+	}
+
+	startNext()
+	pending := 1
+
+	timer := time.NewTimer(p.fallbackDelay)
+	defer timer.Stop()
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			// Bevorzugte Familie ist zu langsam - nächste parallel starten
+			if next < len(addrs) {
+				startNext()
+				pending++
+				timer.Reset(p.fallbackDelay)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.answer, nil
+			}
+			lastErr = r.err
+			// Failover: nächste Familie sofort starten statt auf den Timer zu warten
+			if next < len(addrs) {
+				startNext()
+				pending++
+			}
+		}
+	}
+
+	return upstreamAnswer{}, lastErr
+}
+
+// lookupWithAddress führt eine DNS-Abfrage gegen eine einzelne Adresse (host:port) durch
+// A und AAAA werden parallel über den gemeinsamen Transport der Adresse abgefragt
+func (p *Proxy) lookupWithAddress(ctx context.Context, domain string, server DNSServer, addr string) (upstreamAnswer, error) {
+	transport, err := p.getTransport(server.GetProtocol(), addr, server.GetHostname())
 	if err != nil {
-		return nil, fmt.Errorf("lookup failed for server %s: %w", server.GetName(), err)
+		return upstreamAnswer{}, err
+	}
+
+	type result struct {
+		ips    []string
+		cnames []string
+		err    error
+	}
+
+	qtypes := []uint16{mdns.TypeA, mdns.TypeAAAA}
+	results := make([]result, len(qtypes))
+
+	var wg sync.WaitGroup
+	for i, qtype := range qtypes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ips, cnames, err := queryAddresses(ctx, transport, domain, qtype)
+			results[i] = result{ips: ips, cnames: cnames, err: err}
+		}()
+	}
+	wg.Wait()
+
+	var answer upstreamAnswer
+	var firstErr error
+	for _, r := range results {
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		answer.ips = append(answer.ips, r.ips...)
+		// A- und AAAA-Antwort enthalten meist dieselbe Kette
+		for _, cname := range r.cnames {
+			if !slices.Contains(answer.cnames, cname) {
+				answer.cnames = append(answer.cnames, cname)
+			}
+		}
 	}
 
-	// Konvertiere zu String-Slice
-	var ips []string
-	for _, ip := range ipAddrs {
-		ips = append(ips, ip.String())
+	if len(answer.ips) == 0 {
+		if firstErr != nil {
+			return upstreamAnswer{}, firstErr
+		}
+		return upstreamAnswer{}, fmt.Errorf("no such host: %s", domain)
 	}
 
-	return ips, nil
+	return answer, nil
+}
+
+// queryAddresses stellt eine einzelne A- oder AAAA-Anfrage und gibt die IPs und die
+// CNAME-Ziele der Antwort zurück
+func queryAddresses(ctx context.Context, transport *Transport, domain string, qtype uint16) (ips []string, cnames []string, err error) {
+	msg := new(mdns.Msg)
+	msg.SetQuestion(mdns.Fqdn(domain), qtype)
+	msg.RecursionDesired = true
+	msg.SetEdns0(ednsUDPSize, false)
+
+	resp, err := transport.Exchange(ctx, msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch resp.Rcode {
+	case mdns.RcodeSuccess:
+	case mdns.RcodeNameError:
+		return nil, nil, fmt.Errorf("no such host: %s", domain)
+	default:
+		return nil, nil, fmt.Errorf("upstream answered %s", mdns.RcodeToString[resp.Rcode])
+	}
+
+	// Nur Adressen des angefragten Typs übernehmen, CNAMEs werden für die Prüfung der Kette gesammelt
+	for _, rr := range resp.Answer {
+		switch record := rr.(type) {
+		case *mdns.CNAME:
+			cnames = append(cnames, strings.ToLower(strings.TrimSuffix(record.Target, ".")))
+		case *mdns.A:
+			if qtype == mdns.TypeA {
+				ips = append(ips, record.A.String())
+			}
+		case *mdns.AAAA:
+			if qtype == mdns.TypeAAAA {
+				ips = append(ips, record.AAAA.String())
+			}
+		}
+	}
+
+	return ips, cnames, nil
+}
+
+// getTransport gibt den Transport für eine Upstream-Adresse zurück und erstellt ihn bei Bedarf
+func (p *Proxy) getTransport(protocol, addr, serverName string) (*Transport, error) {
+	key := protocol + "://" + addr + "/" + serverName
+
+	p.transportMu.Lock()
+	defer p.transportMu.Unlock()
+
+	now := p.now()
+	p.sweepTransports(now)
+
+	if entry, ok := p.transports[key]; ok {
+		entry.lastUsed = now
+		return entry.transport, nil
+	}
+
+	transport, err := NewTransport(protocol, addr, serverName)
+	if err != nil {
+		return nil, err
+	}
+	transport.SetIdleTimeout(p.idleTimeout)
+	p.transports[key] = &transportEntry{transport: transport, lastUsed: now}
+
+	return transport, nil
+}
+
+// sweepTransports schließt und entfernt Transports, die länger als unusedTransportTimeout
+// nicht genutzt wurden. Sonst blieben nach jedem Adresswechsel eines Hostname-Upstreams
+// der alte Transport und seine Sockets offen. Muss mit transportMu aufgerufen werden.
+func (p *Proxy) sweepTransports(now time.Time) {
+	if now.Sub(p.transportSweep) < time.Minute {
+		return
+	}
+	p.transportSweep = now
+
+	for key, entry := range p.transports {
+		if now.Sub(entry.lastUsed) > unusedTransportTimeout {
+			entry.transport.Close()
+			delete(p.transports, key)
+		}
+	}
 }
 
 // GetRegistry gibt die Registry zurück
@@ -174,6 +800,11 @@ func (p *Proxy) GetBlacklist() *Blacklist {
 	return p.blacklist
 }
 
+// GetAllowlist gibt die Allowlist zurück (nil wenn keine gesetzt ist)
+func (p *Proxy) GetAllowlist() *Allowlist {
+	return p.allowlist
+}
+
 // GetCache gibt den Cache zurück
 func (p *Proxy) GetCache() *Cache {
 	return p.cache
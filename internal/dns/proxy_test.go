@@ -1,10 +1,16 @@
 package dns
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/netip"
 	"strings"
 	"testing"
 	"time"
+
+	mdns "github.com/miekg/dns"
 )
 
 func TestNewProxy(t *testing.T) {
@@ -408,3 +414,699 @@ func TestProxy_RoundRobin(t *testing.T) {
 		t.Error("Round-Robin should have incremented serverIndex")
 	}
 }
+
+func TestProxy_LookupContext_Canceled(t *testing.T) {
+	registry := NewRegistry()
+	blacklist := NewBlacklist()
+	proxy := NewProxy(registry, blacklist)
+
+	server, _ := NewServer("Test", "8.8.8.8", "", 53)
+	registry.AddServer(server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := proxy.LookupContext(ctx, "example.com")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("LookupContext() with canceled context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestProxy_LookupContext_Deadline(t *testing.T) {
+	// UDP-Socket, der nie antwortet - die Abfrage muss am Context-Deadline enden
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() failed: %v", err)
+	}
+	defer conn.Close()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	registry := NewRegistry()
+	blacklist := NewBlacklist()
+	proxy := NewProxy(registry, blacklist)
+	proxy.SetTimeout(5 * time.Second)
+
+	server, _ := NewServer("Silent", "127.0.0.1", "", port)
+	registry.AddServer(server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = proxy.LookupContext(ctx, "example.com")
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("LookupContext() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("LookupContext() took %v, should stop at the context deadline", elapsed)
+	}
+}
+
+func TestProxy_LookupContext_BlockedIgnoresUpstream(t *testing.T) {
+	registry := NewRegistry()
+	blacklist := NewBlacklist()
+	proxy := NewProxy(registry, blacklist)
+
+	blacklist.AddDomain("blocked.com")
+
+	// Blockierte Domains brauchen keinen Upstream und keinen Deadline-Puffer
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ips, err := proxy.LookupContext(ctx, "blocked.com")
+	if err != nil {
+		t.Errorf("LookupContext() for blocked domain should not error, got: %v", err)
+	}
+	if len(ips) != 2 || ips[0] != "0.0.0.0" {
+		t.Errorf("LookupContext() for blocked domain = %v, want ['0.0.0.0', '::']", ips)
+	}
+}
+
+// startTestUpstream startet einen lokalen DNS-Server auf addr ("host:port", Port 0 für zufällig),
+// der jede A-Anfrage mit ip beantwortet. Gibt den tatsächlich genutzten Port zurück.
+func startTestUpstream(t *testing.T, addr string, ip string) int {
+	t.Helper()
+
+	return startTestUpstreamHandler(t, addr, func(w mdns.ResponseWriter, r *mdns.Msg) {
+		msg := new(mdns.Msg)
+		msg.SetReply(r)
+		for _, q := range r.Question {
+			if q.Qtype == mdns.TypeA {
+				msg.Answer = append(msg.Answer, &mdns.A{
+					Hdr: mdns.RR_Header{Name: q.Name, Rrtype: mdns.TypeA, Class: mdns.ClassINET, Ttl: 60},
+					A:   net.ParseIP(ip).To4(),
+				})
+			}
+		}
+		w.WriteMsg(msg)
+	})
+}
+
+// startTestUpstreamHandler startet einen lokalen DNS-Server mit eigenem Handler
+func startTestUpstreamHandler(t *testing.T, addr string, handler mdns.HandlerFunc) int {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		t.Fatalf("ListenPacket(%s) failed: %v", addr, err)
+	}
+
+	server := &mdns.Server{PacketConn: conn, Handler: handler}
+	go server.ActivateAndServe()
+	t.Cleanup(func() { server.Shutdown() })
+
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// startSilentUpstream belegt addr mit einem UDP-Socket, der nie antwortet
+func startSilentUpstream(t *testing.T, addr string) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		t.Fatalf("ListenPacket(%s) failed: %v", addr, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+}
+
+func TestProxy_SetFallbackDelay(t *testing.T) {
+	proxy := NewProxy(NewRegistry(), NewBlacklist())
+
+	if proxy.fallbackDelay != defaultFallbackDelay {
+		t.Errorf("Default fallbackDelay = %v, want %v", proxy.fallbackDelay, defaultFallbackDelay)
+	}
+
+	proxy.SetFallbackDelay(50 * time.Millisecond)
+	if proxy.fallbackDelay != 50*time.Millisecond {
+		t.Errorf("SetFallbackDelay() failed, got %v", proxy.fallbackDelay)
+	}
+}
+
+func TestProxy_HappyEyeballs_FailoverToIPv6(t *testing.T) {
+	// IPv6 antwortet, IPv4 schweigt auf demselben Port
+	port := startTestUpstream(t, "[::1]:0", "10.0.0.6")
+	startSilentUpstream(t, fmt.Sprintf("127.0.0.1:%d", port))
+
+	registry := NewRegistry()
+	proxy := NewProxy(registry, NewBlacklist())
+	proxy.SetTimeout(5 * time.Second)
+	proxy.SetFallbackDelay(50 * time.Millisecond)
+
+	server, _ := NewServer("Dual", "127.0.0.1", "::1", port)
+	registry.AddServer(server)
+
+	start := time.Now()
+	ips, err := proxy.Lookup("example.com")
+	if err != nil {
+		t.Fatalf("Lookup() should fail over to IPv6, got error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Lookup() took %v, IPv6 should be raced after the fallback delay", elapsed)
+	}
+	if len(ips) != 1 || ips[0] != "10.0.0.6" {
+		t.Errorf("Lookup() = %v, want [10.0.0.6]", ips)
+	}
+}
+
+func TestProxy_HappyEyeballs_PreferIPv6(t *testing.T) {
+	port := startTestUpstream(t, "[::1]:0", "10.0.0.6")
+	startTestUpstream(t, fmt.Sprintf("127.0.0.1:%d", port), "10.0.0.4")
+
+	registry := NewRegistry()
+	proxy := NewProxy(registry, NewBlacklist())
+	proxy.SetFallbackDelay(time.Second)
+
+	server, _ := NewServer("Dual", "127.0.0.1", "::1", port)
+	server.SetPreference(PreferIPv6)
+	registry.AddServer(server)
+
+	ips, err := proxy.Lookup("example.com")
+	if err != nil {
+		t.Fatalf("Lookup() unexpected error: %v", err)
+	}
+	if len(ips) != 1 || ips[0] != "10.0.0.6" {
+		t.Errorf("Lookup() with IPv6 preference = %v, want answer from IPv6 upstream", ips)
+	}
+}
+
+func TestProxy_HappyEyeballs_BothFamiliesFail(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "[::1]:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() failed: %v", err)
+	}
+	defer conn.Close()
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	startSilentUpstream(t, fmt.Sprintf("127.0.0.1:%d", port))
+
+	registry := NewRegistry()
+	proxy := NewProxy(registry, NewBlacklist())
+	proxy.SetTimeout(300 * time.Millisecond)
+	proxy.SetFallbackDelay(50 * time.Millisecond)
+
+	server, _ := NewServer("Dead", "127.0.0.1", "::1", port)
+	registry.AddServer(server)
+
+	_, err = proxy.Lookup("example.com")
+	if err == nil {
+		t.Fatal("Lookup() should fail when both address families fail")
+	}
+	if !strings.Contains(err.Error(), "all DNS servers failed") {
+		t.Errorf("Error should mention 'all DNS servers failed', got: %v", err)
+	}
+}
+
+func TestProxy_Lookup_AllowlistOverridesBlacklist(t *testing.T) {
+	port := startTestUpstream(t, "127.0.0.1:0", "10.0.0.1")
+
+	registry := NewRegistry()
+	blacklist := NewBlacklist()
+	allowlist := NewAllowlist()
+	proxy := NewProxy(registry, blacklist)
+	proxy.SetAllowlist(allowlist)
+	defer proxy.Close()
+
+	server, _ := NewServer("Local", "127.0.0.1", "", port)
+	registry.AddServer(server)
+
+	blacklist.AddDomain("*.example.com")
+	allowlist.AddDomain("login.example.com")
+
+	if proxy.GetAllowlist() != allowlist {
+		t.Error("GetAllowlist() returned wrong allowlist")
+	}
+
+	ips, err := proxy.Lookup("login.example.com")
+	if err != nil {
+		t.Fatalf("Lookup() for allowed domain failed: %v", err)
+	}
+	if len(ips) != 1 || ips[0] != "10.0.0.1" {
+		t.Errorf("Lookup() for allowed domain = %v, want upstream answer", ips)
+	}
+
+	ips, _ = proxy.Lookup("ads.example.com")
+	if len(ips) != 2 || ips[0] != "0.0.0.0" {
+		t.Errorf("Lookup() for blocked sibling = %v, want blocked IPs", ips)
+	}
+
+	// Neuladen der Blacklist lässt die Allowlist unberührt
+	blacklist.Clear()
+	blacklist.LoadFromHostsContent("0.0.0.0 login.example.com\n")
+
+	ips, err = proxy.Lookup("login.example.com")
+	if err != nil || len(ips) != 1 || ips[0] != "10.0.0.1" {
+		t.Errorf("Lookup() after blacklist reload = %v, %v, want upstream answer", ips, err)
+	}
+}
+
+func TestProxy_LookupContext_DNSTypeRule(t *testing.T) {
+	registry := NewRegistry()
+	blacklist := NewBlacklist()
+	proxy := NewProxy(registry, blacklist)
+
+	if err := blacklist.AddAdblockRule("||v6.example.com^$dnstype=AAAA"); err != nil {
+		t.Fatalf("AddAdblockRule() error = %v", err)
+	}
+
+	// Mit AAAA im Context greift die Regel, ohne Upstream
+	ctx := WithQueryType(context.Background(), mdns.TypeAAAA)
+	ips, err := proxy.LookupContext(ctx, "v6.example.com")
+	if err != nil {
+		t.Fatalf("LookupContext() error = %v", err)
+	}
+	if len(ips) != 2 || ips[0] != "0.0.0.0" {
+		t.Errorf("LookupContext() = %v, want blocked", ips)
+	}
+
+	// Für A greift die Regel nicht - ohne Server schlägt die Abfrage fehl
+	ctx = WithQueryType(context.Background(), mdns.TypeA)
+	if _, err := proxy.LookupContext(ctx, "v6.example.com"); err == nil {
+		t.Error("LookupContext() for A should not be blocked")
+	}
+}
+
+func TestQueryTypeFromContext(t *testing.T) {
+	if got := QueryTypeFromContext(context.Background()); got != 0 {
+		t.Errorf("QueryTypeFromContext() without value = %d, want 0", got)
+	}
+	ctx := WithQueryType(context.Background(), mdns.TypeAAAA)
+	if got := QueryTypeFromContext(ctx); got != mdns.TypeAAAA {
+		t.Errorf("QueryTypeFromContext() = %d, want %d", got, mdns.TypeAAAA)
+	}
+}
+
+func TestProxy_Resolve_LocalRecord(t *testing.T) {
+	blacklist := NewBlacklist()
+	blacklist.LoadFromHostsContent("192.168.1.10 nas.home\n0.0.0.0 ads.example.com\n")
+
+	// Ohne Server: lokale Records brauchen keinen Upstream
+	proxy := NewProxy(NewRegistry(), blacklist)
+
+	result, err := proxy.Resolve(context.Background(), "nas.home")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if !result.Local || result.Blocked || len(result.IPs) != 1 || result.IPs[0] != "192.168.1.10" {
+		t.Errorf("Resolve() = %+v, want local record 192.168.1.10", result)
+	}
+
+	ips, err := proxy.Lookup("ads.example.com")
+	if err != nil || len(ips) != 2 || ips[0] != "0.0.0.0" {
+		t.Errorf("Lookup() blocked = %v, %v", ips, err)
+	}
+}
+
+// startCNAMEUpstream startet einen Upstream, der jede A-Anfrage über eine CNAME-Kette beantwortet
+func startCNAMEUpstream(t *testing.T, chain []string, ip string) int {
+	t.Helper()
+
+	return startTestUpstreamHandler(t, "127.0.0.1:0", func(w mdns.ResponseWriter, r *mdns.Msg) {
+		msg := new(mdns.Msg)
+		msg.SetReply(r)
+		for _, q := range r.Question {
+			if q.Qtype != mdns.TypeA {
+				continue
+			}
+			owner := q.Name
+			for _, target := range chain {
+				msg.Answer = append(msg.Answer, &mdns.CNAME{
+					Hdr:    mdns.RR_Header{Name: owner, Rrtype: mdns.TypeCNAME, Class: mdns.ClassINET, Ttl: 60},
+					Target: mdns.Fqdn(target),
+				})
+				owner = mdns.Fqdn(target)
+			}
+			msg.Answer = append(msg.Answer, &mdns.A{
+				Hdr: mdns.RR_Header{Name: owner, Rrtype: mdns.TypeA, Class: mdns.ClassINET, Ttl: 60},
+				A:   net.ParseIP(ip).To4(),
+			})
+		}
+		w.WriteMsg(msg)
+	})
+}
+
+func TestProxy_Resolve_CNAMECloaking(t *testing.T) {
+	port := startCNAMEUpstream(t, []string{"shop.cdn.example.net", "Collect.Tracker.example.org"}, "10.0.0.9")
+
+	registry := NewRegistry()
+	blacklist := NewBlacklist()
+	proxy := NewProxyWithCache(registry, blacklist, NewCache(time.Minute, time.Minute))
+	defer proxy.Close()
+
+	server, _ := NewServer("Local", "127.0.0.1", "", port)
+	registry.AddServer(server)
+
+	// Ohne passende Regel wird die Antwort durchgereicht
+	result, err := proxy.Resolve(context.Background(), "metrics.shop.example.com")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if result.Blocked || len(result.IPs) != 1 || result.IPs[0] != "10.0.0.9" {
+		t.Fatalf("Resolve() = %+v, want upstream answer", result)
+	}
+
+	// Die Regel für das letzte Glied greift auch für die bereits gecachte Antwort
+	blacklist.AddDomain("*.tracker.example.org")
+
+	result, err = proxy.Resolve(context.Background(), "metrics.shop.example.com")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if !result.Blocked {
+		t.Fatalf("Resolve() = %+v, want blocked by CNAME chain", result)
+	}
+	if result.CNAME != "collect.tracker.example.org" {
+		t.Errorf("Result.CNAME = %q, want %q", result.CNAME, "collect.tracker.example.org")
+	}
+	if result.Match.Rule != "*.tracker.example.org" {
+		t.Errorf("Result.Match.Rule = %q, want %q", result.Match.Rule, "*.tracker.example.org")
+	}
+
+	// Ohne Cache wird die Kette der frischen Upstream-Antwort geprüft
+	proxy.GetCache().Clear()
+	ips, err := proxy.Lookup("other.example.com")
+	if err != nil || len(ips) != 2 || ips[0] != "0.0.0.0" {
+		t.Errorf("Lookup() = %v, %v, want blocked IPs", ips, err)
+	}
+}
+
+func TestProxy_Resolve_CNAMECloaking_Allowed(t *testing.T) {
+	port := startCNAMEUpstream(t, []string{"tracker.example.org"}, "10.0.0.9")
+
+	registry := NewRegistry()
+	blacklist := NewBlacklist()
+	allowlist := NewAllowlist()
+	proxy := NewProxy(registry, blacklist)
+	proxy.SetAllowlist(allowlist)
+	defer proxy.Close()
+
+	server, _ := NewServer("Local", "127.0.0.1", "", port)
+	registry.AddServer(server)
+
+	blacklist.AddDomain("tracker.example.org")
+	blacklist.AddAdblockRule("@@||exempt.example.com^")
+	allowlist.AddDomain("allowed.example.com")
+
+	// Erlaubte Domains und Ausnahmen werden auch über die Kette nicht blockiert
+	for _, domain := range []string{"allowed.example.com", "exempt.example.com"} {
+		result, err := proxy.Resolve(context.Background(), domain)
+		if err != nil {
+			t.Fatalf("Resolve(%s) error = %v", domain, err)
+		}
+		if result.Blocked {
+			t.Errorf("Resolve(%s) = %+v, want upstream answer", domain, result)
+		}
+	}
+
+	// Steht das gelistete Glied selbst in der Allowlist, bleibt die Kette erlaubt
+	allowlist.AddDomain("tracker.example.org")
+	result, err := proxy.Resolve(context.Background(), "first.example.com")
+	if err != nil || result.Blocked {
+		t.Errorf("Resolve() = %+v, %v, want allowed CNAME target to pass", result, err)
+	}
+}
+
+func TestProxy_Resolve_IPFilter(t *testing.T) {
+	port := startTestUpstream(t, "127.0.0.1:0", "192.168.1.1")
+
+	registry := NewRegistry()
+	proxy := NewProxyWithCache(registry, NewBlacklist(), NewCache(time.Minute, time.Minute))
+	defer proxy.Close()
+
+	server, _ := NewServer("Local", "127.0.0.1", "", port)
+	registry.AddServer(server)
+
+	// Ohne IPFilter wird die Antwort durchgereicht
+	result, err := proxy.Resolve(context.Background(), "rebind.example.com")
+	if err != nil || result.Blocked {
+		t.Fatalf("Resolve() without filter = %+v, %v, want upstream answer", result, err)
+	}
+
+	// Der Filter greift auch für die gecachte Antwort
+	filter := NewIPFilter()
+	filter.SetRebindingProtection(true)
+	proxy.SetIPFilter(filter)
+	if proxy.GetIPFilter() != filter {
+		t.Error("GetIPFilter() returned wrong filter")
+	}
+
+	result, err = proxy.Resolve(context.Background(), "rebind.example.com")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if !result.Blocked || result.IP != "192.168.1.1" || result.Match.Rule != RebindingRule {
+		t.Errorf("Resolve() = %+v, want blocked rebinding answer", result)
+	}
+
+	// Ausnahmen und gelistete Netze
+	filter.AddRebindingExemption("rebind.example.com")
+	result, err = proxy.Resolve(context.Background(), "rebind.example.com")
+	if err != nil || result.Blocked {
+		t.Errorf("Resolve() for exempt domain = %+v, %v, want upstream answer", result, err)
+	}
+
+	filter.AddCIDR("192.168.1.0/24")
+	ips, err := proxy.Lookup("rebind.example.com")
+	if err != nil || len(ips) != 2 || ips[0] != "0.0.0.0" {
+		t.Errorf("Lookup() for listed network = %v, %v, want blocked IPs", ips, err)
+	}
+
+	// Die Allowlist hat Vorrang
+	allowlist := NewAllowlist()
+	allowlist.AddDomain("rebind.example.com")
+	proxy.SetAllowlist(allowlist)
+	ips, err = proxy.Lookup("rebind.example.com")
+	if err != nil || len(ips) != 1 || ips[0] != "192.168.1.1" {
+		t.Errorf("Lookup() for allowed domain = %v, %v, want upstream answer", ips, err)
+	}
+}
+
+func TestProxy_Resolve_SafeSearch(t *testing.T) {
+	blacklist := NewBlacklist()
+	blacklist.AddLocalRecord("forcesafesearch.google.com", "216.239.38.120")
+	blacklist.AddDomain("strict.bing.com")
+
+	// Ohne Server: das Ziel wird hier über einen lokalen Record aufgelöst
+	proxy := NewProxy(NewRegistry(), blacklist)
+	proxy.SetSafeSearch(NewSafeSearch())
+	if proxy.GetSafeSearch() == nil {
+		t.Fatal("GetSafeSearch() returned nil")
+	}
+
+	result, err := proxy.Resolve(context.Background(), "www.google.de")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if result.Rewrite != "forcesafesearch.google.com" || len(result.IPs) != 1 || result.IPs[0] != "216.239.38.120" {
+		t.Errorf("Resolve() = %+v, want rewrite to forcesafesearch.google.com", result)
+	}
+
+	// Ein blockiertes Ziel wird als Block-Antwort ohne Umschreibung geliefert
+	result, err = proxy.Resolve(context.Background(), "www.bing.com")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if !result.Blocked || result.Rewrite != "" {
+		t.Errorf("Resolve() with blocked target = %+v, want block response", result)
+	}
+
+	// Blockierte Suchmaschinen bleiben blockiert
+	blacklist.AddDomain("duckduckgo.com")
+	result, err = proxy.Resolve(context.Background(), "duckduckgo.com")
+	if err != nil || !result.Blocked || result.Match.Rule != "duckduckgo.com" {
+		t.Errorf("Resolve() for blocked search engine = %+v, %v, want blocked", result, err)
+	}
+
+	proxy.SetSafeSearch(nil)
+	if _, err := proxy.Resolve(context.Background(), "www.google.de"); err == nil {
+		t.Error("Resolve() without SafeSearch should go upstream and fail without servers")
+	}
+}
+
+func TestProxy_Resolve_SafeSearchPerGroup(t *testing.T) {
+	blacklist := NewBlacklist()
+	blacklist.AddLocalRecord("forcesafesearch.google.com", "216.239.38.120")
+
+	noSafeSearch := NewSafeSearch()
+	for p := SafeSearchGoogle; p <= SafeSearchYouTube; p++ {
+		noSafeSearch.SetProvider(p, false)
+	}
+
+	groups := NewClientGroups()
+	groups.AddGroup(ClientGroup{Name: "kids", Networks: []string{"192.168.20.0/24"}, SafeSearch: NewSafeSearch()})
+	groups.AddGroup(ClientGroup{Name: "adults", Networks: []string{"192.168.10.0/24"}, SafeSearch: noSafeSearch})
+
+	// Ohne Server: umgeschriebene Ziele kommen aus einem lokalen Record, alles andere schlägt fehl
+	proxy := NewProxy(NewRegistry(), blacklist)
+	proxy.SetClientGroups(groups)
+
+	resolve := func(client string) (*Result, error) {
+		ctx := WithClientAddr(context.Background(), netip.MustParseAddr(client))
+		return proxy.Resolve(ctx, "www.google.de")
+	}
+
+	// Nur die Gruppe mit SafeSearch wird umgeschrieben
+	if result, err := resolve("192.168.20.5"); err != nil || result.Rewrite != "forcesafesearch.google.com" {
+		t.Errorf("Resolve() for kids = %+v, %v, want rewrite", result, err)
+	}
+	if _, err := resolve("172.16.0.5"); err == nil {
+		t.Error("Resolve() for client without group should not be rewritten")
+	}
+
+	// Globales SafeSearch gilt für Clients ohne Gruppe, eine Gruppe kann es aufheben
+	proxy.SetSafeSearch(NewSafeSearch())
+	if result, err := resolve("172.16.0.5"); err != nil || result.Rewrite == "" {
+		t.Errorf("Resolve() with global SafeSearch = %+v, %v, want rewrite", result, err)
+	}
+	if _, err := resolve("192.168.10.5"); err == nil {
+		t.Error("Resolve() for group with disabled providers should not be rewritten")
+	}
+}
+
+func TestProxy_PauseBlocking(t *testing.T) {
+	port := startCNAMEUpstream(t, []string{"collect.tracker.example.org"}, "10.0.0.9")
+
+	registry := NewRegistry()
+	blacklist := NewBlacklist()
+	proxy := NewProxyWithCache(registry, blacklist, NewCache(time.Hour, time.Hour))
+	defer proxy.Close()
+	clock := &fakeClock{t: time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)}
+	proxy.now = clock.now
+
+	server, _ := NewServer("Local", "127.0.0.1", "", port)
+	registry.AddServer(server)
+
+	blacklist.AddDomain("ads.example.com")
+	blacklist.AddDomain("*.tracker.example.org")
+
+	if _, paused := proxy.PausedUntil(); paused || proxy.BlockingPaused() {
+		t.Fatal("new proxy should not be paused")
+	}
+
+	proxy.PauseBlocking(5 * time.Minute)
+	until, paused := proxy.PausedUntil()
+	if !paused || !until.Equal(clock.t.Add(5*time.Minute)) {
+		t.Errorf("PausedUntil() = %v, %v, want %v", until, paused, clock.t.Add(5*time.Minute))
+	}
+
+	// Während der Pause gehen auch gelistete Domains und Ketten zum Upstream, mit kurzer TTL
+	for _, domain := range []string{"ads.example.com", "cloaked.example.com"} {
+		result, err := proxy.Resolve(context.Background(), domain)
+		if err != nil {
+			t.Fatalf("Resolve(%s) while paused error = %v", domain, err)
+		}
+		if result.Blocked || len(result.IPs) != 1 || result.IPs[0] != "10.0.0.9" || result.TTL != pausedTTL {
+			t.Errorf("Resolve(%s) while paused = %+v, want upstream answer with TTL %d", domain, result, pausedTTL)
+		}
+	}
+
+	// Nach Ablauf wird automatisch wieder blockiert, auch für die während der Pause gecachten Antworten
+	clock.t = clock.t.Add(5 * time.Minute)
+	if proxy.BlockingPaused() {
+		t.Error("BlockingPaused() after deadline = true, want false")
+	}
+	for _, domain := range []string{"ads.example.com", "cloaked.example.com"} {
+		result, err := proxy.Resolve(context.Background(), domain)
+		if err != nil {
+			t.Fatalf("Resolve(%s) after pause error = %v", domain, err)
+		}
+		if !result.Blocked || result.TTL == pausedTTL {
+			t.Errorf("Resolve(%s) after pause = %+v, want blocked", domain, result)
+		}
+	}
+
+	// Ohne Dauer bis ResumeBlocking
+	proxy.PauseBlocking(0)
+	clock.t = clock.t.Add(24 * time.Hour)
+	if until, paused := proxy.PausedUntil(); !paused || !until.IsZero() {
+		t.Errorf("PausedUntil() without deadline = %v, %v, want zero time and paused", until, paused)
+	}
+	proxy.ResumeBlocking()
+	if proxy.BlockingPaused() {
+		t.Error("BlockingPaused() after ResumeBlocking() = true, want false")
+	}
+}
+
+func TestProxy_Resolve_ClientGroups(t *testing.T) {
+	defaultPort := startTestUpstream(t, "127.0.0.1:0", "10.0.0.1")
+	familyPort := startTestUpstream(t, "127.0.0.1:0", "10.0.0.2")
+
+	registry := NewRegistry()
+	defaultServer, _ := NewServer("Default", "127.0.0.1", "", defaultPort)
+	registry.AddServer(defaultServer)
+
+	familyRegistry := NewRegistry()
+	familyServer, _ := NewServer("Family", "127.0.0.1", "", familyPort)
+	familyRegistry.AddServer(familyServer)
+
+	blacklist := NewBlacklist()
+	blacklist.AddList(ListInfo{Name: "easylist", Category: "ads", Enabled: true})
+	blacklist.AddList(ListInfo{Name: "social", Category: "social", Enabled: true})
+	blacklist.LoadListContent("easylist", "||ads.example.com^\n")
+	blacklist.LoadListContent("social", "||social.example.com^\n")
+	blacklist.AddList(ListInfo{Name: "lan", Enabled: true})
+	blacklist.LoadListContent("lan", "192.168.1.50 nas.example.com\n")
+
+	guestAllowlist := NewAllowlist()
+	guestAllowlist.AddDomain("ads.example.com")
+
+	groups := NewClientGroups()
+	groups.AddGroup(ClientGroup{
+		Name:     "kids",
+		Networks: []string{"192.168.20.0/24"},
+		Registry: familyRegistry,
+		Block:    BlockResponse{Mode: BlockNXDomain, TTL: 60},
+	})
+	groups.AddGroup(ClientGroup{Name: "adults", Networks: []string{"192.168.10.0/24"}, Lists: []string{"ads"}})
+	groups.AddGroup(ClientGroup{Name: "servers", Networks: []string{"10.0.0.0/8"}, Unfiltered: true})
+	groups.AddGroup(ClientGroup{Name: "guests", Networks: []string{"192.168.30.0/24"}, Allowlist: guestAllowlist})
+
+	proxy := NewProxyWithCache(registry, blacklist, NewCache(time.Hour, time.Hour))
+	defer proxy.Close()
+	proxy.SetClientGroups(groups)
+	if proxy.GetClientGroups() != groups {
+		t.Error("GetClientGroups() returned wrong groups")
+	}
+
+	tests := []struct {
+		name      string
+		client    string
+		domain    string
+		wantGroup string
+		wantIP    string
+		wantRcode int
+	}{
+		{name: "Kids blocked with group block mode", client: "192.168.20.5", domain: "social.example.com", wantGroup: "kids", wantRcode: mdns.RcodeNameError},
+		{name: "Kids use own upstreams", client: "192.168.20.5", domain: "www.example.com", wantGroup: "kids", wantIP: "10.0.0.2"},
+		{name: "Adults ads only", client: "192.168.10.5", domain: "social.example.com", wantGroup: "adults", wantIP: "10.0.0.1"},
+		{name: "Adults blocked ads", client: "192.168.10.5", domain: "ads.example.com", wantGroup: "adults", wantIP: "0.0.0.0"},
+		{name: "Kids local record", client: "192.168.20.5", domain: "nas.example.com", wantGroup: "kids", wantIP: "192.168.1.50"},
+		{name: "Adults without local list", client: "192.168.10.5", domain: "nas.example.com", wantGroup: "adults", wantIP: "10.0.0.1"},
+		{name: "Servers unfiltered", client: "10.1.2.3", domain: "ads.example.com", wantGroup: "servers", wantIP: "10.0.0.1"},
+		{name: "Group allowlist", client: "192.168.30.5", domain: "ads.example.com", wantGroup: "guests", wantIP: "10.0.0.1"},
+		{name: "Group allowlist keeps other rules", client: "192.168.30.5", domain: "social.example.com", wantGroup: "guests", wantIP: "0.0.0.0"},
+		{name: "Client without group", client: "172.16.0.5", domain: "www.example.com", wantIP: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithClientAddr(context.Background(), netip.MustParseAddr(tt.client))
+			result, err := proxy.Resolve(WithQueryType(ctx, mdns.TypeA), tt.domain)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if result.Group != tt.wantGroup || result.Rcode != tt.wantRcode {
+				t.Errorf("Resolve() = %+v, want group %q and rcode %s", result, tt.wantGroup, mdns.RcodeToString[tt.wantRcode])
+			}
+			if tt.wantIP != "" && (len(result.IPs) == 0 || result.IPs[0] != tt.wantIP) {
+				t.Errorf("Resolve() IPs = %v, want %s", result.IPs, tt.wantIP)
+			}
+		})
+	}
+
+	// Der Cache trennt die Antworten der Upstream-Gruppen
+	ctx := WithClientAddr(context.Background(), netip.MustParseAddr("172.16.0.5"))
+	if ips, _ := proxy.LookupContext(ctx, "www.example.com"); len(ips) != 1 || ips[0] != "10.0.0.1" {
+		t.Errorf("LookupContext() without group = %v, want cached answer of default upstream", ips)
+	}
+}
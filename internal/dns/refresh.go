@@ -0,0 +1,95 @@
+package dns
+
+import (
+	"sync"
+	"time"
+)
+
+// ListRefresher aktualisiert die benannten Listen einer Blacklist in regelmäßigen Abständen
+// Unveränderte Quellen werden per ETag/Last-Modified erkannt und nicht neu geladen
+type ListRefresher struct {
+	blacklist *Blacklist
+	interval  time.Duration
+	onRefresh func([]RefreshResult)
+	last      []RefreshResult
+	mu        sync.Mutex
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+}
+
+// DefaultRefreshInterval ist der Abstand zwischen zwei Aktualisierungen, wenn keiner gesetzt ist
+const DefaultRefreshInterval = 24 * time.Hour
+
+// NewListRefresher startet die periodische Aktualisierung
+// interval: Abstand zwischen zwei Durchläufen (z.B. 24 Stunden), Werte <= 0 setzen den
+// Standard (DefaultRefreshInterval)
+func NewListRefresher(blacklist *Blacklist, interval time.Duration) *ListRefresher {
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+
+	r := &ListRefresher{
+		blacklist: blacklist,
+		interval:  interval,
+		stopChan:  make(chan struct{}),
+	}
+
+	// Starte Aktualisierung in Hintergrund-Goroutine
+	go r.refreshLoop()
+
+	return r
+}
+
+// SetOnRefresh setzt eine Funktion, die nach jedem Durchlauf mit den Ergebnissen aufgerufen wird
+// z.B. zum Loggen fehlgeschlagener Downloads
+func (r *ListRefresher) SetOnRefresh(fn func([]RefreshResult)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.onRefresh = fn
+}
+
+// RefreshNow aktualisiert alle Listen sofort und gibt die Ergebnisse zurück
+func (r *ListRefresher) RefreshNow() []RefreshResult {
+	results := r.blacklist.RefreshLists()
+
+	r.mu.Lock()
+	r.last = results
+	fn := r.onRefresh
+	r.mu.Unlock()
+
+	if fn != nil {
+		fn(results)
+	}
+	return results
+}
+
+// LastResults gibt die Ergebnisse des letzten Durchlaufs zurück
+func (r *ListRefresher) LastResults() []RefreshResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.last
+}
+
+// refreshLoop führt die Aktualisierung in regelmäßigen Abständen durch
+func (r *ListRefresher) refreshLoop() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.RefreshNow()
+		case <-r.stopChan:
+			return
+		}
+	}
+}
+
+// Stop stoppt die periodische Aktualisierung
+func (r *ListRefresher) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+	})
+}
@@ -0,0 +1,261 @@
+package dns
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// listHost ist ein Test-HTTP-Server für Blocklisten mit ETag und Last-Modified
+type listHost struct {
+	mu           sync.Mutex
+	content      string
+	version      int
+	modified     time.Time
+	status       int // != 0 erzwingt einen Status-Code
+	useETag      bool
+	requests     atomic.Int32
+	notModified  atomic.Int32
+	lastIfNone   string
+	lastIfModSin string
+}
+
+func (h *listHost) set(content string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.content = content
+	h.version++
+	h.modified = h.modified.Add(time.Minute)
+}
+
+func (h *listHost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.requests.Add(1)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastIfNone = r.Header.Get("If-None-Match")
+	h.lastIfModSin = r.Header.Get("If-Modified-Since")
+
+	if h.status != 0 {
+		w.WriteHeader(h.status)
+		return
+	}
+
+	if h.useETag {
+		etag := fmt.Sprintf(`"v%d"`, h.version)
+		w.Header().Set("ETag", etag)
+		if h.lastIfNone == etag {
+			h.notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else {
+		w.Header().Set("Last-Modified", h.modified.UTC().Format(http.TimeFormat))
+		if since, err := http.ParseTime(h.lastIfModSin); err == nil && !h.modified.Truncate(time.Second).After(since) {
+			h.notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	fmt.Fprint(w, h.content)
+}
+
+func newListHost(t *testing.T, content string, useETag bool) (*listHost, string) {
+	h := &listHost{useETag: useETag, modified: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	h.set(content)
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return h, srv.URL + "/hosts"
+}
+
+func TestBlacklist_RefreshList(t *testing.T) {
+	tests := []struct {
+		name    string
+		useETag bool
+	}{
+		{name: "ETag", useETag: true},
+		{name: "Last-Modified", useETag: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, url := newListHost(t, "0.0.0.0 old.example.com\n0.0.0.0 stable.example.com\n", tt.useETag)
+
+			bl := NewBlacklist()
+			bl.AddList(ListInfo{Name: "remote", Source: url, Enabled: true})
+
+			if added, err := bl.LoadList("remote"); err != nil || added != 2 {
+				t.Fatalf("LoadList() = %d, %v", added, err)
+			}
+
+			// Unveränderte Liste: bedingte Anfrage, keine neuen Regeln
+			updated, err := bl.RefreshList("remote")
+			if err != nil || updated {
+				t.Fatalf("RefreshList() unchanged = %v, %v, want false, nil", updated, err)
+			}
+			if host.notModified.Load() != 1 {
+				t.Errorf("server answered 304 %d times, want 1", host.notModified.Load())
+			}
+			if tt.useETag && host.lastIfNone == "" {
+				t.Error("RefreshList() should send If-None-Match")
+			}
+			if !tt.useETag && host.lastIfModSin == "" {
+				t.Error("RefreshList() should send If-Modified-Since")
+			}
+
+			// Geänderte Liste: entfernte Domains verschwinden
+			host.set("0.0.0.0 new.example.com\n0.0.0.0 stable.example.com\n")
+			updated, err = bl.RefreshList("remote")
+			if err != nil || !updated {
+				t.Fatalf("RefreshList() changed = %v, %v, want true, nil", updated, err)
+			}
+			if bl.IsBlocked("old.example.com") {
+				t.Error("domain removed upstream should no longer be blocked")
+			}
+			if !bl.IsBlocked("new.example.com") || !bl.IsBlocked("stable.example.com") {
+				t.Error("refreshed rules should be active")
+			}
+
+			info, _ := bl.GetList("remote")
+			if info.Count != 2 || info.Updated.IsZero() {
+				t.Errorf("GetList() = %+v", info)
+			}
+		})
+	}
+}
+
+func TestBlacklist_RefreshList_KeepsRulesOnError(t *testing.T) {
+	host, url := newListHost(t, "0.0.0.0 ads.example.com\n", true)
+
+	bl := NewBlacklist()
+	bl.AddList(ListInfo{Name: "remote", Source: url, Enabled: true})
+	if _, err := bl.LoadList("remote"); err != nil {
+		t.Fatalf("LoadList() error = %v", err)
+	}
+
+	host.mu.Lock()
+	host.status = http.StatusInternalServerError
+	host.mu.Unlock()
+
+	if updated, err := bl.RefreshList("remote"); err == nil || updated {
+		t.Errorf("RefreshList() = %v, %v, want error", updated, err)
+	}
+	if !bl.IsBlocked("ads.example.com") {
+		t.Error("failed refresh should keep the previous rules")
+	}
+}
+
+func TestBlacklist_RefreshLists_AtomicSwap(t *testing.T) {
+	host, url := newListHost(t, "", true)
+
+	// Große Liste, damit das Laden messbar dauert - "stable.example.com" ist in jeder Version
+	content := func(version int) string {
+		var b strings.Builder
+		b.WriteString("0.0.0.0 stable.example.com\n")
+		for i := 0; i < 5000; i++ {
+			fmt.Fprintf(&b, "0.0.0.0 host%d-v%d.example.com\n", i, version)
+		}
+		return b.String()
+	}
+	host.set(content(0))
+
+	bl := NewBlacklist()
+	bl.AddList(ListInfo{Name: "remote", Source: url, Enabled: true})
+	if _, err := bl.LoadList("remote"); err != nil {
+		t.Fatalf("LoadList() error = %v", err)
+	}
+
+	stop := make(chan struct{})
+	var misses atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if !bl.IsBlocked("stable.example.com") {
+					misses.Add(1)
+				}
+			}
+		}()
+	}
+
+	for version := 1; version <= 5; version++ {
+		host.set(content(version))
+		for _, result := range bl.RefreshLists() {
+			if result.Err != nil || !result.Updated {
+				t.Errorf("RefreshLists() result = %+v", result)
+			}
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if misses.Load() != 0 {
+		t.Errorf("lookups saw a half-loaded list %d times", misses.Load())
+	}
+}
+
+func TestListRefresher(t *testing.T) {
+	host, url := newListHost(t, "0.0.0.0 old.example.com\n", true)
+
+	bl := NewBlacklist()
+	bl.AddList(ListInfo{Name: "remote", Source: url, Enabled: true})
+	bl.AddList(ListInfo{Name: "manual", Enabled: true}) // ohne Quelle, wird übersprungen
+	if _, err := bl.LoadList("remote"); err != nil {
+		t.Fatalf("LoadList() error = %v", err)
+	}
+	host.set("0.0.0.0 new.example.com\n")
+
+	refresher := NewListRefresher(bl, 20*time.Millisecond)
+	defer refresher.Stop()
+
+	done := make(chan []RefreshResult, 10)
+	refresher.SetOnRefresh(func(results []RefreshResult) {
+		done <- results
+	})
+
+	select {
+	case results := <-done:
+		if len(results) != 1 || results[0].Name != "remote" {
+			t.Fatalf("results = %+v, want one result for remote", results)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ListRefresher did not refresh")
+	}
+
+	if !bl.IsBlocked("new.example.com") || bl.IsBlocked("old.example.com") {
+		t.Error("ListRefresher should swap in the new rules")
+	}
+	if len(refresher.LastResults()) != 1 {
+		t.Errorf("LastResults() = %+v", refresher.LastResults())
+	}
+
+	// Stop ist idempotent
+	refresher.Stop()
+	refresher.Stop()
+}
+
+func TestNewListRefresher_InvalidInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Minute} {
+		// Darf nicht in time.NewTicker paniken
+		refresher := NewListRefresher(NewBlacklist(), interval)
+		if refresher.interval != DefaultRefreshInterval {
+			t.Errorf("NewListRefresher(%v) interval = %v, want %v", interval, refresher.interval, DefaultRefreshInterval)
+		}
+		refresher.Stop()
+	}
+}
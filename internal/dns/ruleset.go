@@ -0,0 +1,267 @@
+package dns
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	mdns "github.com/miekg/dns"
+)
+
+// ruleKind unterscheidet blockierende Regeln von Ausnahmen
+type ruleKind int
+
+const (
+	// ruleBlock blockiert eine Domain (hosts-Einträge, "||example.com^")
+	ruleBlock ruleKind = iota
+	// ruleException hebt Block-Regeln auf ("@@||example.com^")
+	ruleException
+	// ruleImportant blockiert auch gegen Ausnahmen ("||example.com^$important")
+	ruleImportant
+	// ruleImportantException hebt auch $important-Regeln auf ("@@||example.com^$important")
+	ruleImportantException
+)
+
+// dnsTypeFilter schränkt eine Regel auf bestimmte Query-Typen ein ($dnstype)
+// Ein leerer Filter gilt für alle Typen
+type dnsTypeFilter struct {
+	types  map[uint16]bool
+	negate bool // "~A|~AAAA": alle Typen außer den genannten
+}
+
+// parseDNSTypeFilter parst den Wert eines $dnstype-Modifikators, z.B. "A|AAAA" oder "~CNAME"
+// Positive und negierte Typen dürfen nicht gemischt werden
+func parseDNSTypeFilter(value string) (dnsTypeFilter, error) {
+	filter := dnsTypeFilter{types: make(map[uint16]bool)}
+
+	for i, name := range strings.Split(value, "|") {
+		negated := strings.HasPrefix(name, "~")
+		if i == 0 {
+			filter.negate = negated
+		} else if negated != filter.negate {
+			return dnsTypeFilter{}, fmt.Errorf("cannot mix negated and plain types in dnstype: %s", value)
+		}
+
+		qtype, ok := mdns.StringToType[strings.ToUpper(strings.TrimPrefix(name, "~"))]
+		if !ok {
+			return dnsTypeFilter{}, fmt.Errorf("unknown dnstype: %s", name)
+		}
+		filter.types[qtype] = true
+	}
+
+	return filter, nil
+}
+
+// matches prüft, ob der Filter für einen Query-Typ gilt
+// qtype 0 (unbekannt) trifft nur ungefilterte Regeln
+func (f dnsTypeFilter) matches(qtype uint16) bool {
+	if len(f.types) == 0 {
+		return true
+	}
+	if qtype == 0 {
+		return false
+	}
+	return f.types[qtype] != f.negate
+}
+
+// key gibt eine stabile Darstellung des Filters zurück (z.B. "A|AAAA", "~A")
+func (f dnsTypeFilter) key() string {
+	if len(f.types) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(f.types))
+	for qtype := range f.types {
+		name := mdns.TypeToString[qtype]
+		if f.negate {
+			name = "~" + name
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, "|")
+}
+
+// layerKey identifiziert eine Regel-Ebene über Art, Typ-Filter und Zeitplan
+type layerKey struct {
+	kind     ruleKind
+	types    string
+	schedule string
+}
+
+// ruleLayer ist eine Menge von Domains mit gemeinsamer Art, gemeinsamem Typ-Filter und Zeitplan
+type ruleLayer struct {
+	key      layerKey
+	kind     ruleKind
+	filter   dnsTypeFilter
+	schedule *Schedule // nil = immer aktiv
+	set      *domainSet
+	patterns *patternSet // Regex- und Glob-Regeln
+}
+
+// match prüft Domains, Wildcards und Muster der Ebene und gibt die getroffene Regel zurück
+func (l *ruleLayer) match(domain string) (string, bool) {
+	if rule, ok := l.set.match(domain); ok {
+		return rule, true
+	}
+	return l.patterns.match(domain)
+}
+
+// ruleSet fasst alle Regel-Ebenen einer Blacklist zusammen
+// Die Anzahl der Ebenen ist klein (eine pro Art und $dnstype-Kombination),
+// die Suche innerhalb einer Ebene kostet O(Anzahl Labels)
+// Die Ebenen bleiben in der Reihenfolge ihres Anlegens, damit bei Treffern in mehreren
+// Ebenen immer dieselbe Regel gemeldet wird (Logs, Statistik)
+// Nicht thread-safe - die Blacklist sichert den Zugriff ab
+type ruleSet struct {
+	layers []*ruleLayer
+	local  map[string][]string // lokale A/AAAA-Records aus hosts-Dateien (Domain -> IPs)
+}
+
+// newRuleSet erstellt ein leeres ruleSet mit der Ebene für einfache Block-Regeln
+func newRuleSet() *ruleSet {
+	r := &ruleSet{
+		local: make(map[string][]string),
+	}
+	r.layer(ruleBlock, dnsTypeFilter{}, nil)
+	return r
+}
+
+// layer gibt die Ebene für Art, Filter und Zeitplan zurück und legt sie bei Bedarf an
+func (r *ruleSet) layer(kind ruleKind, filter dnsTypeFilter, schedule *Schedule) *ruleLayer {
+	key := layerKey{kind: kind, types: filter.key(), schedule: schedule.String()}
+	for _, l := range r.layers {
+		if l.key == key {
+			return l
+		}
+	}
+
+	l := &ruleLayer{key: key, kind: kind, filter: filter, schedule: schedule, set: newDomainSet(), patterns: newPatternSet()}
+	r.layers = append(r.layers, l)
+	return l
+}
+
+// allPatterns gibt die Regex- und Glob-Regeln aller Block-Ebenen zurück
+func (r *ruleSet) allPatterns() []string {
+	var exprs []string
+	for _, l := range r.layers {
+		if l.kind == ruleBlock || l.kind == ruleImportant {
+			exprs = append(exprs, l.patterns.all()...)
+		}
+	}
+	return exprs
+}
+
+// defaultLayer ist die Ebene für einfache Block-Regeln ohne Modifikatoren
+// Sie wird in newRuleSet als erste Ebene angelegt
+func (r *ruleSet) defaultLayer() *domainSet {
+	return r.layers[0].set
+}
+
+// add fügt eine Regel in die passende Ebene ein
+// Unterstützt "example.com", "*.example.com", Globs ("*tracking*.example.*") und Regexe ("/^ad[0-9]+\./")
+// Muster werden beim Hinzufügen kompiliert, ungültige Muster liefern einen Fehler
+func (r *ruleSet) add(domain string, kind ruleKind, filter dnsTypeFilter) error {
+	return r.addScheduled(domain, kind, filter, nil)
+}
+
+// addScheduled fügt eine Regel ein, die nur während des Zeitplans gilt (nil = immer)
+func (r *ruleSet) addScheduled(domain string, kind ruleKind, filter dnsTypeFilter, schedule *Schedule) error {
+	l := r.layer(kind, filter, schedule)
+	if isPatternRule(domain) {
+		// Regexe behalten ihre Schreibweise, Globs werden wie Domains normalisiert
+		if !isRegexRule(domain) {
+			domain = normalizeDomain(domain)
+		}
+		return l.patterns.add(domain)
+	}
+	return l.set.add(domain)
+}
+
+// merge übernimmt alle Regeln und lokalen Records eines anderen ruleSet
+// Bereits kompilierte Muster werden übernommen, nicht neu kompiliert
+func (r *ruleSet) merge(other *ruleSet) {
+	for _, ol := range other.layers {
+		l := r.layer(ol.kind, ol.filter, ol.schedule)
+		l.set.merge(ol.set)
+		l.patterns.merge(ol.patterns)
+	}
+	for domain, ips := range other.local {
+		for _, ip := range ips {
+			if !slices.Contains(r.local[domain], ip) {
+				r.local[domain] = append(r.local[domain], ip)
+			}
+		}
+	}
+}
+
+// compact kompaktiert Domains und Wildcards aller Ebenen, siehe domainSet.compact
+func (r *ruleSet) compact() {
+	for _, l := range r.layers {
+		l.set.compact()
+	}
+}
+
+// addLocal fügt einen lokalen Record hinzu, doppelte IPs werden ignoriert
+func (r *ruleSet) addLocal(domain string, addr netip.Addr) {
+	ip := addr.String()
+	if !slices.Contains(r.local[domain], ip) {
+		r.local[domain] = append(r.local[domain], ip)
+	}
+}
+
+// remove entfernt eine Regel aus allen Ebenen und die lokalen Records der Domain
+func (r *ruleSet) remove(domain string) {
+	delete(r.local, normalizeDomain(domain))
+
+	pattern := isPatternRule(domain)
+	if pattern && !isRegexRule(domain) {
+		domain = normalizeDomain(domain)
+	}
+
+	for _, l := range r.layers {
+		if pattern {
+			l.patterns.remove(domain)
+		} else {
+			l.set.remove(domain)
+		}
+	}
+}
+
+// match gibt die Regel einer Ebene der angegebenen Art zurück, die die Domain für qtype trifft
+// Ebenen mit Zeitplan gelten nur, wenn er zum Zeitpunkt now aktiv ist
+// domain muss bereits normalisiert sein
+func (r *ruleSet) match(kind ruleKind, domain string, qtype uint16, now time.Time) (string, bool) {
+	for _, l := range r.layers {
+		if l.kind != kind || !l.filter.matches(qtype) || !l.schedule.Active(now) {
+			continue
+		}
+		if rule, ok := l.match(domain); ok {
+			return rule, true
+		}
+	}
+	return "", false
+}
+
+// rulePriority ist die Reihenfolge, in der die Arten ausgewertet werden:
+// wichtige Ausnahmen vor $important vor Ausnahmen (@@) vor normalen Block-Regeln
+// Die erste Art mit Treffer entscheidet über alle Listen hinweg
+var rulePriority = []ruleKind{ruleImportantException, ruleImportant, ruleException, ruleBlock}
+
+// blocks gibt an, ob ein Treffer dieser Art die Domain blockiert
+func (k ruleKind) blocks() bool {
+	return k == ruleBlock || k == ruleImportant
+}
+
+// count gibt die Anzahl aller Regeln über alle Ebenen zurück
+func (r *ruleSet) count() int {
+	total := len(r.local)
+	for _, l := range r.layers {
+		total += l.set.count() + l.patterns.count()
+	}
+	return total
+}
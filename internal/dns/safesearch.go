@@ -0,0 +1,174 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SafeSearchProvider ist eine Suchmaschine bzw. Plattform mit erzwingbarem SafeSearch
+type SafeSearchProvider int
+
+const (
+	// SafeSearchGoogle leitet die Google-Suche (alle Länder-Domains) auf forcesafesearch.google.com um
+	SafeSearchGoogle SafeSearchProvider = iota
+	// SafeSearchBing leitet Bing auf strict.bing.com um
+	SafeSearchBing
+	// SafeSearchDuckDuckGo leitet DuckDuckGo auf safe.duckduckgo.com um
+	SafeSearchDuckDuckGo
+	// SafeSearchYouTube leitet YouTube auf restrict.youtube.com (bzw. restrictmoderate) um
+	SafeSearchYouTube
+)
+
+// Ziele der Umschreibung, von den Anbietern für das Erzwingen von SafeSearch vorgesehen
+const (
+	googleSafeSearchTarget     = "forcesafesearch.google.com"
+	bingSafeSearchTarget       = "strict.bing.com"
+	duckDuckGoSafeSearchTarget = "safe.duckduckgo.com"
+	youTubeStrictTarget        = "restrict.youtube.com"
+	youTubeModerateTarget      = "restrictmoderate.youtube.com"
+)
+
+// safeSearchHosts sind die umgeschriebenen Hostnamen je Anbieter (Google über isGoogleSearchHost)
+var safeSearchHosts = map[string]SafeSearchProvider{
+	"bing.com":                 SafeSearchBing,
+	"www.bing.com":             SafeSearchBing,
+	"duckduckgo.com":           SafeSearchDuckDuckGo,
+	"www.duckduckgo.com":       SafeSearchDuckDuckGo,
+	"start.duckduckgo.com":     SafeSearchDuckDuckGo,
+	"html.duckduckgo.com":      SafeSearchDuckDuckGo,
+	"www.youtube.com":          SafeSearchYouTube,
+	"m.youtube.com":            SafeSearchYouTube,
+	"youtubei.googleapis.com":  SafeSearchYouTube,
+	"youtube.googleapis.com":   SafeSearchYouTube,
+	"www.youtube-nocookie.com": SafeSearchYouTube,
+}
+
+// String gibt den Namen des Anbieters zurück
+func (p SafeSearchProvider) String() string {
+	switch p {
+	case SafeSearchGoogle:
+		return "google"
+	case SafeSearchBing:
+		return "bing"
+	case SafeSearchDuckDuckGo:
+		return "duckduckgo"
+	case SafeSearchYouTube:
+		return "youtube"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseSafeSearchProvider wandelt einen Namen ("google", "youtube", ...) in einen Anbieter um
+func ParseSafeSearchProvider(name string) (SafeSearchProvider, error) {
+	for p := SafeSearchGoogle; p <= SafeSearchYouTube; p++ {
+		if strings.EqualFold(name, p.String()) {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown safesearch provider: %s", name)
+}
+
+// SafeSearch schreibt die Hostnamen von Suchmaschinen per CNAME auf ihre SafeSearch-Varianten um
+// Der Proxy beantwortet z.B. "www.google.de" mit einem CNAME auf "forcesafesearch.google.com",
+// noch bevor ein Upstream gefragt wird. Alle Anbieter sind einzeln abschaltbar.
+type SafeSearch struct {
+	enabled         map[SafeSearchProvider]bool
+	youTubeModerate bool
+	mu              sync.RWMutex
+}
+
+// NewSafeSearch erstellt eine Umschreibungstabelle mit allen Anbietern aktiv
+// YouTube wird standardmäßig streng eingeschränkt (restrict.youtube.com)
+func NewSafeSearch() *SafeSearch {
+	return &SafeSearch{
+		enabled: map[SafeSearchProvider]bool{
+			SafeSearchGoogle:     true,
+			SafeSearchBing:       true,
+			SafeSearchDuckDuckGo: true,
+			SafeSearchYouTube:    true,
+		},
+	}
+}
+
+// SetProvider aktiviert oder deaktiviert die Umschreibung für einen Anbieter
+func (s *SafeSearch) SetProvider(provider SafeSearchProvider, enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.enabled[provider] = enabled
+}
+
+// IsEnabled prüft, ob die Umschreibung für einen Anbieter aktiv ist
+func (s *SafeSearch) IsEnabled(provider SafeSearchProvider) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.enabled[provider]
+}
+
+// SetYouTubeModerate wählt den moderaten statt des strengen eingeschränkten Modus für YouTube
+func (s *SafeSearch) SetYouTubeModerate(moderate bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.youTubeModerate = moderate
+}
+
+// Rewrite gibt das CNAME-Ziel für eine Domain zurück
+// ok ist false, wenn die Domain nicht zu einem aktiven Anbieter gehört
+func (s *SafeSearch) Rewrite(domain string) (target string, ok bool) {
+	domain = normalizeDomain(strings.TrimSuffix(domain, "."))
+
+	provider, found := safeSearchHosts[domain]
+	if !found {
+		if !isGoogleSearchHost(domain) {
+			return "", false
+		}
+		provider = SafeSearchGoogle
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.enabled[provider] {
+		return "", false
+	}
+
+	switch provider {
+	case SafeSearchGoogle:
+		return googleSafeSearchTarget, true
+	case SafeSearchBing:
+		return bingSafeSearchTarget, true
+	case SafeSearchDuckDuckGo:
+		return duckDuckGoSafeSearchTarget, true
+	default:
+		if s.youTubeModerate {
+			return youTubeModerateTarget, true
+		}
+		return youTubeStrictTarget, true
+	}
+}
+
+// isGoogleSearchHost erkennt die Such-Domains von Google in allen Ländern
+// ("google.com", "www.google.de", "www.google.co.uk", "google.com.au"), ohne die
+// rund 190 Länder-Domains einzeln aufzuzählen
+func isGoogleSearchHost(domain string) bool {
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(domain, "www."), "google.")
+	if !ok {
+		return false
+	}
+
+	labels := strings.Split(rest, ".")
+	switch len(labels) {
+	case 1:
+		// "com" oder eine Länder-TLD ("de"), nicht "google.dev"
+		return labels[0] == "com" || len(labels[0]) == 2
+	case 2:
+		// Second-Level unter einer Länder-TLD ("co.uk", "com.au")
+		return (labels[0] == "co" || labels[0] == "com") && len(labels[1]) == 2
+	default:
+		return false
+	}
+}
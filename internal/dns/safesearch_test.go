@@ -0,0 +1,71 @@
+package dns
+
+import "testing"
+
+func TestSafeSearch_Rewrite(t *testing.T) {
+	s := NewSafeSearch()
+
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{domain: "www.google.com", want: "forcesafesearch.google.com"},
+		{domain: "google.com", want: "forcesafesearch.google.com"},
+		{domain: "www.google.de", want: "forcesafesearch.google.com"},
+		{domain: "WWW.Google.Co.UK.", want: "forcesafesearch.google.com"},
+		{domain: "google.com.au", want: "forcesafesearch.google.com"},
+		{domain: "www.bing.com", want: "strict.bing.com"},
+		{domain: "duckduckgo.com", want: "safe.duckduckgo.com"},
+		{domain: "start.duckduckgo.com", want: "safe.duckduckgo.com"},
+		{domain: "www.youtube.com", want: "restrict.youtube.com"},
+		{domain: "youtubei.googleapis.com", want: "restrict.youtube.com"},
+		// Andere Google-Dienste und Ziele selbst bleiben unverändert
+		{domain: "mail.google.com"},
+		{domain: "forcesafesearch.google.com"},
+		{domain: "google.dev"},
+		{domain: "www.google.example.com"},
+		{domain: "strict.bing.com"},
+		{domain: "example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			got, ok := s.Rewrite(tt.domain)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("Rewrite(%q) = %q, %v, want %q", tt.domain, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestSafeSearch_Providers(t *testing.T) {
+	s := NewSafeSearch()
+
+	s.SetProvider(SafeSearchBing, false)
+	if s.IsEnabled(SafeSearchBing) {
+		t.Error("IsEnabled(bing) = true after SetProvider(false)")
+	}
+	if target, ok := s.Rewrite("www.bing.com"); ok {
+		t.Errorf("Rewrite() for disabled provider = %q, want no rewrite", target)
+	}
+	if _, ok := s.Rewrite("www.google.com"); !ok {
+		t.Error("Rewrite() for other providers should stay active")
+	}
+
+	s.SetYouTubeModerate(true)
+	if target, _ := s.Rewrite("m.youtube.com"); target != "restrictmoderate.youtube.com" {
+		t.Errorf("Rewrite() with moderate YouTube = %q, want restrictmoderate.youtube.com", target)
+	}
+}
+
+func TestParseSafeSearchProvider(t *testing.T) {
+	for p := SafeSearchGoogle; p <= SafeSearchYouTube; p++ {
+		got, err := ParseSafeSearchProvider(p.String())
+		if err != nil || got != p {
+			t.Errorf("ParseSafeSearchProvider(%q) = %v, %v, want %v", p.String(), got, err, p)
+		}
+	}
+	if _, err := ParseSafeSearchProvider("altavista"); err == nil {
+		t.Error("ParseSafeSearchProvider() with unknown name should return error")
+	}
+}
@@ -0,0 +1,240 @@
+package dns
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Schedule legt fest, wann eine Liste oder Regel aktiv ist
+// Außerhalb des Zeitplans blockiert sie nicht, z.B. Social Media nur werktags von 08:00 bis 17:00
+type Schedule struct {
+	Days     []time.Weekday // aktive Wochentage, leer = jeden Tag
+	Ranges   []TimeRange    // aktive Zeiträume, leer = ganztägig
+	Location *time.Location // Zeitzone, nil = Ortszeit des Servers
+}
+
+// TimeRange ist ein Zeitraum innerhalb eines Tages als Abstand zu Mitternacht
+// Ist End kleiner als Start, reicht der Zeitraum über Mitternacht (22:00-06:00) und gehört
+// zu dem Tag, an dem er beginnt
+type TimeRange struct {
+	Start time.Duration // inklusiv
+	End   time.Duration // exklusiv, 24h für das Tagesende
+}
+
+// weekdayNames sind die Kurznamen der Wochentage für ParseSchedule und String
+var weekdayNames = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// ParseSchedule parst einen Zeitplan wie "Mon-Fri 08:00-17:00 Europe/Berlin"
+// Alle Teile sind optional: Wochentage ("Mon-Fri", "Sat,Sun"), Zeiträume
+// ("08:00-12:00,13:00-17:00", "22:00-06:00") und eine Zeitzone (IANA-Name, "UTC" oder "Local")
+func ParseSchedule(s string) (Schedule, error) {
+	var schedule Schedule
+	for _, field := range strings.Fields(s) {
+		switch {
+		case strings.Contains(field, ":"):
+			for _, part := range strings.Split(field, ",") {
+				r, err := parseTimeRange(part)
+				if err != nil {
+					return Schedule{}, err
+				}
+				schedule.Ranges = append(schedule.Ranges, r)
+			}
+		case isWeekdayField(field):
+			days, err := parseWeekdays(field)
+			if err != nil {
+				return Schedule{}, err
+			}
+			schedule.Days = append(schedule.Days, days...)
+		default:
+			loc, err := time.LoadLocation(field)
+			if err != nil {
+				return Schedule{}, fmt.Errorf("invalid timezone in schedule: %s", field)
+			}
+			schedule.Location = loc
+		}
+	}
+
+	if err := schedule.validate(); err != nil {
+		return Schedule{}, err
+	}
+	return schedule, nil
+}
+
+// isWeekdayField prüft, ob ein Feld mit einem Wochentag beginnt
+func isWeekdayField(field string) bool {
+	first, _, _ := strings.Cut(strings.SplitN(field, ",", 2)[0], "-")
+	_, ok := parseWeekday(first)
+	return ok
+}
+
+// parseWeekday wandelt "Mon" oder "monday" in einen Wochentag um
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day, short := range weekdayNames {
+		full := time.Weekday(day).String()
+		if strings.EqualFold(name, short) || strings.EqualFold(name, full) {
+			return time.Weekday(day), true
+		}
+	}
+	return 0, false
+}
+
+// parseWeekdays parst eine Liste von Wochentagen und Bereichen ("Mon-Fri", "Sat,Sun", "Fri-Mon")
+func parseWeekdays(field string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, part := range strings.Split(field, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := parseWeekday(from)
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday in schedule: %s", from)
+		}
+		if !isRange {
+			days = append(days, first)
+			continue
+		}
+		last, ok := parseWeekday(to)
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday in schedule: %s", to)
+		}
+		// Bereiche dürfen über das Wochenende reichen ("Fri-Mon")
+		for day := first; ; day = (day + 1) % 7 {
+			days = append(days, day)
+			if day == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseTimeRange parst einen Zeitraum wie "08:00-17:00"; "24:00" steht für das Tagesende
+func parseTimeRange(s string) (TimeRange, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return TimeRange{}, fmt.Errorf("invalid time range in schedule: %s", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return TimeRange{}, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return TimeRange{}, err
+	}
+	return TimeRange{Start: start, End: end}, nil
+}
+
+// parseClock parst eine Uhrzeit "HH:MM" als Abstand zu Mitternacht
+func parseClock(s string) (time.Duration, error) {
+	var hour, minute int
+	if n, err := fmt.Sscanf(s, "%d:%d", &hour, &minute); err != nil || n != 2 || len(s) != 5 {
+		return 0, fmt.Errorf("invalid time in schedule: %s", s)
+	}
+	if hour < 0 || hour > 24 || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time in schedule: %s", s)
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+}
+
+// validate prüft Wochentage und Zeiträume
+func (s Schedule) validate() error {
+	for _, day := range s.Days {
+		if day < time.Sunday || day > time.Saturday {
+			return fmt.Errorf("invalid weekday in schedule: %d", day)
+		}
+	}
+	for _, r := range s.Ranges {
+		if r.Start < 0 || r.Start >= 24*time.Hour || r.End <= 0 || r.End > 24*time.Hour {
+			return fmt.Errorf("invalid time range in schedule: %s", r)
+		}
+		if r.Start == r.End {
+			return fmt.Errorf("empty time range in schedule: %s", r)
+		}
+	}
+	return nil
+}
+
+// Active prüft, ob der Zeitplan zum Zeitpunkt t aktiv ist
+// Ein nil-Zeitplan ist immer aktiv
+func (s *Schedule) Active(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+
+	loc := s.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+
+	day := t.Weekday()
+	if len(s.Ranges) == 0 {
+		return s.onDay(day)
+	}
+
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	for _, r := range s.Ranges {
+		if r.Start < r.End {
+			if s.onDay(day) && clock >= r.Start && clock < r.End {
+				return true
+			}
+			continue
+		}
+		// Über Mitternacht: der Teil nach Mitternacht gehört zum Vortag
+		if s.onDay(day) && clock >= r.Start {
+			return true
+		}
+		if s.onDay((day+6)%7) && clock < r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// onDay prüft, ob der Zeitplan an einem Wochentag gilt
+func (s *Schedule) onDay(day time.Weekday) bool {
+	return len(s.Days) == 0 || slices.Contains(s.Days, day)
+}
+
+// String gibt den Zeitplan in der Schreibweise von ParseSchedule zurück
+// Dient auch als Schlüssel, um Regeln mit gleichem Zeitplan zusammenzufassen
+func (s *Schedule) String() string {
+	if s == nil {
+		return ""
+	}
+
+	var fields []string
+	if len(s.Days) > 0 {
+		days := slices.Clone(s.Days)
+		slices.Sort(days)
+		days = slices.Compact(days)
+		names := make([]string, len(days))
+		for i, day := range days {
+			names[i] = weekdayNames[day]
+		}
+		fields = append(fields, strings.Join(names, ","))
+	}
+	if len(s.Ranges) > 0 {
+		ranges := make([]string, len(s.Ranges))
+		for i, r := range s.Ranges {
+			ranges[i] = r.String()
+		}
+		fields = append(fields, strings.Join(ranges, ","))
+	}
+	if s.Location != nil {
+		fields = append(fields, s.Location.String())
+	}
+	return strings.Join(fields, " ")
+}
+
+// String gibt den Zeitraum als "HH:MM-HH:MM" zurück
+func (r TimeRange) String() string {
+	return formatClock(r.Start) + "-" + formatClock(r.End)
+}
+
+// formatClock formatiert einen Abstand zu Mitternacht als "HH:MM"
+func formatClock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
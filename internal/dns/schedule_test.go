@@ -0,0 +1,164 @@
+package dns
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock ist eine austauschbare Uhr für Tests mit Zeitplänen
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "Mon-Fri 08:00-17:00 Europe/Berlin", want: "Mon,Tue,Wed,Thu,Fri 08:00-17:00 Europe/Berlin"},
+		{input: "sat,SUNDAY", want: "Sun,Sat"},
+		{input: "Fri-Mon 22:00-06:00", want: "Sun,Mon,Fri,Sat 22:00-06:00"},
+		{input: "08:00-12:00,13:00-24:00 UTC", want: "08:00-12:00,13:00-24:00 UTC"},
+		{input: "", want: ""},
+		{input: "Mon-Foo 08:00-17:00", wantErr: true},
+		{input: "Mon 8:00-17:00", wantErr: true},
+		{input: "Mon 08:00-25:00", wantErr: true},
+		{input: "Mon 08:00-08:00", wantErr: true},
+		{input: "Mon 08:00", wantErr: true},
+		{input: "Mon Mars/Olympus", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSchedule(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSchedule(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("ParseSchedule(%q) = %q, want %q", tt.input, got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedule_Active(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+
+	office, _ := ParseSchedule("Mon-Fri 08:00-17:00 Europe/Berlin")
+	night, _ := ParseSchedule("Fri 22:00-06:00 UTC")
+
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		at       time.Time
+		want     bool
+	}{
+		{name: "Weekday during office hours", schedule: &office, at: time.Date(2026, 10, 19, 8, 0, 0, 0, berlin), want: true},
+		{name: "Weekday end is exclusive", schedule: &office, at: time.Date(2026, 10, 19, 17, 0, 0, 0, berlin), want: false},
+		{name: "Weekday before office hours", schedule: &office, at: time.Date(2026, 10, 19, 7, 59, 59, 0, berlin), want: false},
+		{name: "Saturday", schedule: &office, at: time.Date(2026, 10, 17, 12, 0, 0, 0, berlin), want: false},
+		// 06:30 UTC sind 08:30 in Berlin (Sommerzeit)
+		{name: "Other timezone", schedule: &office, at: time.Date(2026, 10, 19, 6, 30, 0, 0, time.UTC), want: true},
+		{name: "Overnight start day", schedule: &night, at: time.Date(2026, 10, 23, 23, 0, 0, 0, time.UTC), want: true},
+		{name: "Overnight next morning", schedule: &night, at: time.Date(2026, 10, 24, 5, 59, 0, 0, time.UTC), want: true},
+		{name: "Overnight after end", schedule: &night, at: time.Date(2026, 10, 24, 6, 0, 0, 0, time.UTC), want: false},
+		{name: "Overnight wrong day", schedule: &night, at: time.Date(2026, 10, 22, 23, 0, 0, 0, time.UTC), want: false},
+		{name: "Nil schedule", schedule: nil, at: time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Active(tt.at); got != tt.want {
+				t.Errorf("Active(%v) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlacklist_ListSchedule(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)} // Montag
+	b := NewBlacklist()
+	b.SetClock(clock.now)
+
+	office, _ := ParseSchedule("Mon-Fri 08:00-17:00 UTC")
+	b.AddList(ListInfo{Name: "social", Category: "social", Enabled: true, Schedule: &office})
+	b.AddList(ListInfo{Name: "games", Category: "gaming", Enabled: true})
+	b.LoadListContent("social", "||facebook.com^\n")
+	b.LoadListContent("games", "||steampowered.com^\n")
+
+	if !b.IsBlocked("www.facebook.com") {
+		t.Error("IsBlocked() during schedule = false, want true")
+	}
+
+	clock.t = time.Date(2026, 10, 19, 18, 0, 0, 0, time.UTC)
+	if b.IsBlocked("www.facebook.com") {
+		t.Error("IsBlocked() after hours = true, want false")
+	}
+	if !b.IsBlocked("store.steampowered.com") {
+		t.Error("IsBlocked() for list without schedule = false, want true")
+	}
+
+	// Zeitplan für eine Kategorie
+	if n, err := b.SetCategorySchedule("gaming", &office); err != nil || n != 1 {
+		t.Fatalf("SetCategorySchedule() = %d, %v, want 1", n, err)
+	}
+	if b.IsBlocked("store.steampowered.com") {
+		t.Error("IsBlocked() after hours with category schedule = true, want false")
+	}
+	if info, _ := b.GetList("games"); info.Schedule.String() != office.String() {
+		t.Errorf("GetList().Schedule = %q, want %q", info.Schedule.String(), office.String())
+	}
+
+	// nil macht die Liste wieder immer aktiv
+	b.SetListSchedule("social", nil)
+	if !b.IsBlocked("www.facebook.com") {
+		t.Error("IsBlocked() after removing schedule = false, want true")
+	}
+
+	if err := b.SetListSchedule("social", &Schedule{Ranges: []TimeRange{{Start: time.Hour, End: time.Hour}}}); err == nil {
+		t.Error("SetListSchedule() with empty range should return error")
+	}
+	if err := b.SetListSchedule("missing", nil); err == nil {
+		t.Error("SetListSchedule() for unknown list should return error")
+	}
+}
+
+func TestBlacklist_AddScheduledDomain(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)} // Samstag
+	b := NewBlacklist()
+	b.SetClock(clock.now)
+
+	weekend, _ := ParseSchedule("Sat,Sun UTC")
+	if err := b.AddScheduledDomain("*.twitch.tv", weekend); err != nil {
+		t.Fatalf("AddScheduledDomain() error = %v", err)
+	}
+	b.AddDomain("ads.example.com")
+
+	match := b.Match("www.twitch.tv", 0)
+	if !match.Blocked || match.Rule != "*.twitch.tv" {
+		t.Errorf("Match() on weekend = %+v, want blocked by *.twitch.tv", match)
+	}
+
+	clock.t = clock.t.AddDate(0, 0, 2) // Montag
+	if b.IsBlocked("www.twitch.tv") {
+		t.Error("IsBlocked() on weekday = true, want false")
+	}
+	if !b.IsBlocked("ads.example.com") {
+		t.Error("IsBlocked() for rule without schedule = false, want true")
+	}
+
+	// Zeitgesteuerte Regeln lassen sich wie andere entfernen
+	b.RemoveDomain("*.twitch.tv")
+	clock.t = clock.t.AddDate(0, 0, -2)
+	if b.IsBlocked("www.twitch.tv") {
+		t.Error("IsBlocked() after RemoveDomain() = true, want false")
+	}
+}
@@ -1,6 +1,15 @@
 package dns
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
 
 // DNSServer definiert das Interface für DNS-Server
 type DNSServer interface {
@@ -8,59 +17,304 @@ type DNSServer interface {
 	GetIPv4() string
 	GetIPv6() string
 	GetAddress() string
+	GetAddresses() []string
+	GetHostname() string
+	GetProtocol() string
 }
 
+// AddressFamily legt fest, welche Adressfamilie eines Servers zuerst versucht wird
+type AddressFamily int
+
+const (
+	// PreferIPv4 versucht zuerst IPv4, dann IPv6 (Standard)
+	PreferIPv4 AddressFamily = iota
+	// PreferIPv6 versucht zuerst IPv6, dann IPv4
+	PreferIPv6
+)
+
 // Server repräsentiert einen DNS-Server mit seinen Eigenschaften
 type Server struct {
-	Name string
-	IPv4 string
-	IPv6 string
-	Port int
+	Name       string
+	Hostname   string // Optional: Hostname, aus dem IPv4/IPv6 aufgelöst wurden
+	IPv4       string
+	IPv6       string
+	Port       int
+	Preference AddressFamily
+	Protocol   string // ProtocolUDP (Standard), ProtocolTCP oder ProtocolTLS
+
+	resolver  BootstrapResolver // Nur bei Hostname-Servern gesetzt
+	expires   time.Time         // Ablauf der aufgelösten Adressen
+	mu        sync.RWMutex      // Schützt IPv4, IPv6 und expires beim Refresh
+	refreshMu sync.Mutex        // Verhindert parallele Refreshes
+}
+
+// BootstrapResolver löst Hostnamen von Upstream-Servern in IP-Adressen auf
+// *net.Resolver und *Bootstrap erfüllen dieses Interface
+type BootstrapResolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// ttlResolver wird von Resolvern erfüllt, die zusätzlich die TTL liefern (z.B. *Bootstrap)
+type ttlResolver interface {
+	ResolveTTL(ctx context.Context, host string) ([]netip.Addr, time.Duration, error)
 }
 
 // NewServer erstellt eine neue Server-Instanz mit Validierung
+// Mindestens eine der Adressen muss gesetzt sein, beide müssen zur jeweiligen Familie passen
 func NewServer(name, ipv4, ipv6 string, port int) (*Server, error) {
 	if name == "" {
 		return nil, fmt.Errorf("server name cannot be empty")
 	}
-	if ipv4 == "" {
-		return nil, fmt.Errorf("IPv4 address cannot be empty")
+	if ipv4 == "" && ipv6 == "" {
+		return nil, fmt.Errorf("server needs at least one IPv4 or IPv6 address")
+	}
+	if ipv4 != "" && !isIPv4Literal(ipv4) {
+		return nil, fmt.Errorf("invalid IPv4 address: %s", ipv4)
+	}
+	if ipv6 != "" && !isIPv6Literal(ipv6) {
+		return nil, fmt.Errorf("invalid IPv6 address: %s", ipv6)
 	}
 	if port <= 0 || port > 65535 {
 		return nil, fmt.Errorf("port must be between 1 and 65535")
 	}
 
 	return &Server{
-		Name: name,
-		IPv4: ipv4,
-		IPv6: ipv6,
-		Port: port,
+		Name:     name,
+		IPv4:     ipv4,
+		IPv6:     ipv6,
+		Port:     port,
+		Protocol: ProtocolUDP,
 	}, nil
 }
 
+// NewServerFromHost erstellt einen Server aus einem Hostnamen (z.B. "dns.quad9.net")
+// Der Hostname wird über den Bootstrap-Resolver aufgelöst, es wird je Adressfamilie
+// die erste gefundene Adresse übernommen. Nach Ablauf der TTL (bei *Bootstrap) bzw.
+// defaultBootstrapTTL werden die Adressen über Refresh neu aufgelöst.
+func NewServerFromHost(ctx context.Context, name, host string, port int, resolver BootstrapResolver) (*Server, error) {
+	if host == "" {
+		return nil, fmt.Errorf("hostname cannot be empty")
+	}
+	if !isValidHostname(host) {
+		return nil, fmt.Errorf("invalid hostname: %s", host)
+	}
+	if resolver == nil {
+		return nil, fmt.Errorf("bootstrap resolver cannot be nil")
+	}
+
+	ipv4, ipv6, ttl, err := resolveHost(ctx, host, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := NewServer(name, ipv4, ipv6, port)
+	if err != nil {
+		return nil, err
+	}
+	server.Hostname = host
+	server.resolver = resolver
+	server.expires = time.Now().Add(ttl)
+
+	return server, nil
+}
+
+// resolveHost löst einen Hostnamen auf und wählt je Adressfamilie die erste Adresse
+func resolveHost(ctx context.Context, host string, resolver BootstrapResolver) (string, string, time.Duration, error) {
+	var addrs []netip.Addr
+	var err error
+	ttl := defaultBootstrapTTL
+
+	if r, ok := resolver.(ttlResolver); ok {
+		addrs, ttl, err = r.ResolveTTL(ctx, host)
+	} else {
+		addrs, err = resolver.LookupNetIP(ctx, "ip", host)
+	}
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+
+	var ipv4, ipv6 string
+	for _, addr := range addrs {
+		addr = addr.Unmap()
+		if addr.Is4() && ipv4 == "" {
+			ipv4 = addr.String()
+		}
+		if addr.Is6() && ipv6 == "" {
+			ipv6 = addr.String()
+		}
+	}
+	if ipv4 == "" && ipv6 == "" {
+		return "", "", 0, fmt.Errorf("no addresses found for %s", host)
+	}
+
+	return ipv4, ipv6, ttl, nil
+}
+
+// NeedsRefresh prüft, ob die aufgelösten Adressen eines Hostname-Servers abgelaufen sind
+// Server mit festen IP-Adressen brauchen nie einen Refresh
+func (s *Server) NeedsRefresh() bool {
+	if s.resolver == nil {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return time.Now().After(s.expires)
+}
+
+// Refresh löst den Hostnamen erneut über den Bootstrap-Resolver auf
+// Bei Fehlern bleiben die bisherigen Adressen erhalten und der nächste Versuch
+// wird um minBootstrapTTL verschoben. Läuft bereits ein Refresh, kehrt Refresh sofort zurück.
+func (s *Server) Refresh(ctx context.Context) error {
+	if s.resolver == nil {
+		return nil
+	}
+	if !s.refreshMu.TryLock() {
+		return nil
+	}
+	defer s.refreshMu.Unlock()
+
+	ipv4, ipv6, ttl, err := resolveHost(ctx, s.Hostname, s.resolver)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.expires = time.Now().Add(minBootstrapTTL)
+		return err
+	}
+
+	s.IPv4 = ipv4
+	s.IPv6 = ipv6
+	s.expires = time.Now().Add(ttl)
+	return nil
+}
+
+// isIPv4Literal prüft, ob s eine gültige IPv4-Adresse ist
+func isIPv4Literal(s string) bool {
+	addr, err := netip.ParseAddr(s)
+	return err == nil && addr.Is4()
+}
+
+// isIPv6Literal prüft, ob s eine echte IPv6-Adresse ist
+// IPv4-mapped Adressen (::ffff:1.2.3.4) gehören ins IPv4-Feld und werden abgelehnt
+func isIPv6Literal(s string) bool {
+	addr, err := netip.ParseAddr(s)
+	return err == nil && addr.Is6() && !addr.Is4In6()
+}
+
+// isValidHostname prüft einen Hostnamen nach RFC 1123
+// Labels: 1-63 Zeichen aus Buchstaben, Ziffern und Bindestrich, nicht mit Bindestrich
+// beginnend oder endend; Gesamtlänge maximal 253 Zeichen, ein abschließender Punkt ist erlaubt
+func isValidHostname(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+			if !isAlnum && c != '-' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
 // GetName gibt den Namen des Servers zurück
 func (s *Server) GetName() string {
 	return s.Name
 }
 
-// GetIPv4 gibt die IPv4-Adresse zurück
+// GetIPv4 gibt die IPv4-Adresse zurück (kann leer sein)
 func (s *Server) GetIPv4() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	return s.IPv4
 }
 
 // GetIPv6 gibt die IPv6-Adresse zurück (kann leer sein)
 func (s *Server) GetIPv6() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	return s.IPv6
 }
 
+// GetHostname gibt den Hostnamen zurück (leer bei Servern mit festen IP-Adressen)
+func (s *Server) GetHostname() string {
+	return s.Hostname
+}
+
+// SetProtocol legt das Transportprotokoll zum Upstream fest
+func (s *Server) SetProtocol(protocol string) error {
+	switch protocol {
+	case ProtocolUDP, ProtocolTCP, ProtocolTLS:
+		s.Protocol = protocol
+		return nil
+	default:
+		return fmt.Errorf("unsupported protocol: %s", protocol)
+	}
+}
+
+// GetProtocol gibt das Transportprotokoll zurück
+func (s *Server) GetProtocol() string {
+	if s.Protocol == "" {
+		return ProtocolUDP
+	}
+	return s.Protocol
+}
+
+// SetPreference legt die bevorzugte Adressfamilie fest
+func (s *Server) SetPreference(family AddressFamily) {
+	s.Preference = family
+}
+
 // GetAddress gibt die bevorzugte Adresse zurück
-// Nutzt IPv4 wenn vorhanden, sonst IPv6
+// Nutzt standardmäßig IPv4 wenn vorhanden, sonst IPv6
 func (s *Server) GetAddress() string {
-	if s.IPv4 != "" {
-		return fmt.Sprintf("%s:%d", s.IPv4, s.Port)
+	addrs := s.GetAddresses()
+	if len(addrs) == 0 {
+		return ""
+	}
+	return addrs[0]
+}
+
+// GetAddresses gibt alle Adressen des Servers zurück, sortiert nach Präferenz
+// Die erste Adresse wird zuerst versucht, die weiteren dienen als Failover
+func (s *Server) GetAddresses() []string {
+	ipv4, ipv6 := s.GetIPv4(), s.GetIPv6()
+
+	var v4, v6 string
+	if ipv4 != "" {
+		v4 = net.JoinHostPort(ipv4, strconv.Itoa(s.Port))
 	}
-	if s.IPv6 != "" {
-		return fmt.Sprintf("[%s]:%d", s.IPv6, s.Port)
+	if ipv6 != "" {
+		v6 = net.JoinHostPort(ipv6, strconv.Itoa(s.Port))
+	}
+
+	first, second := v4, v6
+	if s.Preference == PreferIPv6 {
+		first, second = v6, v4
+	}
+
+	addrs := make([]string, 0, 2)
+	for _, addr := range []string{first, second} {
+		if addr != "" {
+			addrs = append(addrs, addr)
+		}
 	}
-	return ""
+	return addrs
 }
@@ -1,6 +1,11 @@
 package dns
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"testing"
+)
 
 func TestNewServer(t *testing.T) {
 	tests := []struct {
@@ -43,6 +48,78 @@ func TestNewServer(t *testing.T) {
 			port:      53,
 			wantError: true,
 		},
+		{
+			name:      "Valid server with IPv6 only",
+			servName:  "Quad9 v6",
+			ipv4:      "",
+			ipv6:      "2620:fe::fe",
+			port:      53,
+			wantError: false,
+		},
+		{
+			name:      "Valid IPv6 with zone",
+			servName:  "Link-Local",
+			ipv4:      "",
+			ipv6:      "fe80::1%eth0",
+			port:      53,
+			wantError: false,
+		},
+		{
+			name:      "Invalid IPv4 - out of range",
+			servName:  "Test",
+			ipv4:      "256.1.1.1",
+			ipv6:      "",
+			port:      53,
+			wantError: true,
+		},
+		{
+			name:      "Invalid IPv4 - hostname",
+			servName:  "Test",
+			ipv4:      "dns.quad9.net",
+			ipv6:      "",
+			port:      53,
+			wantError: true,
+		},
+		{
+			name:      "Invalid IPv4 - IPv6 in IPv4 field",
+			servName:  "Test",
+			ipv4:      "2001:4860:4860::8888",
+			ipv6:      "",
+			port:      53,
+			wantError: true,
+		},
+		{
+			name:      "Invalid IPv6 - IPv4 in IPv6 field",
+			servName:  "Test",
+			ipv4:      "",
+			ipv6:      "8.8.8.8",
+			port:      53,
+			wantError: true,
+		},
+		{
+			name:      "Invalid IPv6 - IPv4-mapped",
+			servName:  "Test",
+			ipv4:      "",
+			ipv6:      "::ffff:8.8.8.8",
+			port:      53,
+			wantError: true,
+		},
+		{
+			name:      "Invalid IPv6 - garbage",
+			servName:  "Test",
+			ipv4:      "1.1.1.1",
+			ipv6:      "2001:::1",
+			port:      53,
+			wantError: true,
+		},
+		{
+			name:      "Invalid IPv4 - with port",
+			servName:  "Test",
+			ipv4:      "1.1.1.1:53",
+			ipv6:      "",
+			port:      53,
+			wantError: true,
+		},
 		{
 			name:      "Invalid port - zero",
 			servName:  "Test",
@@ -150,6 +227,14 @@ func TestServer_GetAddress(t *testing.T) {
 			port:     5353,
 			want:     "8.8.8.8:5353",
 		},
+		{
+			name:     "IPv6 only",
+			servName: "Quad9",
+			ipv4:     "",
+			ipv6:     "2620:fe::fe",
+			port:     53,
+			want:     "[2620:fe::fe]:53",
+		},
 		{
 			name:     "IPv4 and IPv6 - prefers IPv4",
 			servName: "Google",
@@ -173,6 +258,203 @@ func TestServer_GetAddress(t *testing.T) {
 	}
 }
 
+func TestServer_GetAddresses(t *testing.T) {
+	tests := []struct {
+		name       string
+		ipv4       string
+		ipv6       string
+		preference AddressFamily
+		want       []string
+	}{
+		{
+			name:       "IPv4 only",
+			ipv4:       "1.1.1.1",
+			preference: PreferIPv4,
+			want:       []string{"1.1.1.1:53"},
+		},
+		{
+			name:       "IPv4 only - IPv6 preference has no effect",
+			ipv4:       "1.1.1.1",
+			preference: PreferIPv6,
+			want:       []string{"1.1.1.1:53"},
+		},
+		{
+			name:       "Both families - prefer IPv4",
+			ipv4:       "1.1.1.1",
+			ipv6:       "2606:4700:4700::1111",
+			preference: PreferIPv4,
+			want:       []string{"1.1.1.1:53", "[2606:4700:4700::1111]:53"},
+		},
+		{
+			name:       "Both families - prefer IPv6",
+			ipv4:       "1.1.1.1",
+			ipv6:       "2606:4700:4700::1111",
+			preference: PreferIPv6,
+			want:       []string{"[2606:4700:4700::1111]:53", "1.1.1.1:53"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := NewServer("Test", tt.ipv4, tt.ipv6, 53)
+			if err != nil {
+				t.Fatalf("Failed to create server: %v", err)
+			}
+			server.SetPreference(tt.preference)
+
+			got := server.GetAddresses()
+			if len(got) != len(tt.want) {
+				t.Fatalf("GetAddresses() = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("GetAddresses()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+			if server.GetAddress() != tt.want[0] {
+				t.Errorf("GetAddress() = %v, want %v", server.GetAddress(), tt.want[0])
+			}
+		})
+	}
+}
+
 func TestServer_ImplementsDNSServerInterface(t *testing.T) {
 	var _ DNSServer = (*Server)(nil)
 }
+
+// fakeResolver ist ein BootstrapResolver mit festen Antworten
+type fakeResolver map[string][]string
+
+func (f fakeResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	ips, ok := f[host]
+	if !ok {
+		return nil, fmt.Errorf("no such host: %s", host)
+	}
+	addrs := make([]netip.Addr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, netip.MustParseAddr(ip))
+	}
+	return addrs, nil
+}
+
+func TestNewServerFromHost(t *testing.T) {
+	resolver := fakeResolver{
+		"dns.quad9.net":      {"9.9.9.9", "149.112.112.112", "2620:fe::fe"},
+		"v6only.example.net": {"2001:db8::53"},
+		"mapped.example.net": {"::ffff:192.0.2.53"},
+		"empty.example.net":  {},
+	}
+
+	tests := []struct {
+		name      string
+		host      string
+		resolver  BootstrapResolver
+		wantIPv4  string
+		wantIPv6  string
+		wantError bool
+	}{
+		{
+			name:     "Both families - first address per family",
+			host:     "dns.quad9.net",
+			resolver: resolver,
+			wantIPv4: "9.9.9.9",
+			wantIPv6: "2620:fe::fe",
+		},
+		{
+			name:     "IPv6 only",
+			host:     "v6only.example.net",
+			resolver: resolver,
+			wantIPv6: "2001:db8::53",
+		},
+		{
+			name:     "IPv4-mapped answer lands in IPv4",
+			host:     "mapped.example.net",
+			resolver: resolver,
+			wantIPv4: "192.0.2.53",
+		},
+		{
+			name:      "No addresses",
+			host:      "empty.example.net",
+			resolver:  resolver,
+			wantError: true,
+		},
+		{
+			name:      "Unresolvable host",
+			host:      "unknown.example.net",
+			resolver:  resolver,
+			wantError: true,
+		},
+		{
+			name:      "Invalid hostname",
+			host:      "bad_host!.example.net",
+			resolver:  resolver,
+			wantError: true,
+		},
+		{
+			name:      "Empty hostname",
+			host:      "",
+			resolver:  resolver,
+			wantError: true,
+		},
+		{
+			name:      "Nil resolver",
+			host:      "dns.quad9.net",
+			resolver:  nil,
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := NewServerFromHost(context.Background(), "Test", tt.host, 53, tt.resolver)
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("NewServerFromHost() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewServerFromHost() unexpected error: %v", err)
+			}
+			if server.Hostname != tt.host {
+				t.Errorf("Hostname = %v, want %v", server.Hostname, tt.host)
+			}
+			if server.IPv4 != tt.wantIPv4 {
+				t.Errorf("IPv4 = %v, want %v", server.IPv4, tt.wantIPv4)
+			}
+			if server.IPv6 != tt.wantIPv6 {
+				t.Errorf("IPv6 = %v, want %v", server.IPv6, tt.wantIPv6)
+			}
+		})
+	}
+}
+
+func TestIsValidHostname(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"example.com", true},
+		{"dns.quad9.net", true},
+		{"cloudflare-dns.com", true},
+		{"example.com.", true},
+		{"localhost", true},
+		{"123.example.com", true},
+		{"", false},
+		{".", false},
+		{"-bad.example.com", false},
+		{"bad-.example.com", false},
+		{"bad..example.com", false},
+		{"under_score.example.com", false},
+		{"space here.com", false},
+		{fmt.Sprintf("%064d.com", 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := isValidHostname(tt.host); got != tt.want {
+				t.Errorf("isValidHostname(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,495 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	mdns "github.com/miekg/dns"
+)
+
+// Unterstützte Upstream-Protokolle
+const (
+	ProtocolUDP = "udp"
+	ProtocolTCP = "tcp"
+	ProtocolTLS = "tls" // DNS-over-TLS (RFC 7858), üblicherweise Port 853
+)
+
+const (
+	// defaultIdleTimeout schließt ungenutzte Verbindungen nach dieser Zeit
+	defaultIdleTimeout = 30 * time.Second
+	// maxIdleUDPConns begrenzt die Anzahl wartender UDP-Sockets pro Upstream
+	maxIdleUDPConns = 8
+	// ednsUDPSize ist die beworbene EDNS0-Puffergröße (DNS Flag Day 2020)
+	ednsUDPSize = 1232
+)
+
+// errTransportClosed wird zurückgegeben, wenn der Transport bereits geschlossen wurde
+var errTransportClosed = errors.New("transport closed")
+
+// Transport hält die Verbindungen zu einer einzelnen Upstream-Adresse
+// UDP-Sockets werden gepoolt, TCP/TLS nutzt eine gemeinsame Verbindung mit Pipelining.
+// Antworten werden über die Message-ID zugeordnet und dürfen in beliebiger Reihenfolge eintreffen.
+type Transport struct {
+	protocol    string
+	addr        string
+	serverName  string // TLS-Servername für die Zertifikatsprüfung
+	idleTimeout time.Duration
+	dialSlot    chan struct{} // nur ein TCP/TLS-Verbindungsaufbau gleichzeitig, außerhalb von mu
+
+	mu       sync.Mutex
+	udpIdle  []idleConn
+	udpTimer *time.Timer
+	stream   *streamConn
+	closed   bool
+}
+
+// idleConn ist ein ungenutzter UDP-Socket im Pool
+type idleConn struct {
+	conn  *mdns.Conn
+	since time.Time
+}
+
+// NewTransport erstellt einen Transport für eine Upstream-Adresse (host:port)
+// serverName wird nur für TLS genutzt; leer bedeutet: Host-Teil der Adresse
+func NewTransport(protocol, addr, serverName string) (*Transport, error) {
+	switch protocol {
+	case ProtocolUDP, ProtocolTCP, ProtocolTLS:
+	default:
+		return nil, fmt.Errorf("unsupported protocol: %s", protocol)
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream address %s: %w", addr, err)
+	}
+	if serverName == "" {
+		serverName = host
+	}
+
+	return &Transport{
+		protocol:    protocol,
+		addr:        addr,
+		serverName:  serverName,
+		idleTimeout: defaultIdleTimeout,
+		dialSlot:    make(chan struct{}, 1),
+	}, nil
+}
+
+// SetIdleTimeout setzt die Zeit, nach der ungenutzte Verbindungen geschlossen werden
+func (t *Transport) SetIdleTimeout(timeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.idleTimeout = timeout
+}
+
+// GetAddress gibt die Upstream-Adresse zurück
+func (t *Transport) GetAddress() string {
+	return t.addr
+}
+
+// Exchange sendet eine Anfrage und wartet auf die passende Antwort
+// Abgeschnittene UDP-Antworten werden automatisch über TCP wiederholt
+func (t *Transport) Exchange(ctx context.Context, m *mdns.Msg) (*mdns.Msg, error) {
+	if t.protocol == ProtocolUDP {
+		resp, err := t.exchangeUDP(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		if !resp.Truncated {
+			return resp, nil
+		}
+		// Antwort passt nicht in ein UDP-Paket - über TCP wiederholen
+	}
+
+	stream, fresh, err := t.getStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := stream.exchange(ctx, m)
+	if err == nil || fresh || ctx.Err() != nil || errors.Is(err, errTransportClosed) {
+		return resp, err
+	}
+
+	// Der Upstream darf eine ungenutzte Verbindung jederzeit schließen (RFC 7766),
+	// daher einmal über eine neue Verbindung wiederholen
+	stream, _, err = t.getStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stream.exchange(ctx, m)
+}
+
+// Close schließt alle Verbindungen des Transports
+func (t *Transport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	for _, idle := range t.udpIdle {
+		idle.conn.Close()
+	}
+	t.udpIdle = nil
+	if t.udpTimer != nil {
+		t.udpTimer.Stop()
+		t.udpTimer = nil
+	}
+	if t.stream != nil {
+		t.stream.fail(errTransportClosed)
+		t.stream = nil
+	}
+
+	return nil
+}
+
+// exchangeUDP führt eine Abfrage über einen gepoolten UDP-Socket aus
+func (t *Transport) exchangeUDP(ctx context.Context, m *mdns.Msg) (*mdns.Msg, error) {
+	conn, err := t.getUDPConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	// Abbruch des Contexts beendet ein blockierendes Read sofort
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+
+	resp, err := roundTripUDP(conn, m)
+	if !stop() || err != nil {
+		// Socket ist in unklarem Zustand - nicht in den Pool zurücklegen
+		conn.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+
+	t.putUDPConn(conn)
+	return resp, nil
+}
+
+// roundTripUDP sendet m und liest, bis die Antwort mit passender ID eintrifft
+// Verspätete Antworten früherer Abfragen auf demselben Socket werden verworfen
+func roundTripUDP(conn *mdns.Conn, m *mdns.Msg) (*mdns.Msg, error) {
+	if err := conn.WriteMsg(m); err != nil {
+		return nil, err
+	}
+
+	for {
+		resp, err := conn.ReadMsg()
+		if err != nil {
+			return nil, err
+		}
+		if resp.Id == m.Id && sameQuestion(resp, m) {
+			return resp, nil
+		}
+	}
+}
+
+// sameQuestion prüft, ob die Antwort zur gestellten Frage gehört
+func sameQuestion(resp, req *mdns.Msg) bool {
+	if len(resp.Question) != len(req.Question) {
+		return false
+	}
+	for i := range req.Question {
+		a, b := resp.Question[i], req.Question[i]
+		if a.Qtype != b.Qtype || a.Qclass != b.Qclass || mdns.CanonicalName(a.Name) != mdns.CanonicalName(b.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// getUDPConn holt einen Socket aus dem Pool oder öffnet einen neuen
+func (t *Transport) getUDPConn(ctx context.Context) (*mdns.Conn, error) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, errTransportClosed
+	}
+	if n := len(t.udpIdle); n > 0 {
+		// Zuletzt genutzten Socket zuerst, ältere laufen über den Idle-Timeout aus
+		idle := t.udpIdle[n-1]
+		t.udpIdle = t.udpIdle[:n-1]
+		t.mu.Unlock()
+		return idle.conn, nil
+	}
+	t.mu.Unlock()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", t.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mdns.Conn{Conn: conn, UDPSize: ednsUDPSize}, nil
+}
+
+// putUDPConn legt einen Socket zurück in den Pool
+func (t *Transport) putUDPConn(conn *mdns.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed || len(t.udpIdle) >= maxIdleUDPConns {
+		conn.Close()
+		return
+	}
+
+	t.udpIdle = append(t.udpIdle, idleConn{conn: conn, since: time.Now()})
+	if t.udpTimer == nil {
+		t.udpTimer = time.AfterFunc(t.idleTimeout, t.closeIdleUDP)
+	}
+}
+
+// closeIdleUDP schließt Sockets, die länger als idleTimeout ungenutzt sind
+func (t *Transport) closeIdleUDP() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := time.Now().Add(-t.idleTimeout)
+	kept := t.udpIdle[:0]
+	for _, idle := range t.udpIdle {
+		if idle.since.Before(cutoff) {
+			idle.conn.Close()
+			continue
+		}
+		kept = append(kept, idle)
+	}
+	t.udpIdle = kept
+
+	if len(t.udpIdle) > 0 && !t.closed {
+		t.udpTimer = time.AfterFunc(t.idleTimeout, t.closeIdleUDP)
+	} else {
+		t.udpTimer = nil
+	}
+}
+
+// getStream gibt die bestehende TCP/TLS-Verbindung zurück oder baut eine neue auf
+// fresh ist true, wenn die Verbindung für diesen Aufruf aufgebaut wurde
+// Der Verbindungsaufbau läuft ohne mu, damit ein hängender TLS-Handshake UDP-Anfragen
+// und Close nicht blockiert
+func (t *Transport) getStream(ctx context.Context) (stream *streamConn, fresh bool, err error) {
+	if stream, err := t.currentStream(); stream != nil || err != nil {
+		return stream, false, err
+	}
+
+	select {
+	case t.dialSlot <- struct{}{}:
+		defer func() { <-t.dialSlot }()
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+
+	// Ein anderer Aufrufer kann die Verbindung inzwischen aufgebaut haben
+	if stream, err := t.currentStream(); stream != nil || err != nil {
+		return stream, false, err
+	}
+
+	conn, err := t.dialStream(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		conn.Close()
+		return nil, false, errTransportClosed
+	}
+	t.stream = newStreamConn(conn, t.idleTimeout)
+	return t.stream, true, nil
+}
+
+// currentStream gibt die offene TCP/TLS-Verbindung zurück, nil wenn keine besteht
+func (t *Transport) currentStream() (*streamConn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil, errTransportClosed
+	}
+	if t.stream != nil && !t.stream.isClosed() {
+		return t.stream, nil
+	}
+	return nil, nil
+}
+
+// dialStream baut eine TCP- oder TLS-Verbindung zum Upstream auf
+func (t *Transport) dialStream(ctx context.Context) (*mdns.Conn, error) {
+	d := &net.Dialer{}
+
+	var conn net.Conn
+	var err error
+	if t.protocol == ProtocolTLS {
+		tlsDialer := &tls.Dialer{
+			NetDialer: d,
+			Config: &tls.Config{
+				ServerName: t.serverName,
+				MinVersion: tls.VersionTLS12,
+			},
+		}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", t.addr)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", t.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &mdns.Conn{Conn: conn}, nil
+}
+
+// streamConn ist eine TCP/TLS-Verbindung mit mehreren gleichzeitigen Anfragen (Pipelining)
+// Eine Lese-Goroutine verteilt die Antworten anhand der Message-ID an die Wartenden
+type streamConn struct {
+	conn        *mdns.Conn
+	idleTimeout time.Duration
+	writeMu     sync.Mutex
+
+	mu       sync.Mutex
+	pending  map[uint16]chan *mdns.Msg
+	inflight int
+	idle     *time.Timer
+	done     chan struct{}
+	err      error
+}
+
+// newStreamConn startet die Lese-Goroutine für eine Verbindung
+func newStreamConn(conn *mdns.Conn, idleTimeout time.Duration) *streamConn {
+	s := &streamConn{
+		conn:        conn,
+		idleTimeout: idleTimeout,
+		pending:     make(map[uint16]chan *mdns.Msg),
+		done:        make(chan struct{}),
+	}
+	s.idle = time.AfterFunc(idleTimeout, s.closeIfIdle)
+
+	go s.readLoop()
+
+	return s
+}
+
+// exchange sendet eine Anfrage über die gemeinsame Verbindung
+// Ist die ID bereits vergeben, wird für die Leitung eine freie ID gewählt
+func (s *streamConn) exchange(ctx context.Context, m *mdns.Msg) (*mdns.Msg, error) {
+	req := m.Copy()
+	ch := make(chan *mdns.Msg, 1)
+
+	s.mu.Lock()
+	if s.err != nil {
+		err := s.err
+		s.mu.Unlock()
+		return nil, err
+	}
+	for {
+		if _, busy := s.pending[req.Id]; !busy {
+			break
+		}
+		req.Id = mdns.Id()
+	}
+	s.pending[req.Id] = ch
+	s.inflight++
+	s.idle.Stop()
+	s.mu.Unlock()
+
+	defer s.release(req.Id)
+
+	s.writeMu.Lock()
+	deadline, _ := ctx.Deadline()
+	s.conn.SetWriteDeadline(deadline)
+	err := s.conn.WriteMsg(req)
+	s.writeMu.Unlock()
+	if err != nil {
+		s.fail(err)
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		// Ursprüngliche ID des Aufrufers wiederherstellen
+		resp.Id = m.Id
+		return resp, nil
+	case <-s.done:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return nil, s.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// release entfernt eine abgeschlossene Anfrage und startet bei Leerlauf den Idle-Timer
+func (s *streamConn) release(id uint16) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pending, id)
+	s.inflight--
+	if s.inflight == 0 && s.err == nil {
+		s.idle.Reset(s.idleTimeout)
+	}
+}
+
+// readLoop liest Antworten und ordnet sie über die Message-ID zu
+func (s *streamConn) readLoop() {
+	for {
+		resp, err := s.conn.ReadMsg()
+		if err != nil {
+			s.fail(err)
+			return
+		}
+
+		s.mu.Lock()
+		ch, ok := s.pending[resp.Id]
+		s.mu.Unlock()
+
+		// Antworten ohne wartende Anfrage (z.B. nach Timeout) werden verworfen
+		if ok {
+			select {
+			case ch <- resp:
+			default:
+			}
+		}
+	}
+}
+
+// closeIfIdle schließt die Verbindung, wenn keine Anfrage mehr läuft
+func (s *streamConn) closeIfIdle() {
+	s.mu.Lock()
+	idle := s.inflight == 0
+	s.mu.Unlock()
+
+	if idle {
+		s.fail(fmt.Errorf("connection closed after idle timeout"))
+	}
+}
+
+// fail schließt die Verbindung und weckt alle wartenden Anfragen mit err
+func (s *streamConn) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return
+	}
+	s.err = err
+	s.idle.Stop()
+	close(s.done)
+	s.conn.Close()
+}
+
+// isClosed prüft, ob die Verbindung bereits beendet wurde
+func (s *streamConn) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err != nil
+}
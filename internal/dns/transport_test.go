@@ -0,0 +1,521 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mdns "github.com/miekg/dns"
+)
+
+// answerFor erstellt eine Antwort mit einem A-Record für die Frage in r
+func answerFor(r *mdns.Msg, ip string) *mdns.Msg {
+	msg := new(mdns.Msg)
+	msg.SetReply(r)
+	msg.Answer = append(msg.Answer, &mdns.A{
+		Hdr: mdns.RR_Header{Name: r.Question[0].Name, Rrtype: mdns.TypeA, Class: mdns.ClassINET, Ttl: 60},
+		A:   net.ParseIP(ip).To4(),
+	})
+	return msg
+}
+
+// newQuery erstellt eine A-Anfrage für domain
+func newQuery(domain string) *mdns.Msg {
+	msg := new(mdns.Msg)
+	msg.SetQuestion(mdns.Fqdn(domain), mdns.TypeA)
+	return msg
+}
+
+// startStreamUpstream startet einen TCP-Upstream, der pro Verbindung handle aufruft
+// Gibt die Adresse und einen Zähler der angenommenen Verbindungen zurück
+func startStreamUpstream(t *testing.T, addr string, handle func(conn *mdns.Conn)) (string, *atomic.Int32) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Listen(%s) failed: %v", addr, err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			go func() {
+				defer conn.Close()
+				handle(&mdns.Conn{Conn: conn})
+			}()
+		}
+	}()
+
+	return ln.Addr().String(), &accepted
+}
+
+// echoStream beantwortet jede Anfrage einer Verbindung sofort mit 10.0.0.1
+func echoStream(conn *mdns.Conn) {
+	for {
+		req, err := conn.ReadMsg()
+		if err != nil {
+			return
+		}
+		conn.WriteMsg(answerFor(req, "10.0.0.1"))
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	tests := []struct {
+		name           string
+		protocol       string
+		addr           string
+		serverName     string
+		wantServerName string
+		wantError      bool
+	}{
+		{name: "UDP", protocol: ProtocolUDP, addr: "1.1.1.1:53", wantServerName: "1.1.1.1"},
+		{name: "TLS with server name", protocol: ProtocolTLS, addr: "9.9.9.9:853", serverName: "dns.quad9.net", wantServerName: "dns.quad9.net"},
+		{name: "TCP IPv6", protocol: ProtocolTCP, addr: "[2620:fe::fe]:53", wantServerName: "2620:fe::fe"},
+		{name: "Unknown protocol", protocol: "quic", addr: "1.1.1.1:853", wantError: true},
+		{name: "Missing port", protocol: ProtocolUDP, addr: "1.1.1.1", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, err := NewTransport(tt.protocol, tt.addr, tt.serverName)
+			if tt.wantError {
+				if err == nil {
+					t.Error("NewTransport() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewTransport() unexpected error: %v", err)
+			}
+			if transport.serverName != tt.wantServerName {
+				t.Errorf("serverName = %v, want %v", transport.serverName, tt.wantServerName)
+			}
+			if transport.GetAddress() != tt.addr {
+				t.Errorf("GetAddress() = %v, want %v", transport.GetAddress(), tt.addr)
+			}
+		})
+	}
+}
+
+func TestTransport_UDPReusesSocket(t *testing.T) {
+	var mu sync.Mutex
+	sources := make(map[string]bool)
+
+	port := startTestUpstreamHandler(t, "127.0.0.1:0", func(w mdns.ResponseWriter, r *mdns.Msg) {
+		mu.Lock()
+		sources[w.RemoteAddr().String()] = true
+		mu.Unlock()
+		w.WriteMsg(answerFor(r, "10.0.0.1"))
+	})
+
+	transport, _ := NewTransport(ProtocolUDP, fmt.Sprintf("127.0.0.1:%d", port), "")
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	for i := 0; i < 5; i++ {
+		if _, err := transport.Exchange(ctx, newQuery("example.com")); err != nil {
+			t.Fatalf("Exchange() %d failed: %v", i, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sources) != 1 {
+		t.Errorf("Sequential queries used %d sockets, want 1 (reused)", len(sources))
+	}
+}
+
+func TestTransport_UDPDiscardsMismatchedID(t *testing.T) {
+	port := startTestUpstreamHandler(t, "127.0.0.1:0", func(w mdns.ResponseWriter, r *mdns.Msg) {
+		// Erst eine verspätete Antwort mit fremder ID, dann die richtige
+		stale := answerFor(r, "192.0.2.1")
+		stale.Id = r.Id + 1
+		w.WriteMsg(stale)
+		w.WriteMsg(answerFor(r, "10.0.0.1"))
+	})
+
+	transport, _ := NewTransport(ProtocolUDP, fmt.Sprintf("127.0.0.1:%d", port), "")
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := transport.Exchange(ctx, newQuery("example.com"))
+	if err != nil {
+		t.Fatalf("Exchange() failed: %v", err)
+	}
+	if a := resp.Answer[0].(*mdns.A); a.A.String() != "10.0.0.1" {
+		t.Errorf("Exchange() returned %v, want answer with matching ID", a.A)
+	}
+}
+
+func TestTransport_UDPCancel(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() failed: %v", err)
+	}
+	defer conn.Close()
+
+	transport, _ := NewTransport(ProtocolUDP, conn.LocalAddr().String(), "")
+	defer transport.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err = transport.Exchange(ctx, newQuery("example.com"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Exchange() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Exchange() took %v after cancel", elapsed)
+	}
+}
+
+func TestTransport_TruncatedFallsBackToTCP(t *testing.T) {
+	port := startTestUpstreamHandler(t, "127.0.0.1:0", func(w mdns.ResponseWriter, r *mdns.Msg) {
+		msg := new(mdns.Msg)
+		msg.SetReply(r)
+		msg.Truncated = true
+		w.WriteMsg(msg)
+	})
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	_, accepted := startStreamUpstream(t, addr, echoStream)
+
+	transport, _ := NewTransport(ProtocolUDP, addr, "")
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := transport.Exchange(ctx, newQuery("example.com"))
+	if err != nil {
+		t.Fatalf("Exchange() failed: %v", err)
+	}
+	if resp.Truncated || len(resp.Answer) != 1 {
+		t.Errorf("Exchange() should return the full TCP answer, got %v", resp)
+	}
+	if accepted.Load() != 1 {
+		t.Errorf("TCP connections = %d, want 1", accepted.Load())
+	}
+}
+
+func TestTransport_TCPPipeliningOutOfOrder(t *testing.T) {
+	// Upstream liest zwei Anfragen und antwortet in umgekehrter Reihenfolge
+	addr, accepted := startStreamUpstream(t, "127.0.0.1:0", func(conn *mdns.Conn) {
+		first, err := conn.ReadMsg()
+		if err != nil {
+			return
+		}
+		second, err := conn.ReadMsg()
+		if err != nil {
+			return
+		}
+		conn.WriteMsg(answerFor(second, "10.0.0.2"))
+		conn.WriteMsg(answerFor(first, "10.0.0.1"))
+		echoStream(conn)
+	})
+
+	transport, _ := NewTransport(ProtocolTCP, addr, "")
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	domains := []string{"one.example.com.", "two.example.com."}
+	var wg sync.WaitGroup
+	for _, domain := range domains {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			query := newQuery(domain)
+			resp, err := transport.Exchange(ctx, query)
+			if err != nil {
+				t.Errorf("Exchange(%s) failed: %v", domain, err)
+				return
+			}
+			if resp.Id != query.Id || resp.Question[0].Name != domain {
+				t.Errorf("Exchange(%s) got response for %s (id %d, want %d)", domain, resp.Question[0].Name, resp.Id, query.Id)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted.Load() != 1 {
+		t.Errorf("Pipelined queries used %d connections, want 1", accepted.Load())
+	}
+}
+
+func TestTransport_TCPDuplicateIDs(t *testing.T) {
+	addr, _ := startStreamUpstream(t, "127.0.0.1:0", func(conn *mdns.Conn) {
+		first, err := conn.ReadMsg()
+		if err != nil {
+			return
+		}
+		second, err := conn.ReadMsg()
+		if err != nil {
+			return
+		}
+		if first.Id == second.Id {
+			// Doppelte IDs auf derselben Leitung wären nicht zuzuordnen
+			return
+		}
+		conn.WriteMsg(answerFor(second, "10.0.0.2"))
+		conn.WriteMsg(answerFor(first, "10.0.0.1"))
+	})
+
+	transport, _ := NewTransport(ProtocolTCP, addr, "")
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, domain := range []string{"one.example.com.", "two.example.com."} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			query := newQuery(domain)
+			query.Id = 4242
+			resp, err := transport.Exchange(ctx, query)
+			if err != nil {
+				t.Errorf("Exchange(%s) failed: %v", domain, err)
+				return
+			}
+			if resp.Id != 4242 || resp.Question[0].Name != domain {
+				t.Errorf("Exchange(%s) got response for %s (id %d)", domain, resp.Question[0].Name, resp.Id)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTransport_IdleTimeout(t *testing.T) {
+	addr, accepted := startStreamUpstream(t, "127.0.0.1:0", echoStream)
+
+	transport, _ := NewTransport(ProtocolTCP, addr, "")
+	transport.SetIdleTimeout(50 * time.Millisecond)
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if _, err := transport.Exchange(ctx, newQuery("example.com")); err != nil {
+		t.Fatalf("Exchange() failed: %v", err)
+	}
+	if _, err := transport.Exchange(ctx, newQuery("example.com")); err != nil {
+		t.Fatalf("Second Exchange() failed: %v", err)
+	}
+	if accepted.Load() != 1 {
+		t.Fatalf("Back-to-back queries used %d connections, want 1", accepted.Load())
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	transport.mu.Lock()
+	closed := transport.stream.isClosed()
+	transport.mu.Unlock()
+	if !closed {
+		t.Error("Idle connection should be closed after the idle timeout")
+	}
+
+	if _, err := transport.Exchange(ctx, newQuery("example.com")); err != nil {
+		t.Fatalf("Exchange() after idle close failed: %v", err)
+	}
+	if accepted.Load() != 2 {
+		t.Errorf("Connections after idle close = %d, want 2", accepted.Load())
+	}
+}
+
+func TestTransport_RetriesClosedStream(t *testing.T) {
+	// Der Upstream beantwortet pro Verbindung nur die erste Anfrage und schließt die
+	// Verbindung bei der nächsten, wie ein Server, der eine ungenutzte Verbindung beendet
+	addr, accepted := startStreamUpstream(t, "127.0.0.1:0", func(conn *mdns.Conn) {
+		req, err := conn.ReadMsg()
+		if err != nil {
+			return
+		}
+		conn.WriteMsg(answerFor(req, "10.0.0.1"))
+		conn.ReadMsg()
+	})
+
+	transport, _ := NewTransport(ProtocolTCP, addr, "")
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if _, err := transport.Exchange(ctx, newQuery("example.com")); err != nil {
+		t.Fatalf("Exchange() failed: %v", err)
+	}
+	if _, err := transport.Exchange(ctx, newQuery("example.com")); err != nil {
+		t.Fatalf("Exchange() on closed stream should be retried, got error: %v", err)
+	}
+	if accepted.Load() != 2 {
+		t.Errorf("Connections = %d, want 2 (one retry)", accepted.Load())
+	}
+}
+
+func TestTransport_DialDoesNotBlock(t *testing.T) {
+	// Der Upstream nimmt Verbindungen an, antwortet aber nie auf den TLS-Handshake
+	addr, _ := startStreamUpstream(t, "127.0.0.1:0", func(conn *mdns.Conn) {
+		time.Sleep(time.Second)
+	})
+
+	transport, _ := NewTransport(ProtocolTLS, addr, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := transport.Exchange(ctx, newQuery("example.com"))
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Close darf nicht auf den hängenden Handshake warten
+	closed := make(chan struct{})
+	go func() {
+		transport.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Close() blocked by a pending TLS handshake")
+	}
+
+	if err := <-done; err == nil {
+		t.Error("Exchange() with hanging handshake should fail")
+	}
+	if _, err := transport.Exchange(context.Background(), newQuery("example.com")); !errors.Is(err, errTransportClosed) {
+		t.Errorf("Exchange() after Close() error = %v, want errTransportClosed", err)
+	}
+}
+
+func TestTransport_Close(t *testing.T) {
+	addr, _ := startStreamUpstream(t, "127.0.0.1:0", echoStream)
+
+	transport, _ := NewTransport(ProtocolTCP, addr, "")
+	transport.Close()
+
+	_, err := transport.Exchange(context.Background(), newQuery("example.com"))
+	if !errors.Is(err, errTransportClosed) {
+		t.Errorf("Exchange() after Close() error = %v, want errTransportClosed", err)
+	}
+}
+
+func TestProxy_ReusesTransport(t *testing.T) {
+	port := startTestUpstream(t, "127.0.0.1:0", "10.0.0.1")
+
+	registry := NewRegistry()
+	proxy := NewProxy(registry, NewBlacklist())
+	defer proxy.Close()
+
+	server, _ := NewServer("Local", "127.0.0.1", "", port)
+	registry.AddServer(server)
+
+	for i := 0; i < 3; i++ {
+		if _, err := proxy.Lookup("example.com"); err != nil {
+			t.Fatalf("Lookup() %d failed: %v", i, err)
+		}
+	}
+
+	proxy.transportMu.Lock()
+	count := len(proxy.transports)
+	proxy.transportMu.Unlock()
+	if count != 1 {
+		t.Errorf("Proxy created %d transports, want 1 per upstream address", count)
+	}
+
+	proxy.Close()
+	proxy.transportMu.Lock()
+	count = len(proxy.transports)
+	proxy.transportMu.Unlock()
+	if count != 0 {
+		t.Errorf("Close() should drop all transports, %d left", count)
+	}
+}
+
+func TestProxy_ClosesUnusedTransports(t *testing.T) {
+	oldPort := startTestUpstream(t, "127.0.0.1:0", "10.0.0.1")
+	newPort := startTestUpstream(t, "127.0.0.1:0", "10.0.0.2")
+
+	registry := NewRegistry()
+	proxy := NewProxy(registry, NewBlacklist())
+	defer proxy.Close()
+
+	now := time.Now()
+	proxy.now = func() time.Time { return now }
+
+	server, _ := NewServer("Local", "127.0.0.1", "", oldPort)
+	registry.AddServer(server)
+	if _, err := proxy.Lookup("example.com"); err != nil {
+		t.Fatalf("Lookup() failed: %v", err)
+	}
+	proxy.transportMu.Lock()
+	var old *Transport
+	for _, entry := range proxy.transports {
+		old = entry.transport
+	}
+	proxy.transportMu.Unlock()
+
+	// Adresswechsel wie nach einem Refresh: der alte Transport wird nicht mehr gefragt
+	server.mu.Lock()
+	server.Port = newPort
+	server.mu.Unlock()
+	now = now.Add(unusedTransportTimeout + time.Minute)
+	if _, err := proxy.Lookup("example.com"); err != nil {
+		t.Fatalf("Lookup() after address change failed: %v", err)
+	}
+
+	proxy.transportMu.Lock()
+	count := len(proxy.transports)
+	proxy.transportMu.Unlock()
+	if count != 1 {
+		t.Errorf("Proxy has %d transports, want only the one in use", count)
+	}
+	if _, err := old.Exchange(context.Background(), new(mdns.Msg)); !errors.Is(err, errTransportClosed) {
+		t.Errorf("Exchange() on unused transport error = %v, want errTransportClosed", err)
+	}
+}
+
+func TestProxy_LookupOverTCP(t *testing.T) {
+	addr, _ := startStreamUpstream(t, "127.0.0.1:0", echoStream)
+	_, portStr, _ := net.SplitHostPort(addr)
+
+	registry := NewRegistry()
+	proxy := NewProxy(registry, NewBlacklist())
+	defer proxy.Close()
+
+	port, _ := strconv.Atoi(portStr)
+	server, _ := NewServer("TCP", "127.0.0.1", "", port)
+	if err := server.SetProtocol(ProtocolTCP); err != nil {
+		t.Fatalf("SetProtocol() failed: %v", err)
+	}
+	registry.AddServer(server)
+
+	ips, err := proxy.Lookup("example.com")
+	if err != nil {
+		t.Fatalf("Lookup() over TCP failed: %v", err)
+	}
+	if len(ips) != 1 || ips[0] != "10.0.0.1" {
+		t.Errorf("Lookup() = %v, want [10.0.0.1]", ips)
+	}
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// AccessAction legt fest, wie Anfragen nicht erlaubter Clients behandelt werden
+type AccessAction int
+
+const (
+	// AccessRefuse beantwortet die Anfrage mit REFUSED
+	AccessRefuse AccessAction = iota
+	// AccessDrop verwirft die Anfrage ohne Antwort
+	AccessDrop
+)
+
+// String gibt den Namen der Aktion zurück
+func (a AccessAction) String() string {
+	switch a {
+	case AccessRefuse:
+		return "refuse"
+	case AccessDrop:
+		return "drop"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseAccessAction wandelt einen Namen ("refuse", "drop") in eine AccessAction um
+func ParseAccessAction(name string) (AccessAction, error) {
+	for a := AccessRefuse; a <= AccessDrop; a++ {
+		if strings.EqualFold(name, a.String()) {
+			return a, nil
+		}
+	}
+	return AccessRefuse, fmt.Errorf("unknown access action: %s", name)
+}
+
+// DefaultAllowedNetworks sind die Netze, die ohne weitere Konfiguration anfragen dürfen:
+// private Bereiche (RFC 1918, ULA), Link-Local und Loopback. Ein offener Resolver im Internet
+// ließe sich sonst für Amplification-Angriffe missbrauchen.
+// Globale IPv6-Präfixe (GUA) des eigenen Netzes sind nicht enthalten und müssen mit Allow
+// erlaubt werden, sonst bekommen IPv6-Clients mit globaler Adresse REFUSED.
+var DefaultAllowedNetworks = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"fc00::/7",
+	"fe80::/10",
+	"::1/128",
+}
+
+// AccessControl entscheidet anhand von Allow- und Deny-Listen, welche Clients anfragen dürfen
+// Deny hat Vorrang vor Allow, Clients außerhalb der Allow-Liste sind nicht erlaubt
+type AccessControl struct {
+	allow  []netip.Prefix
+	deny   []netip.Prefix
+	action AccessAction
+	mu     sync.RWMutex
+}
+
+// NewAccessControl erstellt eine Zugriffskontrolle mit sicheren Standardwerten:
+// nur DefaultAllowedNetworks sind erlaubt, andere Clients bekommen REFUSED
+func NewAccessControl() *AccessControl {
+	ac := &AccessControl{action: AccessRefuse}
+	for _, network := range DefaultAllowedNetworks {
+		ac.allow = append(ac.allow, netip.MustParsePrefix(network))
+	}
+	return ac
+}
+
+// parseNetwork parst ein Netz ("192.168.0.0/16") oder eine einzelne Adresse ("203.0.113.7")
+func parseNetwork(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid network: %s", s)
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid network: %s", s)
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// parseNetworks parst eine Liste von Netzen
+func parseNetworks(networks []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(networks))
+	for _, network := range networks {
+		prefix, err := parseNetwork(network)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// SetAllowed ersetzt die Allow-Liste (auch die Standardwerte)
+// Ein öffentlich erreichbarer Resolver braucht z.B. "0.0.0.0/0" und "::/0"
+func (ac *AccessControl) SetAllowed(networks []string) error {
+	prefixes, err := parseNetworks(networks)
+	if err != nil {
+		return err
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.allow = prefixes
+	return nil
+}
+
+// Allow fügt ein Netz oder eine Adresse zur Allow-Liste hinzu
+func (ac *AccessControl) Allow(network string) error {
+	prefix, err := parseNetwork(network)
+	if err != nil {
+		return err
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	if !slices.Contains(ac.allow, prefix) {
+		ac.allow = append(ac.allow, prefix)
+	}
+	return nil
+}
+
+// Deny fügt ein Netz oder eine Adresse zur Deny-Liste hinzu, sie hat Vorrang vor der Allow-Liste
+func (ac *AccessControl) Deny(network string) error {
+	prefix, err := parseNetwork(network)
+	if err != nil {
+		return err
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	if !slices.Contains(ac.deny, prefix) {
+		ac.deny = append(ac.deny, prefix)
+	}
+	return nil
+}
+
+// GetAllowed gibt die Allow-Liste zurück
+func (ac *AccessControl) GetAllowed() []string {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+
+	return prefixStrings(ac.allow)
+}
+
+// GetDenied gibt die Deny-Liste zurück
+func (ac *AccessControl) GetDenied() []string {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+
+	return prefixStrings(ac.deny)
+}
+
+// prefixStrings wandelt Netze in ihre Schreibweise um
+func prefixStrings(prefixes []netip.Prefix) []string {
+	networks := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		networks[i] = prefix.String()
+	}
+	return networks
+}
+
+// SetAction legt fest, ob nicht erlaubte Clients REFUSED bekommen oder ignoriert werden
+func (ac *AccessControl) SetAction(action AccessAction) error {
+	if action != AccessRefuse && action != AccessDrop {
+		return fmt.Errorf("unknown access action: %d", action)
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.action = action
+	return nil
+}
+
+// GetAction gibt die Aktion für nicht erlaubte Clients zurück
+func (ac *AccessControl) GetAction() AccessAction {
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+
+	return ac.action
+}
+
+// IsAllowed prüft, ob ein Client anfragen darf
+// Ungültige Adressen (z.B. unbekannte Verbindungsarten) sind nicht erlaubt
+func (ac *AccessControl) IsAllowed(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+
+	if containsAddr(ac.deny, addr) {
+		return false
+	}
+	return containsAddr(ac.allow, addr)
+}
+
+// containsAddr prüft, ob eine Adresse in einem der Netze liegt
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+	dnsinternal "gittea.kittel.dev/go-dnsproxy/internal/dns"
+)
+
+// recordingWriter ist ein dns.ResponseWriter, der geschriebene Antworten speichert
+type recordingWriter struct {
+	remote net.Addr
+	msgs   []*dns.Msg
+}
+
+func (w *recordingWriter) LocalAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}
+}
+func (w *recordingWriter) RemoteAddr() net.Addr      { return w.remote }
+func (w *recordingWriter) WriteMsg(m *dns.Msg) error { w.msgs = append(w.msgs, m); return nil }
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+func (w *recordingWriter) Close() error        { return nil }
+func (w *recordingWriter) TsigStatus() error   { return nil }
+func (w *recordingWriter) TsigTimersOnly(bool) {}
+func (w *recordingWriter) Hijack()             {}
+
+func TestAccessControl_IsAllowed(t *testing.T) {
+	ac := NewAccessControl()
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "10.1.2.3", want: true},
+		{addr: "172.31.255.1", want: true},
+		{addr: "192.168.1.20", want: true},
+		{addr: "127.0.0.1", want: true},
+		{addr: "::1", want: true},
+		{addr: "fd00::20", want: true},
+		{addr: "fe80::1", want: true},
+		{addr: "169.254.10.1", want: true},
+		{addr: "::ffff:192.168.1.20", want: true},
+		{addr: "172.32.0.1", want: false},
+		{addr: "8.8.8.8", want: false},
+		{addr: "2001:db8::1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := ac.IsAllowed(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("IsAllowed(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+
+	if ac.IsAllowed(netip.Addr{}) {
+		t.Error("IsAllowed() with invalid address should be false")
+	}
+}
+
+func TestAccessControl_AllowDeny(t *testing.T) {
+	ac := NewAccessControl()
+
+	if err := ac.Allow("203.0.113.0/24"); err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if err := ac.Deny("192.168.1.66"); err != nil {
+		t.Fatalf("Deny() error = %v", err)
+	}
+	if err := ac.Deny("not-a-network"); err == nil {
+		t.Error("Deny() with invalid network should return error")
+	}
+
+	if !ac.IsAllowed(netip.MustParseAddr("203.0.113.9")) {
+		t.Error("IsAllowed() for added network = false, want true")
+	}
+	// Deny hat Vorrang vor dem erlaubten privaten Netz
+	if ac.IsAllowed(netip.MustParseAddr("192.168.1.66")) {
+		t.Error("IsAllowed() for denied address = true, want false")
+	}
+	if got := ac.GetDenied(); !slices.Equal(got, []string{"192.168.1.66/32"}) {
+		t.Errorf("GetDenied() = %v", got)
+	}
+
+	// SetAllowed ersetzt die Standardwerte
+	if err := ac.SetAllowed([]string{"0.0.0.0/0"}); err != nil {
+		t.Fatalf("SetAllowed() error = %v", err)
+	}
+	if !ac.IsAllowed(netip.MustParseAddr("8.8.8.8")) || ac.IsAllowed(netip.MustParseAddr("::1")) {
+		t.Errorf("SetAllowed() = %v, want only IPv4", ac.GetAllowed())
+	}
+	if err := ac.SetAllowed([]string{"10.0.0.0/8", "bogus"}); err == nil {
+		t.Error("SetAllowed() with invalid network should return error")
+	}
+}
+
+func TestParseAccessAction(t *testing.T) {
+	for _, a := range []AccessAction{AccessRefuse, AccessDrop} {
+		if got, err := ParseAccessAction(a.String()); err != nil || got != a {
+			t.Errorf("ParseAccessAction(%q) = %v, %v", a.String(), got, err)
+		}
+	}
+	if _, err := ParseAccessAction("ignore"); err == nil {
+		t.Error("ParseAccessAction() with unknown name should return error")
+	}
+	if err := NewAccessControl().SetAction(AccessAction(7)); err == nil {
+		t.Error("SetAction() with unknown action should return error")
+	}
+}
+
+func TestDNSServer_AccessControl(t *testing.T) {
+	blacklist := dnsinternal.NewBlacklist()
+	blacklist.AddDomain("blocked.example.com")
+	proxy := dnsinternal.NewProxy(dnsinternal.NewRegistry(), blacklist)
+	server, _ := NewDNSServer("127.0.0.1:0", proxy)
+	defer server.Stop()
+
+	if server.GetAccessControl() == nil {
+		t.Fatal("new server should have an access control with safe defaults")
+	}
+
+	query := new(dns.Msg)
+	query.SetQuestion("blocked.example.com.", dns.TypeA)
+
+	tests := []struct {
+		name      string
+		client    string
+		action    AccessAction
+		wantMsgs  int
+		wantRcode int
+	}{
+		{name: "Private client", client: "192.168.1.20", action: AccessRefuse, wantMsgs: 1, wantRcode: dns.RcodeSuccess},
+		{name: "Public client refused", client: "198.51.100.7", action: AccessRefuse, wantMsgs: 1, wantRcode: dns.RcodeRefused},
+		{name: "Public client dropped", client: "198.51.100.7", action: AccessDrop, wantMsgs: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.GetAccessControl().SetAction(tt.action)
+			w := &recordingWriter{remote: &net.UDPAddr{IP: net.ParseIP(tt.client), Port: 40000}}
+			server.handleDNSRequest(w, query)
+
+			if len(w.msgs) != tt.wantMsgs {
+				t.Fatalf("handleDNSRequest() wrote %d messages, want %d", len(w.msgs), tt.wantMsgs)
+			}
+			if tt.wantMsgs == 0 {
+				return
+			}
+			if w.msgs[0].Rcode != tt.wantRcode {
+				t.Errorf("Rcode = %s, want %s", dns.RcodeToString[w.msgs[0].Rcode], dns.RcodeToString[tt.wantRcode])
+			}
+			if tt.wantRcode == dns.RcodeRefused && len(w.msgs[0].Answer) != 0 {
+				t.Errorf("refused response has %d answers, want none", len(w.msgs[0].Answer))
+			}
+		})
+	}
+
+	// Ohne Zugriffskontrolle werden alle Clients beantwortet
+	server.SetAccessControl(nil)
+	w := &recordingWriter{remote: &net.UDPAddr{IP: net.ParseIP("198.51.100.7"), Port: 40000}}
+	server.handleDNSRequest(w, query)
+	if len(w.msgs) != 1 || len(w.msgs[0].Answer) != 1 {
+		t.Errorf("handleDNSRequest() without access control = %v, want blocked answer", w.msgs)
+	}
+}
@@ -1,8 +1,11 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"net/netip"
+	"sync"
 
 	"github.com/miekg/dns"
 	dnsinternal "gittea.kittel.dev/go-dnsproxy/internal/dns"
@@ -12,11 +15,21 @@ import (
 type DNSServer struct {
 	proxy  *dnsinternal.Proxy
 	server *dns.Server
+	tcp    *dns.Server // für Clients, die nach einer abgeschnittenen Antwort über TCP wiederholen
 	addr   string
+	access *AccessControl     // erlaubte Clients, nil = alle
+	limit  *RateLimiter       // Rate Limiting pro Client und RRL für erlaubte Clients, nil = unbegrenzt
+	refuse *RateLimiter       // eigenes Limit für REFUSED an nicht erlaubte Clients, nil = unbegrenzt
+	ctx    context.Context    // Basis-Context für alle Anfragen, bei jedem Start neu
+	cancel context.CancelFunc // Bricht laufende Upstream-Abfragen beim Stop ab
+	mu     sync.Mutex         // schützt ctx und cancel
 }
 
 // NewDNSServer erstellt einen neuen DNS-Server
 // addr: Adresse zum Lauschen (z.B. ":53" oder "127.0.0.1:5353")
+// Standardmäßig beantwortet er nur Clients aus privaten Netzen und Loopback, siehe SetAccessControl,
+// und begrenzt Anfragen mit DefaultRateLimitConfig, siehe SetRateLimiter, sowie REFUSED-Antworten
+// an nicht erlaubte Clients mit DefaultRefuseRateLimitConfig, siehe SetRefuseRateLimiter
 func NewDNSServer(addr string, proxy *dnsinternal.Proxy) (*DNSServer, error) {
 	if addr == "" {
 		return nil, fmt.Errorf("address cannot be empty")
@@ -25,9 +38,21 @@ func NewDNSServer(addr string, proxy *dnsinternal.Proxy) (*DNSServer, error) {
 		return nil, fmt.Errorf("proxy cannot be nil")
 	}
 
+	limit, err := NewRateLimiter(DefaultRateLimitConfig)
+	if err != nil {
+		return nil, err
+	}
+	refuse, err := NewRateLimiter(DefaultRefuseRateLimitConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &DNSServer{
-		proxy: proxy,
-		addr:  addr,
+		proxy:  proxy,
+		addr:   addr,
+		access: NewAccessControl(),
+		limit:  limit,
+		refuse: refuse,
 	}
 
 	// Erstelle DNS-Server mit UDP
@@ -36,11 +61,48 @@ func NewDNSServer(addr string, proxy *dnsinternal.Proxy) (*DNSServer, error) {
 		Net:     "udp",
 		Handler: dns.HandlerFunc(s.handleDNSRequest),
 	}
+	s.tcp = &dns.Server{
+		Addr:    addr,
+		Net:     "tcp",
+		Handler: dns.HandlerFunc(s.handleDNSRequest),
+	}
 
 	return s, nil
 }
 
-// Start startet den DNS-Server
+// SetAccessControl setzt die Zugriffskontrolle, nil beantwortet alle Clients (offener Resolver)
+func (s *DNSServer) SetAccessControl(access *AccessControl) {
+	s.access = access
+}
+
+// GetAccessControl gibt die Zugriffskontrolle zurück
+func (s *DNSServer) GetAccessControl() *AccessControl {
+	return s.access
+}
+
+// SetRateLimiter setzt das Rate Limiting, nil schaltet es ab
+func (s *DNSServer) SetRateLimiter(limit *RateLimiter) {
+	s.limit = limit
+}
+
+// GetRateLimiter gibt das Rate Limiting zurück (Limits und Zähler)
+func (s *DNSServer) GetRateLimiter() *RateLimiter {
+	return s.limit
+}
+
+// SetRefuseRateLimiter setzt das Limit für REFUSED-Antworten an nicht erlaubte Clients, nil schaltet es ab
+// Es ist vom Rate Limiting erlaubter Clients getrennt, damit nicht erlaubter (gefälschter)
+// Verkehr deren Buckets nicht verdrängen kann
+func (s *DNSServer) SetRefuseRateLimiter(limit *RateLimiter) {
+	s.refuse = limit
+}
+
+// GetRefuseRateLimiter gibt das Limit für REFUSED-Antworten zurück
+func (s *DNSServer) GetRefuseRateLimiter() *RateLimiter {
+	return s.refuse
+}
+
+// Start startet den DNS-Server auf UDP und TCP
 func (s *DNSServer) Start() error {
 	// Prüfe ob Port verfügbar ist
 	conn, err := net.ListenPacket("udp", s.addr)
@@ -48,48 +110,156 @@ func (s *DNSServer) Start() error {
 		return fmt.Errorf("failed to bind to %s: %w", s.addr, err)
 	}
 	conn.Close()
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to bind to %s: %w", s.addr, err)
+	}
+	listener.Close()
+
+	// Neuer Context pro Start, der vorherige wurde beim Stop abgebrochen
+	s.mu.Lock()
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.mu.Unlock()
 
-	// Starte Server in Goroutine
-	go func() {
-		if err := s.server.ListenAndServe(); err != nil {
-			// Server wurde gestoppt oder Fehler
-			fmt.Printf("DNS Server stopped: %v\n", err)
+	// Starte Server in Goroutinen und warte, bis beide lauschen
+	// Sonst könnte ein direkt folgendes Stop vor dem Binden laufen und nichts beenden
+	started := make(chan error, 2)
+	for _, srv := range []*dns.Server{s.server, s.tcp} {
+		srv.NotifyStartedFunc = func() { started <- nil }
+		go func() {
+			if err := srv.ListenAndServe(); err != nil {
+				// Server wurde gestoppt oder Fehler
+				fmt.Printf("DNS Server (%s) stopped: %v\n", srv.Net, err)
+				select {
+				case started <- err:
+				default:
+				}
+			}
+		}()
+	}
+
+	for range 2 {
+		if err := <-started; err != nil {
+			s.Stop()
+			return fmt.Errorf("failed to start DNS server on %s: %w", s.addr, err)
 		}
-	}()
+	}
 
 	return nil
 }
 
 // Stop stoppt den DNS-Server
+// Laufende Upstream-Abfragen werden über den Server-Context abgebrochen
 func (s *DNSServer) Stop() error {
-	if s.server == nil {
-		return nil
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
 	}
-	return s.server.Shutdown()
+	s.mu.Unlock()
+
+	err := s.server.Shutdown()
+	if tcpErr := s.tcp.Shutdown(); err == nil {
+		err = tcpErr
+	}
+	return err
 }
 
 // handleDNSRequest behandelt eingehende DNS-Anfragen
 func (s *DNSServer) handleDNSRequest(w dns.ResponseWriter, r *dns.Msg) {
+	// Nicht erlaubte Clients bekommen REFUSED oder keine Antwort (kein offener Resolver)
+	// REFUSED läuft über ein eigenes Limit, sonst ließe es sich mit gefälschten Absendern
+	// ungebremst reflektieren
+	addr, ok := clientAddr(w.RemoteAddr())
+	if s.access != nil && !s.access.IsAllowed(addr) {
+		if s.access.GetAction() == AccessRefuse && (s.refuse == nil || (ok && s.refuse.AllowQuery(addr))) {
+			refused := new(dns.Msg)
+			refused.SetRcode(r, dns.RcodeRefused)
+			w.WriteMsg(refused)
+		}
+		return
+	}
+
+	// Clients über dem Anfrage-Limit werden ignoriert, eine Antwort würde die Last nur erhöhen
+	if s.limit != nil && ok && !s.limit.AllowQuery(addr) {
+		return
+	}
+
+	// Jede Anfrage bekommt einen eigenen Context, der beim Stop mit abgebrochen wird
+	ctx, cancel := context.WithCancel(s.baseContext())
+	defer cancel()
+
+	// Die Client-Adresse bestimmt die Client-Gruppe (eigene Listen, Upstreams, Block-Antwort)
+	if ok {
+		ctx = dnsinternal.WithClientAddr(ctx, addr)
+	}
+
 	msg := new(dns.Msg)
 	msg.SetReply(r)
 	msg.Authoritative = true
 
 	// Verarbeite jede Frage in der Anfrage
 	for _, question := range r.Question {
-		answers := s.processQuestion(question)
+		answers, authority, rcode := s.processQuestion(ctx, question)
 		msg.Answer = append(msg.Answer, answers...)
+		msg.Ns = append(msg.Ns, authority...)
+		if rcode != dns.RcodeSuccess {
+			msg.Rcode = rcode
+		}
+	}
+
+	s.writeResponse(w, r, addr, ok, msg)
+}
+
+// writeResponse sendet eine Antwort, über UDP nach dem Response Rate Limiting
+// Absender lassen sich bei UDP fälschen, bei TCP nicht
+func (s *DNSServer) writeResponse(w dns.ResponseWriter, r *dns.Msg, addr netip.Addr, ok bool, msg *dns.Msg) {
+	if _, udp := w.RemoteAddr().(*net.UDPAddr); udp && s.limit != nil && ok && len(r.Question) > 0 {
+		switch s.limit.CheckResponse(addr, r.Question[0], msg.Rcode) {
+		case ResponseDrop:
+			return
+		case ResponseSlip:
+			// Leere Antwort mit TC-Bit: echte Clients wiederholen die Anfrage über TCP
+			truncated := new(dns.Msg)
+			truncated.SetReply(r)
+			truncated.Truncated = true
+			w.WriteMsg(truncated)
+			return
+		}
 	}
 
 	w.WriteMsg(msg)
 }
 
-// processQuestion verarbeitet eine DNS-Frage und gibt Antworten zurück
-func (s *DNSServer) processQuestion(q dns.Question) []dns.RR {
-	var answers []dns.RR
+// baseContext gibt den Context des laufenden Servers zurück
+// Vor dem ersten Start (z.B. in Tests) ist das context.Background()
+func (s *DNSServer) baseContext() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// clientAddr gibt die IP-Adresse eines Clients aus der Remote-Adresse der Verbindung zurück
+func clientAddr(addr net.Addr) (netip.Addr, bool) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.AddrPort().Addr().Unmap(), true
+	case *net.TCPAddr:
+		return a.AddrPort().Addr().Unmap(), true
+	default:
+		return netip.Addr{}, false
+	}
+}
+
+// processQuestion verarbeitet eine DNS-Frage und gibt Antworten, Authority-Records und Rcode zurück
+// Blockierte Domains werden je nach Block-Modus mit IPs, NXDOMAIN, REFUSED oder NODATA beantwortet
+func (s *DNSServer) processQuestion(ctx context.Context, q dns.Question) (answers []dns.RR, authority []dns.RR, rcode int) {
 	// Unterstütze nur A (IPv4) und AAAA (IPv6) Records
 	if q.Qtype != dns.TypeA && q.Qtype != dns.TypeAAAA {
-		return answers
+		return nil, nil, dns.RcodeSuccess
 	}
 
 	// Extrahiere Domain-Namen (entferne trailing dot)
@@ -98,26 +268,55 @@ func (s *DNSServer) processQuestion(q dns.Question) []dns.RR {
 		domain = domain[:len(domain)-1]
 	}
 
-	// Frage Proxy nach IPs
-	ips, err := s.proxy.Lookup(domain)
+	// Frage Proxy nach IPs, der Query-Typ wird für $dnstype-Regeln mitgegeben
+	result, err := s.proxy.Resolve(dnsinternal.WithQueryType(ctx, q.Qtype), domain)
 	if err != nil {
 		// Fehler bei Lookup - keine Antworten zurückgeben
-		return answers
+		return nil, nil, dns.RcodeSuccess
+	}
+
+	ttl := result.TTL
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+
+	// SafeSearch-Umschreibung: CNAME auf das Ziel, die Adressen gehören zum Ziel
+	name := q.Name
+	if result.Rewrite != "" {
+		name = dns.Fqdn(result.Rewrite)
+		answers = append(answers, &dns.CNAME{
+			Hdr: dns.RR_Header{
+				Name:   q.Name,
+				Rrtype: dns.TypeCNAME,
+				Class:  dns.ClassINET,
+				Ttl:    ttl,
+			},
+			Target: name,
+		})
 	}
 
 	// Konvertiere IPs zu DNS-Records
-	for _, ip := range ips {
-		rr := s.createDNSRecord(q.Name, ip, q.Qtype)
+	for _, ip := range result.IPs {
+		rr := s.createDNSRecord(name, ip, q.Qtype, ttl)
 		if rr != nil {
 			answers = append(answers, rr)
 		}
 	}
 
-	return answers
+	// NXDOMAIN und NODATA brauchen einen SOA-Record, damit Clients die negative Antwort
+	// nur für die Block-TTL cachen (RFC 2308)
+	if result.Blocked && len(answers) == 0 && result.Rcode != dns.RcodeRefused {
+		authority = append(authority, createBlockSOA(q.Name, ttl))
+	}
+
+	return answers, authority, result.Rcode
 }
 
+// defaultTTL ist die TTL für Antworten ohne eigene TTL (5 Minuten)
+const defaultTTL = 300
+
 // createDNSRecord erstellt einen DNS-Record (A oder AAAA) aus einer IP-Adresse
-func (s *DNSServer) createDNSRecord(name string, ip string, qtype uint16) dns.RR {
+func (s *DNSServer) createDNSRecord(name string, ip string, qtype uint16, ttl uint32) dns.RR {
 	parsedIP := net.ParseIP(ip)
 	if parsedIP == nil {
 		return nil
@@ -130,7 +329,7 @@ func (s *DNSServer) createDNSRecord(name string, ip string, qtype uint16) dns.RR
 				Name:   name,
 				Rrtype: dns.TypeA,
 				Class:  dns.ClassINET,
-				Ttl:    300, // 5 Minuten TTL
+				Ttl:    ttl,
 			},
 			A: parsedIP.To4(),
 		}
@@ -143,7 +342,7 @@ func (s *DNSServer) createDNSRecord(name string, ip string, qtype uint16) dns.RR
 				Name:   name,
 				Rrtype: dns.TypeAAAA,
 				Class:  dns.ClassINET,
-				Ttl:    300, // 5 Minuten TTL
+				Ttl:    ttl,
 			},
 			AAAA: parsedIP,
 		}
@@ -152,6 +351,26 @@ func (s *DNSServer) createDNSRecord(name string, ip string, qtype uint16) dns.RR
 	return nil
 }
 
+// createBlockSOA erstellt den SOA-Record für negative Block-Antworten
+// Minttl bestimmt, wie lange Clients NXDOMAIN/NODATA cachen
+func createBlockSOA(name string, ttl uint32) dns.RR {
+	return &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   name,
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Ns:      "blocked.go-dnsproxy.",
+		Mbox:    "hostmaster.go-dnsproxy.",
+		Serial:  1,
+		Refresh: 1800,
+		Retry:   900,
+		Expire:  604800,
+		Minttl:  ttl,
+	}
+}
+
 // GetAddr gibt die Server-Adresse zurück
 func (s *DNSServer) GetAddr() string {
 	return s.addr
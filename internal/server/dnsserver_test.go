@@ -1,6 +1,8 @@
 package server
 
 import (
+	"context"
+	"net"
 	"testing"
 	"time"
 
@@ -260,3 +262,191 @@ func testDNSQueryBlocked(t *testing.T, serverAddr, domain string) {
 		t.Error("Blocked domain should return 0.0.0.0")
 	}
 }
+
+func TestDNSServer_StopCancelsContext(t *testing.T) {
+	registry := dnsinternal.NewRegistry()
+	blacklist := dnsinternal.NewBlacklist()
+	proxy := dnsinternal.NewProxy(registry, blacklist)
+
+	server, _ := NewDNSServer("127.0.0.1:15358", proxy)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	ctx := server.baseContext()
+	if ctx.Err() != nil {
+		t.Fatal("Server context should be active before Stop()")
+	}
+
+	server.Stop()
+
+	if ctx.Err() == nil {
+		t.Error("Stop() should cancel the server context")
+	}
+
+	// Nach dem Stop liefern Fragen keine Antworten mehr, auch ohne Upstream-Timeout
+	q := dns.Question{Name: "example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	if answers, _, _ := server.processQuestion(ctx, q); len(answers) != 0 {
+		t.Errorf("processQuestion() after Stop() returned %d answers, want 0", len(answers))
+	}
+
+	// Ein erneuter Start bekommt einen frischen Context
+	if err := server.Start(); err != nil {
+		t.Fatalf("second Start() failed: %v", err)
+	}
+	defer server.Stop()
+	if server.baseContext().Err() != nil {
+		t.Error("Server context should be active after restart")
+	}
+}
+
+func TestDNSServer_BlockModes(t *testing.T) {
+	tests := []struct {
+		name       string
+		resp       dnsinternal.BlockResponse
+		qtype      uint16
+		wantAnswer string
+		wantRcode  int
+		wantSOA    bool
+		wantTTL    uint32
+	}{
+		{name: "Null IP", resp: dnsinternal.BlockResponse{Mode: dnsinternal.BlockNullIP, TTL: 20}, qtype: dns.TypeA, wantAnswer: "0.0.0.0", wantRcode: dns.RcodeSuccess, wantTTL: 20},
+		{name: "Null IP AAAA", resp: dnsinternal.BlockResponse{Mode: dnsinternal.BlockNullIP}, qtype: dns.TypeAAAA, wantAnswer: "::", wantRcode: dns.RcodeSuccess, wantTTL: 300},
+		{name: "NXDOMAIN", resp: dnsinternal.BlockResponse{Mode: dnsinternal.BlockNXDomain, TTL: 10}, qtype: dns.TypeA, wantRcode: dns.RcodeNameError, wantSOA: true, wantTTL: 10},
+		{name: "REFUSED", resp: dnsinternal.BlockResponse{Mode: dnsinternal.BlockRefused}, qtype: dns.TypeA, wantRcode: dns.RcodeRefused},
+		{name: "NODATA", resp: dnsinternal.BlockResponse{Mode: dnsinternal.BlockNoData, TTL: 30}, qtype: dns.TypeA, wantRcode: dns.RcodeSuccess, wantSOA: true, wantTTL: 30},
+		{name: "Custom IP", resp: dnsinternal.BlockResponse{Mode: dnsinternal.BlockCustomIP, IPv4: "10.0.0.80"}, qtype: dns.TypeA, wantAnswer: "10.0.0.80", wantRcode: dns.RcodeSuccess, wantTTL: 300},
+		// Ohne eigene IPv6-Adresse bleibt die AAAA-Antwort leer
+		{name: "Custom IP AAAA", resp: dnsinternal.BlockResponse{Mode: dnsinternal.BlockCustomIP, IPv4: "10.0.0.80"}, qtype: dns.TypeAAAA, wantRcode: dns.RcodeSuccess, wantSOA: true, wantTTL: 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blacklist := dnsinternal.NewBlacklist()
+			blacklist.AddDomain("blocked.example.com")
+			proxy := dnsinternal.NewProxy(dnsinternal.NewRegistry(), blacklist)
+			if err := proxy.SetBlockResponse(tt.resp); err != nil {
+				t.Fatalf("SetBlockResponse() error = %v", err)
+			}
+			server, _ := NewDNSServer("127.0.0.1:0", proxy)
+			defer server.Stop()
+
+			q := dns.Question{Name: "blocked.example.com.", Qtype: tt.qtype, Qclass: dns.ClassINET}
+			answers, authority, rcode := server.processQuestion(context.Background(), q)
+
+			if rcode != tt.wantRcode {
+				t.Errorf("rcode = %s, want %s", dns.RcodeToString[rcode], dns.RcodeToString[tt.wantRcode])
+			}
+			if tt.wantAnswer == "" && len(answers) != 0 {
+				t.Errorf("answers = %v, want none", answers)
+			}
+			if tt.wantAnswer != "" {
+				if len(answers) != 1 {
+					t.Fatalf("answers = %v, want one record", answers)
+				}
+				var got string
+				switch rr := answers[0].(type) {
+				case *dns.A:
+					got = rr.A.String()
+				case *dns.AAAA:
+					got = rr.AAAA.String()
+				}
+				if got != tt.wantAnswer || answers[0].Header().Ttl != tt.wantTTL {
+					t.Errorf("answer = %s (ttl %d), want %s (ttl %d)", got, answers[0].Header().Ttl, tt.wantAnswer, tt.wantTTL)
+				}
+			}
+			if tt.wantSOA {
+				if len(authority) != 1 {
+					t.Fatalf("authority = %v, want SOA", authority)
+				}
+				soa, ok := authority[0].(*dns.SOA)
+				if !ok || soa.Minttl != tt.wantTTL {
+					t.Errorf("authority = %v, want SOA with minttl %d", authority[0], tt.wantTTL)
+				}
+			} else if len(authority) != 0 {
+				t.Errorf("authority = %v, want none", authority)
+			}
+		})
+	}
+}
+
+func TestDNSServer_SafeSearch(t *testing.T) {
+	blacklist := dnsinternal.NewBlacklist()
+	blacklist.AddLocalRecord("restrict.youtube.com", "216.239.38.120")
+	proxy := dnsinternal.NewProxy(dnsinternal.NewRegistry(), blacklist)
+	proxy.SetSafeSearch(dnsinternal.NewSafeSearch())
+	server, _ := NewDNSServer("127.0.0.1:0", proxy)
+	defer server.Stop()
+
+	q := dns.Question{Name: "www.youtube.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	answers, _, rcode := server.processQuestion(context.Background(), q)
+	if rcode != dns.RcodeSuccess || len(answers) != 2 {
+		t.Fatalf("processQuestion() = %v (%s), want CNAME and A", answers, dns.RcodeToString[rcode])
+	}
+
+	cname, ok := answers[0].(*dns.CNAME)
+	if !ok || cname.Hdr.Name != "www.youtube.com." || cname.Target != "restrict.youtube.com." {
+		t.Errorf("answers[0] = %v, want CNAME www.youtube.com. -> restrict.youtube.com.", answers[0])
+	}
+	a, ok := answers[1].(*dns.A)
+	if !ok || a.Hdr.Name != "restrict.youtube.com." || a.A.String() != "216.239.38.120" {
+		t.Errorf("answers[1] = %v, want A restrict.youtube.com. 216.239.38.120", answers[1])
+	}
+}
+
+func TestDNSServer_ClientGroups(t *testing.T) {
+	blacklist := dnsinternal.NewBlacklist()
+	blacklist.AddDomain("blocked.example.com")
+	proxy := dnsinternal.NewProxy(dnsinternal.NewRegistry(), blacklist)
+
+	// Anfragen von Loopback gehören zur Gruppe mit NXDOMAIN statt 0.0.0.0
+	groups := dnsinternal.NewClientGroups()
+	groups.AddGroup(dnsinternal.ClientGroup{
+		Name:     "local",
+		Networks: []string{"127.0.0.0/8"},
+		Block:    dnsinternal.BlockResponse{Mode: dnsinternal.BlockNXDomain},
+	})
+	proxy.SetClientGroups(groups)
+
+	server, err := NewDNSServer("127.0.0.1:15359", proxy)
+	if err != nil {
+		t.Fatalf("NewDNSServer() failed: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	defer server.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	m := new(dns.Msg)
+	m.SetQuestion("blocked.example.com.", dns.TypeA)
+	r, _, err := new(dns.Client).Exchange(m, "127.0.0.1:15359")
+	if err != nil {
+		t.Fatalf("DNS query failed: %v", err)
+	}
+	if r.Rcode != dns.RcodeNameError || len(r.Answer) != 0 {
+		t.Errorf("response = %s with %d answers, want NXDOMAIN for client group", dns.RcodeToString[r.Rcode], len(r.Answer))
+	}
+}
+
+func TestClientAddr(t *testing.T) {
+	tests := []struct {
+		name string
+		addr net.Addr
+		want string
+	}{
+		{name: "UDP IPv4", addr: &net.UDPAddr{IP: net.ParseIP("192.168.1.20"), Port: 5353}, want: "192.168.1.20"},
+		{name: "UDP IPv4-mapped", addr: &net.UDPAddr{IP: net.ParseIP("::ffff:192.168.1.20"), Port: 5353}, want: "192.168.1.20"},
+		{name: "TCP IPv6", addr: &net.TCPAddr{IP: net.ParseIP("fd00::20"), Port: 5353}, want: "fd00::20"},
+		{name: "Unix socket", addr: &net.UnixAddr{Name: "/run/dns.sock", Net: "unix"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, ok := clientAddr(tt.addr)
+			if ok != (tt.want != "") || (ok && addr.String() != tt.want) {
+				t.Errorf("clientAddr(%v) = %v, %v, want %q", tt.addr, addr, ok, tt.want)
+			}
+		})
+	}
+}
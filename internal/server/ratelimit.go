@@ -0,0 +1,369 @@
+package server
+
+import (
+	"container/list"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// RateLimitConfig sind die Limits des RateLimiters
+// Ein Wert von 0 schaltet das jeweilige Limit ab.
+type RateLimitConfig struct {
+	// QueriesPerSecond begrenzt die Anfragen pro Client (bzw. Client-Netz), Burst erlaubt kurze Spitzen
+	QueriesPerSecond float64
+	Burst            int
+
+	// IPv4PrefixLen und IPv6PrefixLen fassen Clients zu Netzen zusammen, 0 = einzelne Adresse
+	// IPv6-Clients haben meist ein ganzes /56 oder /64 und könnten sonst beliebig viele Buckets belegen
+	IPv4PrefixLen int
+	IPv6PrefixLen int
+
+	// ResponsesPerSecond begrenzt gleiche UDP-Antworten (Name, Typ, Rcode) an ein Client-Netz
+	// (Response Rate Limiting). Schützt davor, dass der Proxy mit gefälschten Absendern für
+	// Reflection-Angriffe missbraucht wird.
+	ResponsesPerSecond float64
+	ResponseBurst      int
+
+	// Slip beantwortet jede n-te verworfene Antwort mit einer leeren, abgeschnittenen Antwort (TC-Bit)
+	// Echte Clients wiederholen die Anfrage dann über TCP, 0 = alle verwerfen, 1 = nie verwerfen
+	Slip int
+}
+
+// DefaultRateLimitConfig sind großzügige Limits für ein Heimnetz
+// Ein normaler Client bleibt weit darunter, ein amoklaufendes Gerät wird gebremst.
+var DefaultRateLimitConfig = RateLimitConfig{
+	QueriesPerSecond:   50,
+	Burst:              100,
+	IPv4PrefixLen:      32,
+	IPv6PrefixLen:      56,
+	ResponsesPerSecond: 10,
+	ResponseBurst:      20,
+	Slip:               2,
+}
+
+// DefaultRefuseRateLimitConfig begrenzt REFUSED-Antworten an nicht erlaubte Clients pro /24 bzw. /48
+// Über UDP lassen sich Absender fälschen, mehr als ein paar Antworten pro Netz braucht niemand
+var DefaultRefuseRateLimitConfig = RateLimitConfig{
+	QueriesPerSecond: 5,
+	Burst:            10,
+	IPv4PrefixLen:    24,
+	IPv6PrefixLen:    48,
+}
+
+// validate prüft die Limits
+func (c RateLimitConfig) validate() error {
+	if c.QueriesPerSecond < 0 || c.Burst < 0 || c.ResponsesPerSecond < 0 || c.ResponseBurst < 0 {
+		return fmt.Errorf("rate limits cannot be negative")
+	}
+	if (c.QueriesPerSecond > 0 && c.Burst < 1) || (c.ResponsesPerSecond > 0 && c.ResponseBurst < 1) {
+		return fmt.Errorf("burst must be at least 1 when a rate limit is set")
+	}
+	if c.Slip < 0 {
+		return fmt.Errorf("slip cannot be negative: %d", c.Slip)
+	}
+	if c.IPv4PrefixLen < 0 || c.IPv4PrefixLen > 32 {
+		return fmt.Errorf("invalid IPv4 prefix length: %d", c.IPv4PrefixLen)
+	}
+	if c.IPv6PrefixLen < 0 || c.IPv6PrefixLen > 128 {
+		return fmt.Errorf("invalid IPv6 prefix length: %d", c.IPv6PrefixLen)
+	}
+	return nil
+}
+
+// ResponseAction ist die Entscheidung des Response Rate Limitings
+type ResponseAction int
+
+const (
+	// ResponseSend schickt die Antwort
+	ResponseSend ResponseAction = iota
+	// ResponseDrop verwirft die Antwort
+	ResponseDrop
+	// ResponseSlip schickt statt der Antwort eine leere Antwort mit TC-Bit
+	ResponseSlip
+)
+
+// String gibt den Namen der Aktion zurück
+func (a ResponseAction) String() string {
+	switch a {
+	case ResponseSend:
+		return "send"
+	case ResponseDrop:
+		return "drop"
+	case ResponseSlip:
+		return "slip"
+	default:
+		return "unknown"
+	}
+}
+
+// RateLimitStats sind die Zähler des RateLimiters seit dem Start
+type RateLimitStats struct {
+	QueriesAllowed   uint64 // Anfragen innerhalb des Limits
+	QueriesLimited   uint64 // verworfene Anfragen über dem Limit pro Client
+	ResponsesDropped uint64 // vom Response Rate Limiting verworfene Antworten
+	ResponsesSlipped uint64 // statt der Antwort gesendete abgeschnittene Antworten
+	BucketsEvicted   uint64 // verdrängte Buckets, weil das Bucket-Limit erreicht war
+}
+
+// tokenBucket ist ein Token-Bucket: er füllt sich mit rate Tokens pro Sekunde bis burst
+type tokenBucket struct {
+	tokens  float64
+	last    time.Time
+	limited int // verworfene Antworten in Folge, für Slip
+}
+
+// take entnimmt ein Token, false wenn der Bucket leer ist
+func (b *tokenBucket) take(rate float64, burst int, now time.Time) bool {
+	b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*rate, float64(burst))
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// full prüft, ob der Bucket inzwischen wieder voll ist und damit gelöscht werden kann
+func (b *tokenBucket) full(rate float64, burst int, now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*rate >= float64(burst)
+}
+
+// responseKey identifiziert gleiche Antworten an ein Client-Netz
+type responseKey struct {
+	client netip.Prefix
+	name   string
+	qtype  uint16
+	rcode  int
+}
+
+const (
+	// maxBuckets begrenzt die Buckets pro Map, wenn viele (gefälschte) Absender anfragen
+	// Ist die Map voll, wird der am längsten ungenutzte Bucket verdrängt
+	maxBuckets = 100000
+	// sweepInterval ist der Abstand, in dem volle Buckets entfernt werden
+	sweepInterval = time.Minute
+)
+
+// bucketMap hält Token-Buckets in der Reihenfolge ihrer letzten Nutzung
+// Neue Schlüssel werden nie abgewiesen: bei vollem Limit fällt der am längsten ungenutzte
+// Bucket weg, aktive Clients bleiben so auch bei einer Flut neuer Absender erhalten
+type bucketMap[K comparable] struct {
+	buckets map[K]*list.Element // Wert: *bucketEntry[K]
+	order   *list.List          // vorne zuletzt genutzt, hinten am längsten ungenutzt
+	limit   int
+}
+
+// bucketEntry ist ein Bucket mit seinem Schlüssel, damit er beim Verdrängen gelöscht werden kann
+type bucketEntry[K comparable] struct {
+	key    K
+	bucket tokenBucket
+}
+
+// newBucketMap erstellt eine leere bucketMap mit höchstens limit Buckets
+func newBucketMap[K comparable](limit int) *bucketMap[K] {
+	return &bucketMap[K]{buckets: make(map[K]*list.Element), order: list.New(), limit: limit}
+}
+
+// get gibt den Bucket eines Schlüssels zurück und legt ihn bei Bedarf mit burst Tokens an
+// evicted meldet, dass dafür ein anderer Bucket verdrängt wurde
+func (m *bucketMap[K]) get(key K, burst int, now time.Time) (bucket *tokenBucket, evicted bool) {
+	if elem, ok := m.buckets[key]; ok {
+		m.order.MoveToFront(elem)
+		return &elem.Value.(*bucketEntry[K]).bucket, false
+	}
+
+	if len(m.buckets) >= m.limit {
+		oldest := m.order.Back()
+		delete(m.buckets, oldest.Value.(*bucketEntry[K]).key)
+		m.order.Remove(oldest)
+		evicted = true
+	}
+
+	entry := &bucketEntry[K]{key: key, bucket: tokenBucket{tokens: float64(burst), last: now}}
+	m.buckets[key] = m.order.PushFront(entry)
+	return &entry.bucket, evicted
+}
+
+// removeFull entfernt alle Buckets, die wieder voll sind
+func (m *bucketMap[K]) removeFull(rate float64, burst int, now time.Time) {
+	for elem := m.order.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*bucketEntry[K])
+		if entry.bucket.full(rate, burst, now) {
+			delete(m.buckets, entry.key)
+			m.order.Remove(elem)
+		}
+		elem = next
+	}
+}
+
+// reset entfernt alle Buckets
+func (m *bucketMap[K]) reset() {
+	clear(m.buckets)
+	m.order.Init()
+}
+
+// len gibt die Anzahl der Buckets zurück
+func (m *bucketMap[K]) len() int {
+	return len(m.buckets)
+}
+
+// RateLimiter begrenzt Anfragen pro Client und gleiche Antworten (RRL) mit Token-Buckets
+type RateLimiter struct {
+	config    RateLimitConfig
+	clients   *bucketMap[netip.Prefix]
+	responses *bucketMap[responseKey]
+	lastSweep time.Time
+	now       func() time.Time // Uhr, austauschbar für Tests
+	mu        sync.Mutex
+
+	queriesAllowed   atomic.Uint64
+	queriesLimited   atomic.Uint64
+	responsesDropped atomic.Uint64
+	responsesSlipped atomic.Uint64
+	bucketsEvicted   atomic.Uint64
+}
+
+// NewRateLimiter erstellt einen RateLimiter mit den angegebenen Limits
+func NewRateLimiter(config RateLimitConfig) (*RateLimiter, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	return &RateLimiter{
+		config:    config,
+		clients:   newBucketMap[netip.Prefix](maxBuckets),
+		responses: newBucketMap[responseKey](maxBuckets),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}, nil
+}
+
+// SetConfig ändert die Limits, bestehende Buckets werden verworfen
+func (l *RateLimiter) SetConfig(config RateLimitConfig) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.config = config
+	l.clients.reset()
+	l.responses.reset()
+	return nil
+}
+
+// GetConfig gibt die aktuellen Limits zurück
+func (l *RateLimiter) GetConfig() RateLimitConfig {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.config
+}
+
+// GetStats gibt die Zähler zurück
+func (l *RateLimiter) GetStats() RateLimitStats {
+	return RateLimitStats{
+		QueriesAllowed:   l.queriesAllowed.Load(),
+		QueriesLimited:   l.queriesLimited.Load(),
+		ResponsesDropped: l.responsesDropped.Load(),
+		ResponsesSlipped: l.responsesSlipped.Load(),
+		BucketsEvicted:   l.bucketsEvicted.Load(),
+	}
+}
+
+// clientPrefix fasst eine Client-Adresse zum konfigurierten Netz zusammen
+func (l *RateLimiter) clientPrefix(addr netip.Addr) netip.Prefix {
+	addr = addr.Unmap().WithZone("")
+	bits := l.config.IPv6PrefixLen
+	if addr.Is4() {
+		bits = l.config.IPv4PrefixLen
+	}
+	if bits == 0 {
+		bits = addr.BitLen()
+	}
+	prefix, _ := addr.Prefix(bits)
+	return prefix
+}
+
+// AllowQuery prüft das Anfrage-Limit eines Clients und verbraucht ein Token
+func (l *RateLimiter) AllowQuery(addr netip.Addr) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.config.QueriesPerSecond == 0 {
+		l.queriesAllowed.Add(1)
+		return true
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	bucket, evicted := l.clients.get(l.clientPrefix(addr), l.config.Burst, now)
+	if evicted {
+		l.bucketsEvicted.Add(1)
+	}
+
+	if !bucket.take(l.config.QueriesPerSecond, l.config.Burst, now) {
+		l.queriesLimited.Add(1)
+		return false
+	}
+	l.queriesAllowed.Add(1)
+	return true
+}
+
+// CheckResponse entscheidet per Response Rate Limiting, ob eine UDP-Antwort gesendet wird
+// Über dem Limit wird jede Slip-te Antwort abgeschnitten gesendet, die übrigen verworfen
+func (l *RateLimiter) CheckResponse(addr netip.Addr, q dns.Question, rcode int) ResponseAction {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.config.ResponsesPerSecond == 0 {
+		return ResponseSend
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	key := responseKey{
+		client: l.clientPrefix(addr),
+		name:   strings.ToLower(q.Name),
+		qtype:  q.Qtype,
+		rcode:  rcode,
+	}
+	bucket, evicted := l.responses.get(key, l.config.ResponseBurst, now)
+	if evicted {
+		l.bucketsEvicted.Add(1)
+	}
+
+	if bucket.take(l.config.ResponsesPerSecond, l.config.ResponseBurst, now) {
+		bucket.limited = 0
+		return ResponseSend
+	}
+
+	bucket.limited++
+	if l.config.Slip > 0 && bucket.limited%l.config.Slip == 0 {
+		l.responsesSlipped.Add(1)
+		return ResponseSlip
+	}
+	l.responsesDropped.Add(1)
+	return ResponseDrop
+}
+
+// sweep entfernt wieder volle Buckets, die Obergrenze hält bucketMap auch ohne Sweep ein
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	l.clients.removeFull(l.config.QueriesPerSecond, l.config.Burst, now)
+	l.responses.removeFull(l.config.ResponsesPerSecond, l.config.ResponseBurst, now)
+}
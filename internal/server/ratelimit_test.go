@@ -0,0 +1,335 @@
+package server
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	dnsinternal "gittea.kittel.dev/go-dnsproxy/internal/dns"
+)
+
+// testClock ist eine manuell weitergestellte Uhr für den RateLimiter
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) now() time.Time { return c.t }
+
+func newTestRateLimiter(t *testing.T, config RateLimitConfig) (*RateLimiter, *testClock) {
+	t.Helper()
+	limiter, err := NewRateLimiter(config)
+	if err != nil {
+		t.Fatalf("NewRateLimiter() error = %v", err)
+	}
+	clock := &testClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter.now = clock.now
+	limiter.lastSweep = clock.t
+	return limiter, clock
+}
+
+func TestNewRateLimiter_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config RateLimitConfig
+	}{
+		{name: "Negative rate", config: RateLimitConfig{QueriesPerSecond: -1}},
+		{name: "Rate without burst", config: RateLimitConfig{QueriesPerSecond: 10}},
+		{name: "Response rate without burst", config: RateLimitConfig{ResponsesPerSecond: 5}},
+		{name: "Negative slip", config: RateLimitConfig{Slip: -1}},
+		{name: "IPv4 prefix too long", config: RateLimitConfig{IPv4PrefixLen: 33}},
+		{name: "IPv6 prefix too long", config: RateLimitConfig{IPv6PrefixLen: 129}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRateLimiter(tt.config); err == nil {
+				t.Error("NewRateLimiter() should return error")
+			}
+		})
+	}
+
+	if _, err := NewRateLimiter(RateLimitConfig{}); err != nil {
+		t.Errorf("NewRateLimiter() without limits error = %v", err)
+	}
+}
+
+func TestRateLimiter_AllowQuery(t *testing.T) {
+	limiter, clock := newTestRateLimiter(t, RateLimitConfig{QueriesPerSecond: 2, Burst: 3, IPv6PrefixLen: 64})
+	client := netip.MustParseAddr("192.168.1.20")
+
+	// Burst wird sofort verbraucht, danach ist der Client begrenzt
+	for i := range 3 {
+		if !limiter.AllowQuery(client) {
+			t.Fatalf("AllowQuery() #%d = false, want true", i+1)
+		}
+	}
+	if limiter.AllowQuery(client) {
+		t.Error("AllowQuery() over burst = true, want false")
+	}
+
+	// Andere Clients haben einen eigenen Bucket
+	if !limiter.AllowQuery(netip.MustParseAddr("192.168.1.21")) {
+		t.Error("AllowQuery() for other client = false, want true")
+	}
+
+	// Nach einer halben Sekunde ist ein Token nachgefüllt
+	clock.t = clock.t.Add(500 * time.Millisecond)
+	if !limiter.AllowQuery(client) {
+		t.Error("AllowQuery() after refill = false, want true")
+	}
+	if limiter.AllowQuery(client) {
+		t.Error("AllowQuery() after single refill = true, want false")
+	}
+
+	// IPv6-Clients im selben /64 teilen sich einen Bucket
+	for range 3 {
+		limiter.AllowQuery(netip.MustParseAddr("2001:db8::1"))
+	}
+	if limiter.AllowQuery(netip.MustParseAddr("2001:db8::ffff")) {
+		t.Error("AllowQuery() for same /64 = true, want false")
+	}
+
+	stats := limiter.GetStats()
+	if stats.QueriesAllowed != 8 || stats.QueriesLimited != 3 {
+		t.Errorf("GetStats() = %+v, want 8 allowed, 3 limited", stats)
+	}
+}
+
+func TestRateLimiter_CheckResponse(t *testing.T) {
+	limiter, clock := newTestRateLimiter(t, RateLimitConfig{ResponsesPerSecond: 1, ResponseBurst: 2, Slip: 2})
+	client := netip.MustParseAddr("192.168.1.20")
+	q := dns.Question{Name: "example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+
+	want := []ResponseAction{ResponseSend, ResponseSend, ResponseDrop, ResponseSlip, ResponseDrop, ResponseSlip}
+	for i, w := range want {
+		if got := limiter.CheckResponse(client, q, dns.RcodeSuccess); got != w {
+			t.Errorf("CheckResponse() #%d = %s, want %s", i+1, got, w)
+		}
+	}
+
+	// Andere Antworten (Name, Typ, Rcode) sind nicht betroffen, Groß-/Kleinschreibung zählt nicht
+	if got := limiter.CheckResponse(client, q, dns.RcodeNameError); got != ResponseSend {
+		t.Errorf("CheckResponse() with other rcode = %s, want send", got)
+	}
+	upper := dns.Question{Name: "EXAMPLE.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	if got := limiter.CheckResponse(client, upper, dns.RcodeSuccess); got == ResponseSend {
+		t.Error("CheckResponse() with other case = send, want limited")
+	}
+
+	clock.t = clock.t.Add(time.Second)
+	if got := limiter.CheckResponse(client, q, dns.RcodeSuccess); got != ResponseSend {
+		t.Errorf("CheckResponse() after refill = %s, want send", got)
+	}
+
+	stats := limiter.GetStats()
+	if stats.ResponsesDropped+stats.ResponsesSlipped != 5 || stats.ResponsesSlipped != 2 {
+		t.Errorf("GetStats() = %+v, want 5 limited responses, 2 slipped", stats)
+	}
+
+	// Slip 0 verwirft alle Antworten über dem Limit
+	if err := limiter.SetConfig(RateLimitConfig{ResponsesPerSecond: 1, ResponseBurst: 1}); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+	limiter.CheckResponse(client, q, dns.RcodeSuccess)
+	for range 3 {
+		if got := limiter.CheckResponse(client, q, dns.RcodeSuccess); got != ResponseDrop {
+			t.Errorf("CheckResponse() with slip 0 = %s, want drop", got)
+		}
+	}
+}
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	limiter, clock := newTestRateLimiter(t, RateLimitConfig{QueriesPerSecond: 10, Burst: 10})
+
+	for i := range 5 {
+		limiter.AllowQuery(netip.AddrFrom4([4]byte{10, 0, 0, byte(i)}))
+	}
+	if limiter.clients.len() != 5 {
+		t.Fatalf("clients = %d, want 5", limiter.clients.len())
+	}
+
+	// Nach dem Sweep-Intervall sind alle Buckets wieder voll und werden entfernt
+	clock.t = clock.t.Add(sweepInterval)
+	limiter.AllowQuery(netip.MustParseAddr("10.0.0.200"))
+	if limiter.clients.len() != 1 {
+		t.Errorf("clients after sweep = %d, want 1", limiter.clients.len())
+	}
+}
+
+func TestRateLimiter_BucketLimit(t *testing.T) {
+	limiter, _ := newTestRateLimiter(t, RateLimitConfig{
+		QueriesPerSecond:   10,
+		Burst:              10,
+		ResponsesPerSecond: 10,
+		ResponseBurst:      10,
+	})
+	limiter.clients.limit = 100
+	limiter.responses.limit = 100
+
+	// Ein aktiver Client bleibt während der Flut als zuletzt genutzter Bucket erhalten
+	active := netip.MustParseAddr("192.168.1.20")
+
+	// Flut mit gefälschten Absendern: die Maps wachsen nicht über das Limit,
+	// neue Absender verdrängen die am längsten ungenutzten Buckets statt abgewiesen zu werden
+	q := dns.Question{Name: "example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	for i := range 1000 {
+		addr := netip.AddrFrom4([4]byte{198, 51, byte(i >> 8), byte(i)})
+		if !limiter.AllowQuery(addr) {
+			t.Fatalf("AllowQuery(%s) for new source should be allowed", addr)
+		}
+		if action := limiter.CheckResponse(addr, q, dns.RcodeSuccess); action != ResponseSend {
+			t.Fatalf("CheckResponse(%s) = %s, want send", addr, action)
+		}
+		if i%50 == 0 {
+			limiter.AllowQuery(active)
+		}
+	}
+	if limiter.clients.len() > 100 || limiter.responses.len() > 100 {
+		t.Errorf("buckets = %d clients, %d responses, want at most 100 each", limiter.clients.len(), limiter.responses.len())
+	}
+	// Der aktive Client behält seinen Bucket samt verbrauchter Tokens: 20 Anfragen bei Burst 10
+	if stats := limiter.GetStats(); stats.BucketsEvicted != 901+900 || stats.QueriesLimited != 10 {
+		t.Errorf("GetStats() = %+v, want 1801 evicted buckets and 10 limited queries of the active client", stats)
+	}
+	if _, ok := limiter.clients.buckets[limiter.clientPrefix(active)]; !ok {
+		t.Error("bucket of active client should survive the flood")
+	}
+}
+
+func TestDNSServer_RateLimit(t *testing.T) {
+	blacklist := dnsinternal.NewBlacklist()
+	blacklist.AddDomain("blocked.example.com")
+	proxy := dnsinternal.NewProxy(dnsinternal.NewRegistry(), blacklist)
+	server, _ := NewDNSServer("127.0.0.1:0", proxy)
+	defer server.Stop()
+
+	if server.GetRateLimiter() == nil {
+		t.Fatal("new server should have a rate limiter with default limits")
+	}
+
+	limiter, _ := newTestRateLimiter(t, RateLimitConfig{ResponsesPerSecond: 1, ResponseBurst: 1, Slip: 1})
+	server.SetRateLimiter(limiter)
+
+	query := new(dns.Msg)
+	query.SetQuestion("blocked.example.com.", dns.TypeA)
+	udp := &net.UDPAddr{IP: net.ParseIP("192.168.1.20"), Port: 40000}
+	tcp := &net.TCPAddr{IP: net.ParseIP("192.168.1.20"), Port: 40000}
+
+	// Erste Antwort vollständig, die zweite abgeschnitten (Slip 1)
+	w := &recordingWriter{remote: udp}
+	server.handleDNSRequest(w, query)
+	server.handleDNSRequest(w, query)
+	if len(w.msgs) != 2 {
+		t.Fatalf("handleDNSRequest() wrote %d messages, want 2", len(w.msgs))
+	}
+	if w.msgs[0].Truncated || len(w.msgs[0].Answer) != 1 {
+		t.Errorf("first response = %v, want full answer", w.msgs[0])
+	}
+	if !w.msgs[1].Truncated || len(w.msgs[1].Answer) != 0 || w.msgs[1].Id != query.Id {
+		t.Errorf("second response = %v, want empty truncated reply", w.msgs[1])
+	}
+
+	// Über TCP gilt kein Response Rate Limiting
+	w = &recordingWriter{remote: tcp}
+	server.handleDNSRequest(w, query)
+	if len(w.msgs) != 1 || w.msgs[0].Truncated || len(w.msgs[0].Answer) != 1 {
+		t.Errorf("TCP response = %v, want full answer", w.msgs)
+	}
+
+	// Clients über dem Anfrage-Limit bekommen keine Antwort
+	limiter.SetConfig(RateLimitConfig{QueriesPerSecond: 1, Burst: 1})
+	w = &recordingWriter{remote: udp}
+	server.handleDNSRequest(w, query)
+	server.handleDNSRequest(w, query)
+	if len(w.msgs) != 1 {
+		t.Errorf("handleDNSRequest() over query limit wrote %d messages, want 1", len(w.msgs))
+	}
+	if stats := limiter.GetStats(); stats.QueriesLimited != 1 || stats.ResponsesSlipped != 1 {
+		t.Errorf("GetStats() = %+v, want 1 limited query, 1 slipped response", stats)
+	}
+}
+
+func TestDNSServer_RateLimitDeniedClients(t *testing.T) {
+	proxy := dnsinternal.NewProxy(dnsinternal.NewRegistry(), dnsinternal.NewBlacklist())
+	server, _ := NewDNSServer("127.0.0.1:0", proxy)
+	defer server.Stop()
+
+	if server.GetRefuseRateLimiter() == nil {
+		t.Fatal("new server should limit refused responses by default")
+	}
+
+	limiter, _ := newTestRateLimiter(t, RateLimitConfig{QueriesPerSecond: 10, Burst: 5})
+	refuse, _ := newTestRateLimiter(t, RateLimitConfig{QueriesPerSecond: 1, Burst: 2, IPv4PrefixLen: 24})
+	server.SetRateLimiter(limiter)
+	server.SetRefuseRateLimiter(refuse)
+
+	query := new(dns.Msg)
+	query.SetQuestion("example.com.", dns.TypeA)
+
+	// Ein gefälschter öffentlicher Absender darf mit REFUSED nicht ungebremst reflektiert werden
+	w := &recordingWriter{remote: &net.UDPAddr{IP: net.ParseIP("198.51.100.7"), Port: 40000}}
+	for range 20 {
+		server.handleDNSRequest(w, query)
+	}
+	if len(w.msgs) != 2 {
+		t.Errorf("handleDNSRequest() for denied client wrote %d messages, want 2 (refuse burst)", len(w.msgs))
+	}
+	for _, msg := range w.msgs {
+		if msg.Rcode != dns.RcodeRefused {
+			t.Errorf("Rcode = %s, want REFUSED", dns.RcodeToString[msg.Rcode])
+		}
+	}
+	if stats := refuse.GetStats(); stats.QueriesAllowed != 2 || stats.QueriesLimited != 18 {
+		t.Errorf("refuse GetStats() = %+v, want 2 allowed, 18 limited", stats)
+	}
+
+	// Nicht erlaubter Verkehr berührt das Limit der erlaubten Clients nicht
+	if stats := limiter.GetStats(); stats != (RateLimitStats{}) || limiter.clients.len() != 0 {
+		t.Errorf("GetStats() = %+v with %d buckets, want untouched limiter", stats, limiter.clients.len())
+	}
+	w = &recordingWriter{remote: &net.UDPAddr{IP: net.ParseIP("192.168.1.20"), Port: 40000}}
+	server.handleDNSRequest(w, query)
+	if len(w.msgs) != 1 || w.msgs[0].Rcode == dns.RcodeRefused {
+		t.Errorf("handleDNSRequest() for allowed client = %v, want answer", w.msgs)
+	}
+}
+
+func TestDNSServer_DeniedFloodKeepsAllowedClients(t *testing.T) {
+	proxy := dnsinternal.NewProxy(dnsinternal.NewRegistry(), dnsinternal.NewBlacklist())
+	server, _ := NewDNSServer("127.0.0.1:0", proxy)
+	defer server.Stop()
+
+	limiter, _ := newTestRateLimiter(t, RateLimitConfig{QueriesPerSecond: 10, Burst: 5})
+	refuse, _ := newTestRateLimiter(t, RateLimitConfig{QueriesPerSecond: 1, Burst: 1})
+	limiter.clients.limit = 10
+	refuse.clients.limit = 10
+	server.SetRateLimiter(limiter)
+	server.SetRefuseRateLimiter(refuse)
+
+	query := new(dns.Msg)
+	query.SetQuestion("example.com.", dns.TypeA)
+	allowed := &net.UDPAddr{IP: net.ParseIP("192.168.1.20"), Port: 40000}
+
+	w := &recordingWriter{remote: allowed}
+	server.handleDNSRequest(w, query)
+
+	// Flut mit vielen gefälschten, nicht erlaubten Absendern
+	for i := range 1000 {
+		w := &recordingWriter{remote: &net.UDPAddr{IP: net.IPv4(198, 51, byte(i>>8), byte(i)), Port: 40000}}
+		server.handleDNSRequest(w, query)
+	}
+	if n := refuse.clients.len(); n > 10 {
+		t.Errorf("refuse limiter holds %d buckets, want at most 10", n)
+	}
+	if n := limiter.clients.len(); n != 1 {
+		t.Errorf("limiter holds %d buckets, want only the allowed client", n)
+	}
+
+	// Der erlaubte Client wird weiter beantwortet
+	w = &recordingWriter{remote: allowed}
+	server.handleDNSRequest(w, query)
+	if len(w.msgs) != 1 || w.msgs[0].Rcode == dns.RcodeRefused {
+		t.Errorf("handleDNSRequest() for allowed client after flood = %v, want answer", w.msgs)
+	}
+}